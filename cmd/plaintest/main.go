@@ -1,18 +1,42 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/ssd532/plaintest/internal/collection"
 	"github.com/ssd532/plaintest/internal/core"
 	"github.com/ssd532/plaintest/internal/csv"
+	"github.com/ssd532/plaintest/internal/dataset"
+	"github.com/ssd532/plaintest/internal/environment"
+	"github.com/ssd532/plaintest/internal/expectations"
+	"github.com/ssd532/plaintest/internal/generate"
+	"github.com/ssd532/plaintest/internal/manifest"
+	"github.com/ssd532/plaintest/internal/native"
 	"github.com/ssd532/plaintest/internal/newman"
 	"github.com/ssd532/plaintest/internal/payloadsync"
+	"github.com/ssd532/plaintest/internal/project"
+	"github.com/ssd532/plaintest/internal/report"
+	"github.com/ssd532/plaintest/internal/sandbox"
 	"github.com/ssd532/plaintest/internal/scriptsync"
+	"github.com/ssd532/plaintest/internal/secrets"
+	"github.com/ssd532/plaintest/internal/tempdir"
 	"github.com/ssd532/plaintest/internal/templates"
 )
 
@@ -20,6 +44,12 @@ var rootCmd = &cobra.Command{
 	Use:   "plaintest",
 	Short: "PlainTest CLI for API testing",
 	Long:  "PlainTest provides a framework for API testing using Postman collections and CSV data.",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if err := loadProjectConfig(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
 }
 
 var versionCmd = &cobra.Command{
@@ -33,11 +63,34 @@ var versionCmd = &cobra.Command{
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Create PlainTest project structure",
-	Long:  "Creates the basic PlainTest project structure with collections/, data/, environments/, and reports/ directories plus working template files.",
+	Long: `Creates the basic PlainTest project structure with collections/, data/, environments/, and reports/ directories plus working template files.
+
+The project is created in the working directory, or the --project directory,
+never in a project found further up. Existing files are not overwritten
+unless --force is given.`,
+	// init creates a project where it is run, so it does not look for one upwards
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if err := openInitProject(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		initializer := templates.NewProjectInitializer()
+		initializer := templates.NewProjectInitializer(templates.Config{
+			CollectionsDir:  projectConfig.CollectionsDir(),
+			ScriptsDir:      projectConfig.ScriptsDir(),
+			DataDir:         projectConfig.DataDir(),
+			EnvironmentsDir: projectConfig.EnvironmentsDir(),
+			ReportsDir:      projectConfig.ReportsDir(),
+			PayloadsDir:     projectConfig.PayloadsDir(),
+			Force:           initForce,
+		})
 		if err := initializer.CreateProjectStructure(); err != nil {
-			fmt.Printf("Error creating project structure: %v\n", err)
+			if errors.Is(err, templates.ErrExists) {
+				fmt.Printf("Error: %v\nUse --force to overwrite them.\n", err)
+			} else {
+				fmt.Printf("Error creating project structure: %v\n", err)
+			}
 			os.Exit(1)
 		}
 		fmt.Println("PlainTest project initialized successfully!")
@@ -53,11 +106,86 @@ var generateReports bool
 var setupLinks []string
 var testLinks []string
 var generatedReports []string
+var linkSummaries []report.Summary
+var engineName string
+var skipExpectations bool
+var junitPath string
+var suiteName string
+var projectDir string
+var initForce bool
+var parallelLinks int
+var shardCount int
+var retryFailed int
+var matrixData bool
+var dataSeed int64
+var generatedData string
+var pinnedVars []string
+var pinnedVarFiles []string
+var keepEnv bool
+var keepTemp bool
+var linkTimeout time.Duration
+var runTimeout time.Duration
+
+// envSnapshotDir is where the environment is saved after each link with
+// --keep-env, and envSnapshotMasker hides resolved secrets in the copies
+var envSnapshotDir string
+var envSnapshotMasker *secrets.Masker
+var rerunFailed bool
+var validateCollection string
+var requiredColumns []string
+
+// generatedReportsMu guards generatedReports while links run in parallel
+var generatedReportsMu sync.Mutex
+
+// projectConfig is the plaintest.yaml of the project, or the default layout
+// when there is none
+var projectConfig = project.Default()
+
+// runner executes a single collection link. newman.Service runs links through
+// the Newman CLI; native.Service runs them in-process. Both stop when ctx
+// ends and then return its cause.
+type runner interface {
+	RunWithFlags(ctx context.Context, collection string, flags []string) (*newman.Result, error)
+	RunWithEnvironmentExport(ctx context.Context, collection string, flags []string, exportEnvPath string) (*newman.Result, error)
+	IsInstalled() bool
+}
+
+// maskedRunner hides secret values in the output of the runner it wraps
+type maskedRunner struct {
+	runner
+	masker *secrets.Masker
+}
+
+func (m maskedRunner) RunWithFlags(ctx context.Context, collection string, flags []string) (*newman.Result, error) {
+	result, err := m.runner.RunWithFlags(ctx, collection, flags)
+	return m.mask(result), err
+}
+
+func (m maskedRunner) RunWithEnvironmentExport(ctx context.Context, collection string, flags []string, exportEnvPath string) (*newman.Result, error) {
+	result, err := m.runner.RunWithEnvironmentExport(ctx, collection, flags, exportEnvPath)
+	return m.mask(result), err
+}
+
+func (m maskedRunner) mask(result *newman.Result) *newman.Result {
+	if result != nil {
+		result.Output = m.masker.Mask(result.Output)
+	}
+	return result
+}
 
 // LinkSpec represents a parsed link specification
 type LinkSpec struct {
 	Collection string
 	Items      []string // Empty means whole collection
+	Rows       string   // Row selection for this link only, overriding --rows
+}
+
+// linkResult is the outcome of one link of a run
+type linkResult struct {
+	Phase   string
+	Spec    LinkSpec
+	Summary *report.Summary // nil when the link wrote no report
+	Err     error
 }
 
 // ExecutionPhase represents setup or test phase
@@ -68,27 +196,70 @@ type ExecutionPhase struct {
 
 // Flag constants for command line flags
 const (
-	envShortFlag  = "-e"
-	envLongFlag   = "--environment"
-	dataShortFlag = "-d"
-	dataLongFlag  = "--iteration-data"
-	rowsShortFlag = "-r"
-	rowsLongFlag  = "--rows"
-	debugFlag     = "--debug"
-	reportsFlag   = "--reports"
+	envShortFlag    = "-e"
+	envLongFlag     = "--environment"
+	dataShortFlag   = "-d"
+	dataLongFlag    = "--iteration-data"
+	rowsShortFlag   = "-r"
+	rowsLongFlag    = "--rows"
+	debugFlag       = "--debug"
+	reportsFlag     = "--reports"
+	engineFlag      = "--engine"
+	noExpectFlag    = "--no-expect"
+	junitFlag       = "--junit"
+	suiteFlag       = "--suite"
+	projectFlag     = "--project"
+	parallelFlag    = "--parallel"
+	shardsFlag      = "--shards"
+	retryFlag       = "--retry-failed"
+	matrixFlag      = "--matrix"
+	seedFlag        = "--seed"
+	varFlag         = "--var"
+	varFileFlag     = "--var-file"
+	keepEnvFlag     = "--keep-env"
+	keepTempFlag    = "--keep-temp"
+	linkTimeoutFlag = "--link-timeout"
+	runTimeoutFlag  = "--run-timeout"
+
+	// envVarFlag sets an environment variable in Newman and the native engine
+	envVarFlag = "--env-var"
+
+	// Engine names for --engine
+	newmanEngine = "newman"
+	nativeEngine = "native"
 
 	// Newman flag constants
 	reportersFlag    = "--reporters"
 	jsonReporter     = "json"
 	defaultReporters = "cli,htmlextra,json"
+	summaryReporters = "cli,json"
 	jsonExportFlag   = "--reporter-json-export"
 	htmlExportFlag   = "--reporter-htmlextra-export"
+	folderFlag       = "--folder"
 
 	// File constants
-	reportsDir      = "reports"
 	timestampFormat = "20060102T150405"
+
+	// Exit codes of run and rerun
+	exitFailed      = 1
+	exitTimedOut    = 124
+	exitInterrupted = 130
+)
+
+// Causes of a stopped link or run
+var (
+	errTimedOut    = errors.New("timed out")
+	errInterrupted = errors.New("interrupted")
 )
 
+// String returns the link as written on the command line
+func (l LinkSpec) String() string {
+	if len(l.Items) == 0 {
+		return l.Collection
+	}
+	return l.Collection + "." + strings.Join(l.Items, ",")
+}
+
 // parseLinkSpec parses a link specification like "collection.item1,item2"
 func parseLinkSpec(linkSpec string) (LinkSpec, error) {
 	// Handle quoted strings and dots
@@ -156,14 +327,15 @@ func parsePhases(setupLinks, testLinks []string) ([]ExecutionPhase, error) {
 	return phases, nil
 }
 
-// executeLinkSpec executes a single link specification
-func executeLinkSpec(linkSpec LinkSpec, phase string, linkIndex, totalLinks int, config *DiscoveryConfig,
-	newmanFlags []string, service *newman.Service, tempEnvFile *string) error {
+// executeLinkSpec executes a single link specification, writing its progress
+// to out. It returns the link's summary, or nil when it wrote no report
+func executeLinkSpec(ctx context.Context, linkSpec LinkSpec, phase string, linkIndex, totalLinks int, config *DiscoveryConfig,
+	newmanFlags []string, service runner, tempEnvFile *string, out io.Writer) (*report.Summary, error) {
 
 	// Find collection path
 	collectionPath, err := getCollectionPath(linkSpec.Collection, config)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Build flags for this link
@@ -172,7 +344,7 @@ func executeLinkSpec(linkSpec LinkSpec, phase string, linkIndex, totalLinks int,
 
 	// Add folder selections if any
 	for _, item := range linkSpec.Items {
-		currentFlags = append(currentFlags, "--folder", item)
+		currentFlags = append(currentFlags, folderFlag, item)
 	}
 
 	// For setup phase, remove CSV iteration flags
@@ -180,48 +352,1134 @@ func executeLinkSpec(linkSpec LinkSpec, phase string, linkIndex, totalLinks int,
 		currentFlags = removeCsvFlags(currentFlags)
 	}
 
-	// Apply row selection if specified and this is test phase
-	if phase == "test" && rowSelection != "" {
-		currentFlags = applyRowSelection(currentFlags, rowSelection)
+	// Apply row selection if specified and this is test phase. The rows it
+	// keeps number the retried and flaky rows as in the original data file
+	var selectedRows []int
+	if rows := linkRows(linkSpec); phase == "test" && rows != "" {
+		if dataFile := extractCSVFromFlags(currentFlags); dataFile != "" {
+			if selectedRows, err = dataset.SelectedRows(dataFile, rows); err != nil {
+				return nil, fmt.Errorf("processing data rows: %v", err)
+			}
+		}
+		currentFlags, err = applyRowSelection(out, currentFlags, rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Newman reads JSON iteration data, not YAML
+	if dataFile := extractCSVFromFlags(currentFlags); phase == "test" && dataset.FormatOf(dataFile) == dataset.YAML {
+		jsonFile, err := dataset.ToJSON(dataFile)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanupTempFile(jsonFile)
+		currentFlags = replaceCSVInFlags(currentFlags, jsonFile)
+	}
+
+	// Assert the expected_* columns of the data file
+	if phase == "test" && !skipExpectations {
+		injected, err := injectExpectations(out, collectionPath, currentFlags)
+		if err != nil {
+			return nil, err
+		}
+		if injected != "" {
+			defer cleanupTempFile(injected)
+			collectionPath = injected
+		}
+	}
+
+	// Use shared environment from previous link
+	if *tempEnvFile != "" {
+		currentFlags = replaceEnvironmentInFlags(currentFlags, *tempEnvFile)
+	}
+
+	// Add report flags if requested
+	if generateReports {
+		currentFlags = addReportFlags(currentFlags, linkSpec.Collection)
+	}
+
+	// Always write a JSON report for the run summary
+	currentFlags, reportPath, tempReport, err := addJSONExport(currentFlags)
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary report file: %v", err)
+	}
+	if tempReport {
+		defer cleanupTempFile(reportPath)
+	}
+
+	// Keep the environment the link starts from for retrying its failed rows,
+	// since the link may export over it
+	retrying := phase == "test" && retryFailed > 0 && extractCSVFromFlags(currentFlags) != ""
+	var retryEnvFile string
+	if retrying {
+		retryEnvFile, err = copyEnvironmentFile(environmentFromFlags(currentFlags))
+		if err != nil {
+			return nil, err
+		}
+		defer cleanupTempFile(retryEnvFile)
+	}
+
+	// Print execution status
+	printLinkStatus(out, linkSpec, phase, linkIndex, totalLinks)
+
+	// The link stops at --link-timeout, or when the run stops
+	linkCtx := ctx
+	if linkTimeout > 0 {
+		var cancel context.CancelFunc
+		linkCtx, cancel = context.WithTimeoutCause(ctx, linkTimeout, fmt.Errorf("link %w after %s", errTimedOut, linkTimeout))
+		defer cancel()
+	}
+	linkStarted := time.Now()
+
+	var result *newman.Result
+
+	// If not the last link, export environment for next link. With
+	// --keep-env every link exports, to be saved as a snapshot
+	var exportEnvFile string
+	if linkIndex < totalLinks || envSnapshotDir != "" {
+		if *tempEnvFile == "" {
+			*tempEnvFile, err = createTempEnvironmentFile()
+			if err != nil {
+				return nil, fmt.Errorf("creating temporary environment file: %v", err)
+			}
+		}
+		exportEnvFile = *tempEnvFile
+	}
+
+	switch {
+	case phase == "test" && shardCount > 1 && extractCSVFromFlags(currentFlags) != "":
+		result, err = runShards(linkCtx, out, service, collectionPath, currentFlags, reportPath, exportEnvFile)
+	case exportEnvFile != "":
+		result, err = service.RunWithEnvironmentExport(linkCtx, collectionPath, currentFlags, exportEnvFile)
+	default:
+		result, err = service.RunWithFlags(linkCtx, collectionPath, currentFlags)
+	}
+	if exportEnvFile != "" {
+		warnPinnedOverwrites(out, linkSpec, phase, exportEnvFile, currentFlags)
+	}
+	if envSnapshotDir != "" {
+		if _, snapshotErr := saveEnvironmentSnapshot(linkIndex, linkSpec.Collection, exportEnvFile); snapshotErr != nil {
+			fmt.Fprintf(out, "Warning: could not save the environment of %s: %v\n", linkSpec, snapshotErr)
+		}
+	}
+
+	var flaky map[int]int
+	if retrying && linkCtx.Err() == nil {
+		var failing bool
+		var retryErr error
+		flaky, failing, retryErr = retryFailedRows(linkCtx, out, service, collectionPath, currentFlags, selectedRows, reportPath, retryEnvFile)
+		// A retry stopped with the link is reported as the link's stop
+		if retryErr != nil && linkCtx.Err() == nil {
+			fmt.Fprintf(out, "Warning: could not retry failed rows: %v\n", retryErr)
+			flaky = nil
+		} else if len(flaky) > 0 && !failing {
+			// Every failed row passed on retry
+			result, err = &newman.Result{Success: true}, nil
+		}
+	}
+
+	summary := summarizeLink(linkSpec, phase, reportPath, currentFlags)
+	if summary != nil {
+		for _, iteration := range sortedKeys(flaky) {
+			summary.AddFlaky(iteration, dataRow(selectedRows, iteration), flaky[iteration])
+		}
+	}
+
+	// A stopped link is summarized with what its report holds, if anything
+	if linkCtx.Err() != nil {
+		err = context.Cause(linkCtx)
+		if summary == nil {
+			summary = &report.Summary{Link: linkSpec.String(), Phase: phase, Duration: time.Since(linkStarted)}
+		}
+		summary.Stopped = err.Error()
+	}
+
+	if err != nil {
+		if result != nil && result.Output != "" {
+			fmt.Fprintln(out, "Newman output:")
+			fmt.Fprintln(out, result.Output)
+		}
+		return summary, fmt.Errorf("execution failed: %w", err)
+	}
+
+	return summary, handleResult(out, result, linkSpec.Collection, currentFlags)
+}
+
+// retryFailedRows reruns the data rows of a test link that failed, up to
+// retryFailed times, each time from the environment the link started from.
+// The reruns are folded into the link's report. selectedRows are the rows of
+// the original data file that the link's data holds, nil for all of them. It
+// returns the iterations that passed on a retry, mapped to the attempt they
+// passed on, and whether any row still fails. When ctx ends, retrying stops
+// with its cause
+func retryFailedRows(ctx context.Context, out io.Writer, service runner, collectionPath string, flags []string, selectedRows []int,
+	reportPath, envFile string) (map[int]int, bool, error) {
+	original, err := report.Load(reportPath)
+	if err != nil {
+		// Nothing ran far enough to report, so there are no rows to retry
+		return nil, true, nil
+	}
+
+	dataFile := extractCSVFromFlags(flags)
+	rowCount, err := dataset.Count(dataFile)
+	if err != nil {
+		return nil, true, err
+	}
+
+	// Iterations past the last row reuse it and cannot be retried on their own
+	var failed []int
+	for _, iteration := range original.FailedIterations() {
+		if iteration < rowCount {
+			failed = append(failed, iteration)
+		}
+	}
+	if len(failed) == 0 {
+		return nil, len(original.Run.Failures) > 0, nil
+	}
+
+	flaky := make(map[int]int)
+	var stopped error
+	for attempt := 1; attempt <= retryFailed && len(failed) > 0; attempt++ {
+		// The retry data is selected from the link's data, and printed with
+		// the rows of the original data file
+		rows := make([]string, len(failed))
+		dataRows := make([]string, len(failed))
+		for i, iteration := range failed {
+			rows[i] = strconv.Itoa(iteration + 1)
+			dataRows[i] = strconv.Itoa(dataRow(selectedRows, iteration))
+		}
+		selection := strings.Join(rows, ",")
+		fmt.Fprintf(out, "Retrying failed rows %s (attempt %d/%d)\n", strings.Join(dataRows, ","), attempt, retryFailed)
+
+		retryCSV, err := dataset.Select(dataFile, selection)
+		if err != nil {
+			return flaky, true, err
+		}
+		retryReport, err := createTempReportFile()
+		if err != nil {
+			cleanupTempFile(retryCSV)
+			return flaky, true, fmt.Errorf("creating temporary report file: %v", err)
+		}
+
+		retryFlags := replaceCSVInFlags(flags, retryCSV)
+		retryFlags = replaceFlagValue(retryFlags, jsonExportFlag, retryReport)
+		if envFile != "" {
+			retryFlags = replaceEnvironmentInFlags(retryFlags, envFile)
+		}
+		if htmlFile := flagValue(retryFlags, htmlExportFlag); htmlFile != "" {
+			htmlFile = fmt.Sprintf("%s_retry%d.html", strings.TrimSuffix(htmlFile, ".html"), attempt)
+			retryFlags = replaceFlagValue(retryFlags, htmlExportFlag, htmlFile)
+			generatedReportsMu.Lock()
+			generatedReports = append(generatedReports, htmlFile)
+			generatedReportsMu.Unlock()
+		}
+
+		result, runErr := service.RunWithFlags(ctx, collectionPath, retryFlags)
+		rerun, err := report.Load(retryReport)
+		cleanupTempFile(retryCSV)
+		cleanupTempFile(retryReport)
+		// A stopped retry did not run all its rows, so it does not count
+		if ctx.Err() != nil {
+			stopped = context.Cause(ctx)
+			break
+		}
+		// A retry whose rows failed still reports them; one that could not
+		// run does not
+		if runErr != nil && (result == nil || err != nil) {
+			return flaky, true, fmt.Errorf("retry %d: %w", attempt, runErr)
+		}
+		if err != nil {
+			return flaky, true, fmt.Errorf("retry %d: %w", attempt, err)
+		}
+
+		original.ApplyRetry(rerun, failed)
+		stillFailing := make(map[int]bool)
+		for _, iteration := range rerun.FailedIterations() {
+			if iteration < len(failed) {
+				stillFailing[failed[iteration]] = true
+			}
+		}
+		var next []int
+		for _, iteration := range failed {
+			if stillFailing[iteration] {
+				next = append(next, iteration)
+			} else {
+				flaky[iteration] = attempt
+			}
+		}
+		failed = next
+	}
+
+	if len(flaky) > 0 {
+		fmt.Fprintf(out, "%d of the failed rows passed on retry\n", len(flaky))
+	}
+	if err := original.Save(reportPath); err != nil {
+		return flaky, true, err
+	}
+	return flaky, len(original.Run.Failures) > 0, stopped
+}
+
+// dataRow returns the one based row of the original data file that the zero
+// based iteration ran, given the rows a row selection kept
+func dataRow(selectedRows []int, iteration int) int {
+	if iteration < len(selectedRows) {
+		return selectedRows[iteration]
+	}
+	return iteration + 1
+}
+
+// sortedKeys returns the keys of m in ascending order
+func sortedKeys(m map[int]int) []int {
+	keys := make([]int, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// runShards runs a test link as one worker per row range of its data file,
+// all starting from the same environment, and merges the shard reports into
+// reportPath in the original row order. The last shard exports the
+// environment for the next link
+func runShards(ctx context.Context, out io.Writer, service runner, collectionPath string, flags []string, reportPath, exportEnvFile string) (*newman.Result, error) {
+	dataFile := extractCSVFromFlags(flags)
+	shards, err := dataset.Split(dataFile, shardCount)
+	if err != nil {
+		return nil, fmt.Errorf("sharding %s: %v", dataFile, err)
+	}
+	defer func() {
+		for _, shard := range shards {
+			cleanupTempFile(shard.Path)
+		}
+	}()
+	fmt.Fprintf(out, "Running %d shards of %s\n", len(shards), dataFile)
+
+	// The last shard exports to a file of its own, so that no shard reads the
+	// shared environment while it is being written
+	var shardEnvFile string
+	if exportEnvFile != "" {
+		if shardEnvFile, err = createTempEnvironmentFile(); err != nil {
+			return nil, fmt.Errorf("creating temporary environment file: %v", err)
+		}
+		defer cleanupTempFile(shardEnvFile)
+	}
+
+	results := make([]*newman.Result, len(shards))
+	errs := make([]error, len(shards))
+	reports := make([]*report.Report, len(shards))
+	offsets := make([]int, len(shards))
+	var wg sync.WaitGroup
+
+	// HTML reports are not merged: each shard writes its own, listed in
+	// place of the link's
+	htmlFile := flagValue(flags, htmlExportFlag)
+	var shardHTMLFiles []string
+
+	for i, shard := range shards {
+		offsets[i] = shard.First - 1
+		shardReport, err := createTempReportFile()
+		if err != nil {
+			return nil, fmt.Errorf("creating temporary report file: %v", err)
+		}
+		defer cleanupTempFile(shardReport)
+
+		shardFlags := replaceCSVInFlags(flags, shard.Path)
+		shardFlags = replaceFlagValue(shardFlags, jsonExportFlag, shardReport)
+		if htmlFile != "" {
+			shardHTML := fmt.Sprintf("%s_shard%d.html", strings.TrimSuffix(htmlFile, ".html"), i+1)
+			shardFlags = replaceFlagValue(shardFlags, htmlExportFlag, shardHTML)
+			shardHTMLFiles = append(shardHTMLFiles, shardHTML)
+		}
+
+		wg.Add(1)
+		go func(i int, shardFlags []string, shardReport string) {
+			defer wg.Done()
+			if i == len(shards)-1 && shardEnvFile != "" {
+				results[i], errs[i] = service.RunWithEnvironmentExport(ctx, collectionPath, shardFlags, shardEnvFile)
+			} else {
+				results[i], errs[i] = service.RunWithFlags(ctx, collectionPath, shardFlags)
+			}
+			reports[i], _ = report.Load(shardReport)
+		}(i, shardFlags, shardReport)
+	}
+	wg.Wait()
+	if htmlFile != "" {
+		replaceGeneratedReport(htmlFile, shardHTMLFiles)
+	}
+
+	if err := report.Merge(reports, offsets).Save(reportPath); err != nil {
+		return nil, fmt.Errorf("merging shard reports: %v", err)
+	}
+	if shardEnvFile != "" {
+		if data, err := os.ReadFile(shardEnvFile); err == nil && len(data) > 0 {
+			if err := os.WriteFile(exportEnvFile, data, 0o600); err != nil {
+				return nil, fmt.Errorf("exporting environment: %v", err)
+			}
+		}
+	}
+
+	// Combine the shards into one result, in row order
+	combined := &newman.Result{Success: true}
+	var output strings.Builder
+	var firstErr error
+	for i, shard := range shards {
+		result := results[i]
+		if result != nil && result.Output != "" {
+			fmt.Fprintf(&output, "Shard %d (rows %d-%d):\n%s\n", i+1, shard.First, shard.Last, result.Output)
+		}
+		if errs[i] != nil && firstErr == nil {
+			firstErr = fmt.Errorf("shard %d (rows %d-%d): %v", i+1, shard.First, shard.Last, errs[i])
+		}
+		if result == nil || !result.Success {
+			combined.Success = false
+			if result != nil && combined.ExitCode == 0 {
+				combined.ExitCode = result.ExitCode
+			}
+		}
+	}
+	combined.Output = output.String()
+	return combined, firstErr
+}
+
+// executeRun runs the phases in order, setup first, then test, and prints
+// the run summary and reports. The run is recorded for plaintest rerun. It
+// returns the exit code
+func executeRun(ctx context.Context, phases []ExecutionPhase, config *DiscoveryConfig, newmanFlags []string, service runner) int {
+	// Temporary files of the run go to a private directory, removed with
+	// everything in it when the run ends
+	removeTempDir, err := startTempDir()
+	if err != nil {
+		fmt.Printf("Error creating temporary directory: %v\n", err)
+		return 1
+	}
+	defer removeTempDir()
+
+	if runTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, runTimeout, fmt.Errorf("run %w after %s", errTimedOut, runTimeout))
+		defer cancel()
+	}
+
+	var tempEnvFile string
+	var exitCode int
+	defer func() {
+		if tempEnvFile != "" {
+			cleanupTempFile(tempEnvFile)
+		}
+	}()
+
+	// Pinned variables are added here, so that newmanFlags, recorded for
+	// rerun, never hold their values
+	runFlags, err := applyPinnedVariables(newmanFlags, pinnedVarFiles, pinnedVars)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	// Links run on the combined rows of a data matrix
+	if matrixData {
+		files := dataFilesFromFlags(newmanFlags)
+		matrixFile, err := dataset.Matrix(files)
+		if err != nil {
+			fmt.Printf("Error combining data files: %v\n", err)
+			return 1
+		}
+		defer cleanupTempFile(matrixFile)
+		rows, err := dataset.Count(matrixFile)
+		if err != nil {
+			fmt.Printf("Error combining data files: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Using data matrix of %s: %s\n", strings.Join(files, " x "), plural(rows, "row"))
+		runFlags = append(removeCsvFlags(runFlags), dataShortFlag, matrixFile)
+	}
+
+	// Resolve the secret references of each environment into a temporary
+	// copy, and keep the secrets out of printed output
+	var masker *secrets.Masker
+	var secretValues []string
+	envFiles := environmentFilesFromFlags(runFlags)
+	resolver := secrets.NewResolver(projectConfig.Resolve(secrets.VaultPath))
+	for i, envFile := range envFiles {
+		resolved, values, err := secrets.ResolveEnvironment(envFile, resolver)
+		if err != nil {
+			fmt.Printf("Error resolving secrets of %s: %v\n", envFile, err)
+			return 1
+		}
+		if resolved != "" {
+			defer cleanupTempFile(resolved)
+			fmt.Printf("Resolved %s in %s\n", plural(len(values), "secret reference"), envFile)
+			envFiles[i] = resolved
+			secretValues = append(secretValues, values...)
+		}
+	}
+	if len(secretValues) > 0 {
+		masker = secrets.NewMasker(secretValues)
+		service = maskedRunner{runner: service, masker: masker}
+	}
+
+	// Layered environments are merged into one, later files winning
+	if len(envFiles) > 1 {
+		names := environmentFilesFromFlags(runFlags)
+		merged, err := environment.Merge(envFiles)
+		if err == nil {
+			var mergedFile string
+			if mergedFile, err = merged.Write(); err == nil {
+				defer cleanupTempFile(mergedFile)
+				envFiles = []string{mergedFile}
+			}
+		}
+		if err != nil {
+			fmt.Printf("Error merging environments: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Using environments %s\n", strings.Join(names, " + "))
+	}
+	runFlags = setEnvironmentFiles(runFlags, envFiles)
+
+	// Save the starting environment and the one after each link for
+	// plaintest env diff
+	envSnapshotDir, envSnapshotMasker = "", masker
+	if keepEnv {
+		dir, err := createSnapshotDir()
+		if err != nil {
+			fmt.Printf("Error creating environment snapshot directory: %v\n", err)
+			return 1
+		}
+		envSnapshotDir = dir
+		if envFile := environmentFromFlags(runFlags); envFile != "" {
+			if _, err := saveEnvironmentSnapshot(0, "start", envFile); err != nil {
+				fmt.Printf("Warning: could not save the starting environment: %v\n", err)
+			}
+		}
+	}
+
+	// Expand generator expressions into a data file kept with the reports
+	generatedData = ""
+	if dataFile := extractCSVFromFlags(runFlags); dataFile != "" {
+		name := strings.TrimSuffix(filepath.Base(dataFile), filepath.Ext(dataFile))
+		if matrixData {
+			name = "matrix"
+		}
+		generated, err := generateData(dataFile, name)
+		if err != nil {
+			fmt.Printf("Error generating data from %s: %v\n", dataFile, err)
+			return 1
+		}
+		if generated != "" {
+			fmt.Printf("Generated data with --seed %d: %s\n", dataSeed, generated)
+			generatedData = generated
+			runFlags = replaceCSVInFlags(runFlags, generated)
+		}
+	}
+
+	started := time.Now()
+	var results []linkResult
+	var linkIndex, totalLinks int
+	for _, phase := range phases {
+		totalLinks += len(phase.Links)
+	}
+	for _, phase := range phases {
+		// Test links share nothing but the setup environment, so they may run at once
+		if phase.Phase == "test" && parallelLinks > 1 && len(phase.Links) > 1 {
+			phaseResults := runLinksParallel(ctx, phase, linkIndex, totalLinks, config, runFlags, service, tempEnvFile)
+			results = append(results, phaseResults...)
+			linkIndex += len(phase.Links)
+			for _, result := range phaseResults {
+				if result.Err != nil {
+					exitCode = max(exitCode, exitCodeOf(result.Err))
+				}
+			}
+			if exitCode != 0 {
+				break
+			}
+			continue
+		}
+
+		for _, linkSpec := range phase.Links {
+			// Links after a stop are recorded as skipped
+			if ctx.Err() != nil {
+				exitCode = exitCodeOf(context.Cause(ctx))
+				break
+			}
+			linkIndex++
+			summary, err := executeLinkSpec(ctx, linkSpec, phase.Phase, linkIndex, totalLinks,
+				config, runFlags, service, &tempEnvFile, os.Stdout)
+			results = append(results, linkResult{Phase: phase.Phase, Spec: linkSpec, Summary: summary, Err: err})
+			if err != nil {
+				fmt.Printf("Error executing %s link '%s': %v\n", phase.Phase, linkSpec.Collection, err)
+				exitCode = exitCodeOf(err)
+				break
+			}
+		}
+		if exitCode != 0 {
+			break
+		}
+	}
+
+	for _, result := range results {
+		if result.Summary != nil {
+			linkSummaries = append(linkSummaries, *result.Summary)
+		}
+	}
+	if masker != nil {
+		var summary bytes.Buffer
+		report.Print(&summary, linkSummaries)
+		fmt.Print(masker.Mask(summary.String()))
+	} else {
+		report.Print(os.Stdout, linkSummaries)
+	}
+
+	if junitPath != "" {
+		if err := report.SaveJUnit(junitPath, linkSummaries); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exitCode = max(exitCode, exitFailed)
+		} else {
+			generatedReports = append(generatedReports, junitPath)
+		}
+	}
+
+	// Show summary of generated reports
+	if len(generatedReports) > 0 || generatedData != "" || envSnapshotDir != "" {
+		fmt.Println()
+		fmt.Println("Generated Reports:")
+		if generatedData != "" {
+			fmt.Printf("   Data: %s\n", generatedData)
+		}
+		if envSnapshotDir != "" {
+			fmt.Printf("   Environments: %s\n", envSnapshotDir)
+		}
+		for _, path := range generatedReports {
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			switch filepath.Ext(path) {
+			case ".json":
+				fmt.Printf("   JSON: %s\n", path)
+			case ".xml":
+				fmt.Printf("   JUnit: %s\n", path)
+			default:
+				fmt.Printf("   HTML: %s\n", path)
+			}
+		}
+	}
+
+	run := recordRun(started, phases, results, newmanFlags, extractCSVFromFlags(runFlags))
+	if err := manifest.Save(projectConfig.Resolve(manifest.Path), run); err != nil {
+		fmt.Printf("Warning: could not record the run for plaintest rerun: %v\n", err)
+	}
+	return exitCode
+}
+
+// generateData expands the generator expressions of a data file with the
+// run's seed into a copy named after name in the reports directory. The copy
+// is kept so that a failed run can be reproduced. It returns "" when the
+// file has no generator expressions
+func generateData(dataFile, name string) (string, error) {
+	content, err := os.ReadFile(dataFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to open data file: %w", err)
+	}
+	if !generate.Contains(string(content)) {
+		return "", nil
+	}
+
+	ext := filepath.Ext(dataFile)
+	if dataset.FormatOf(dataFile) != dataset.CSV {
+		ext = ".json"
+	}
+	reportsDir := projectConfig.ReportsDir()
+	if err := os.MkdirAll(reportsDir, 0755); err != nil {
+		return "", err
+	}
+	// Runs in the same second get a numbered name of their own
+	base := filepath.Join(reportsDir, fmt.Sprintf("%s_%s", name, time.Now().Format(timestampFormat)))
+	path := base + "_data" + ext
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	for n := 2; errors.Is(err, fs.ErrExist); n++ {
+		path = fmt.Sprintf("%s_%d_data%s", base, n, ext)
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	generator := generate.New(dataSeed, time.Now())
+	err = dataset.Generate(dataFile, file, generator.Expand)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// startTempDir creates the private temporary directory of a run. The
+// returned function removes it, or with --keep-temp reports where it is. An
+// interrupt removes it too before exiting, as deferred calls do not run then
+func startTempDir() (func(), error) {
+	dir, err := tempdir.Start()
+	if err != nil {
+		return nil, err
+	}
+	return func() { removeTempFiles(dir) }, nil
+}
+
+// interruptContext returns a context that the first SIGINT or SIGTERM ends,
+// so that a run stops its links and still writes its reports. A second
+// signal exits at once. The returned function stops catching signals
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	signals := make(chan os.Signal, 2)
+	done := make(chan struct{})
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			name := "SIGINT"
+			if sig == syscall.SIGTERM {
+				name = "SIGTERM"
+			}
+			fmt.Printf("\nStopping the run on %s; press Ctrl-C again to quit at once\n", name)
+			cancel(fmt.Errorf("%w by %s", errInterrupted, name))
+		case <-done:
+			return
+		}
+		select {
+		case <-signals:
+			_ = tempdir.Remove(keepTemp)
+			os.Exit(exitInterrupted)
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		cancel(nil)
+	}
+}
+
+// exitCodeOf returns the exit code for a link that failed with err. The
+// codes grow with precedence, so the code of a run is the largest of its links
+func exitCodeOf(err error) int {
+	switch {
+	case errors.Is(err, errInterrupted):
+		return exitInterrupted
+	case errors.Is(err, errTimedOut):
+		return exitTimedOut
+	default:
+		return exitFailed
+	}
+}
+
+// checkTimeouts validates --link-timeout and --run-timeout
+func checkTimeouts() error {
+	if linkTimeout < 0 || runTimeout < 0 {
+		return fmt.Errorf("--link-timeout and --run-timeout must not be negative")
+	}
+	return nil
+}
+
+// removeTempFiles removes the temporary directory of a run, or with
+// --keep-temp leaves it and prints where it is
+func removeTempFiles(dir string) {
+	if keepTemp {
+		fmt.Printf("Temporary files kept in %s\n", dir)
+	}
+	if err := tempdir.Remove(keepTemp); err != nil {
+		fmt.Printf("Warning: could not remove temporary files in %s: %v\n", dir, err)
+	}
+}
+
+// createSnapshotDir creates the directory of a run's environment snapshots
+// in the reports directory. Runs in the same second get a numbered name of
+// their own
+func createSnapshotDir() (string, error) {
+	reportsDir := projectConfig.ReportsDir()
+	if err := os.MkdirAll(reportsDir, 0755); err != nil {
+		return "", err
+	}
+	base := filepath.Join(reportsDir, "run_"+time.Now().Format(timestampFormat))
+	dir := base
+	err := os.Mkdir(dir, 0755)
+	for n := 2; errors.Is(err, fs.ErrExist); n++ {
+		dir = fmt.Sprintf("%s_%d", base, n)
+		err = os.Mkdir(dir, 0755)
+	}
+	return dir, err
+}
+
+// saveEnvironmentSnapshot copies the environment file to env_<n>_<name>.json
+// in envSnapshotDir, with resolved secrets masked. An empty file, left by a
+// link that stopped before exporting, is not saved and "" is returned
+func saveEnvironmentSnapshot(n int, name, envFile string) (string, error) {
+	data, err := os.ReadFile(envFile)
+	if err != nil || len(data) == 0 {
+		return "", err
+	}
+	if envSnapshotMasker != nil {
+		data = []byte(envSnapshotMasker.Mask(string(data)))
+	}
+	path := filepath.Join(envSnapshotDir, fmt.Sprintf("env_%d_%s.json", n, name))
+	return path, os.WriteFile(path, data, 0o600)
+}
+
+// environmentSnapshots returns the env_<n>_*.json snapshots in dir, in link
+// order
+func environmentSnapshots(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "env_*_*.json"))
+	if err != nil {
+		return nil, err
+	}
+	index := func(path string) int {
+		n, _, _ := strings.Cut(strings.TrimPrefix(filepath.Base(path), "env_"), "_")
+		i, _ := strconv.Atoi(n)
+		return i
+	}
+	sort.SliceStable(paths, func(i, j int) bool { return index(paths[i]) < index(paths[j]) })
+	return paths, nil
+}
+
+// printEnvironmentDiff prints the variables added, changed and removed from
+// the environment file before to after
+func printEnvironmentDiff(out io.Writer, before, after string) error {
+	b, err := environment.Merge([]string{before})
+	if err != nil {
+		return err
+	}
+	a, err := environment.Merge([]string{after})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "%s -> %s\n", filepath.Base(before), filepath.Base(after))
+	changes := environment.Diff(b, a)
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "  No changes")
+	}
+	for _, c := range changes {
+		switch c.Kind {
+		case environment.Added:
+			fmt.Fprintf(out, "  + %s = %q\n", c.Key, c.After)
+		case environment.Changed:
+			fmt.Fprintf(out, "  ~ %s: %q -> %q\n", c.Key, c.Before, c.After)
+		case environment.Removed:
+			fmt.Fprintf(out, "  - %s (was %q)\n", c.Key, c.Before)
+		}
+	}
+	return nil
+}
+
+// recordRun builds the manifest of a run from the outcome of its links.
+// dataFile is the data the test links ran on, which failed iterations are
+// mapped to rows of. Links after a failure that did not run are recorded as
+// skipped
+func recordRun(started time.Time, phases []ExecutionPhase, results []linkResult, newmanFlags []string, dataFile string) *manifest.Manifest {
+	flags := absoluteFileFlags(newmanFlags)
+	run := &manifest.Manifest{
+		Started:     started,
+		Engine:      engineName,
+		NoExpect:    skipExpectations,
+		Matrix:      matrixData,
+		Parallel:    parallelLinks,
+		Shards:      shardCount,
+		RetryFailed: retryFailed,
+		Flags:       flags,
+		VarFiles:    absolutePaths(pinnedVarFiles),
+		Environment: strings.Join(environmentFilesFromFlags(flags), " + "),
+	}
+	for _, assignment := range pinnedVars {
+		if key, _, ok := strings.Cut(assignment, "="); ok && !slices.Contains(run.Vars, key) {
+			run.Vars = append(run.Vars, key)
+		}
+	}
+	if generatedData != "" {
+		run.Seed = dataSeed
+	}
+	if !matrixData {
+		run.Data = extractCSVFromFlags(flags)
+	}
+
+	var i int
+	for _, phase := range phases {
+		for _, linkSpec := range phase.Links {
+			link := manifest.Link{
+				Phase:      phase.Phase,
+				Collection: linkSpec.Collection,
+				Items:      linkSpec.Items,
+				Status:     manifest.StatusSkipped,
+			}
+			if phase.Phase == "test" {
+				link.Rows = linkRows(linkSpec)
+			}
+
+			if i < len(results) {
+				result := results[i]
+				i++
+				link.Status = manifest.StatusPassed
+				if result.Err != nil || (result.Summary != nil && !result.Summary.Passed()) {
+					link.Status = manifest.StatusFailed
+				}
+				// A stopped link did not reach all its rows, so it reruns in full
+				if result.Summary != nil && result.Summary.Stopped == "" {
+					linkData := dataFile
+					if phase.Phase != "test" {
+						linkData = ""
+					}
+					link.Failures = linkFailures(result.Summary, linkData, link.Rows)
+				}
+			}
+			run.Links = append(run.Links, link)
+		}
+	}
+	return run
+}
+
+// linkFailures returns the requests of a link that failed with the rows of
+// dataFile they failed on, in report order. Iterations of a row selection
+// are mapped back to their rows; without dataFile rows are 0
+func linkFailures(summary *report.Summary, dataFile, rows string) []manifest.Failure {
+	var selected []int
+	if dataFile != "" && rows != "" {
+		var err error
+		if selected, err = dataset.SelectedRows(dataFile, rows); err != nil {
+			return nil
+		}
+	}
+
+	var failures []manifest.Failure
+	for _, f := range summary.Failures {
+		failure := manifest.Failure{Item: f.Item}
+		switch {
+		case dataFile == "":
+		case rows == "":
+			failure.Row = f.Iteration
+		case f.Iteration >= 1 && f.Iteration <= len(selected):
+			failure.Row = selected[f.Iteration-1]
+		default:
+			continue
+		}
+		if !slices.Contains(failures, failure) {
+			failures = append(failures, failure)
+		}
+	}
+	return failures
+}
+
+// restorePinnedVariables pins the variables of the last run again for
+// rerun. Its --var-file files are read again unless --var-file is given, and
+// its --var values, which are not recorded, must be given again
+func restorePinnedVariables(last *manifest.Manifest) error {
+	if len(pinnedVarFiles) == 0 {
+		pinnedVarFiles = last.VarFiles
+	}
+	given := make(map[string]bool)
+	for _, assignment := range pinnedVars {
+		key, _, _ := strings.Cut(assignment, "=")
+		given[strings.TrimSpace(key)] = true
+	}
+	var missing []string
+	for _, key := range last.Vars {
+		if !given[key] {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the last run pinned %s with %s, whose values are not recorded; pass %s %s=<value> again",
+			strings.Join(missing, ", "), varFlag, varFlag, missing[0])
+	}
+	return nil
+}
+
+// absolutePaths returns paths made absolute, or nil for none
+func absolutePaths(paths []string) []string {
+	var result []string
+	for _, path := range paths {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		result = append(result, path)
+	}
+	return result
+}
+
+// linkRows returns the row selection of a test link
+func linkRows(linkSpec LinkSpec) string {
+	if linkSpec.Rows != "" {
+		return linkSpec.Rows
+	}
+	return rowSelection
+}
+
+// absoluteFileFlags returns a copy of flags with the environment and data
+// file paths made absolute, so a recorded run can be repeated from any
+// directory
+func absoluteFileFlags(flags []string) []string {
+	result := make([]string, len(flags))
+	copy(result, flags)
+	for i := 0; i+1 < len(result); i++ {
+		switch result[i] {
+		case envShortFlag, envLongFlag, dataShortFlag, dataLongFlag:
+			if abs, err := filepath.Abs(result[i+1]); err == nil {
+				result[i+1] = abs
+			}
+			i++
+		}
+	}
+	return result
+}
+
+// runLinksParallel runs the links of a phase on up to parallelLinks workers.
+// Each link starts from its own copy of the environment exported by earlier
+// links, and its output is printed in one piece when it finishes. Results
+// are returned in link order
+func runLinksParallel(ctx context.Context, phase ExecutionPhase, firstIndex, totalLinks int, config *DiscoveryConfig,
+	newmanFlags []string, service runner, sharedEnvFile string) []linkResult {
+
+	fmt.Printf("Running %d %s links, up to %d at a time\n", len(phase.Links), phase.Phase, parallelLinks)
+
+	results := make([]linkResult, len(phase.Links))
+	slots := make(chan struct{}, parallelLinks)
+	var printMu sync.Mutex
+	var wg sync.WaitGroup
+
+	for i, linkSpec := range phase.Links {
+		wg.Add(1)
+		go func(i int, linkSpec LinkSpec) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			var out bytes.Buffer
+			var summary *report.Summary
+			envFile, err := copyEnvironmentFile(sharedEnvFile)
+			defer func() { cleanupTempFile(envFile) }()
+			if err == nil {
+				summary, err = executeLinkSpec(ctx, linkSpec, phase.Phase, firstIndex+i+1, totalLinks,
+					config, newmanFlags, service, &envFile, &out)
+			}
+			if err != nil {
+				fmt.Fprintf(&out, "Error executing %s link '%s': %v\n", phase.Phase, linkSpec.Collection, err)
+			}
+			results[i] = linkResult{Phase: phase.Phase, Spec: linkSpec, Summary: summary, Err: err}
+
+			printMu.Lock()
+			defer printMu.Unlock()
+			_, _ = os.Stdout.Write(out.Bytes())
+		}(i, linkSpec)
+	}
+	wg.Wait()
+	return results
+}
+
+// copyEnvironmentFile gives a parallel link its own copy of the shared
+// environment file, or "" when there is none yet
+func copyEnvironmentFile(sharedEnvFile string) (string, error) {
+	if sharedEnvFile == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(sharedEnvFile)
+	if err != nil {
+		return "", fmt.Errorf("reading shared environment: %v", err)
+	}
+	envFile, err := createTempEnvironmentFile()
+	if err != nil {
+		return "", fmt.Errorf("creating temporary environment file: %v", err)
+	}
+	return envFile, os.WriteFile(envFile, data, 0o600)
+}
+
+// injectExpectations returns a copy of the collection that asserts the
+// expected_* columns of the CSV in flags, or "" when there is nothing to add
+func injectExpectations(out io.Writer, collectionPath string, flags []string) (string, error) {
+	dataFile := extractCSVFromFlags(flags)
+	if dataFile == "" {
+		return "", nil
+	}
+
+	injected, columns, err := expectations.Inject(collectionPath, dataFile, flagValues(flags, folderFlag))
+	if err != nil {
+		return "", fmt.Errorf("adding data column assertions: %v", err)
+	}
+	if injected != "" {
+		fmt.Fprintf(out, "Asserting data columns: %s\n", strings.Join(columns, ", "))
+	}
+	return injected, nil
+}
+
+// addJSONExport makes the link write a JSON report for the run summary. It
+// returns the flags, the report path and whether the path is a temporary file
+func addJSONExport(flags []string) ([]string, string, bool, error) {
+	for i, flag := range flags {
+		if flag == jsonExportFlag && i+1 < len(flags) {
+			return flags, flags[i+1], false, nil
+		}
+	}
+
+	hasReporters := false
+	for i, flag := range flags {
+		if flag == reportersFlag {
+			hasReporters = true
+			if needsJSONReporter(flags, i) {
+				flags[i+1] = flags[i+1] + "," + jsonReporter
+			}
+		}
 	}
-
-	// Use shared environment from previous link
-	if *tempEnvFile != "" {
-		currentFlags = replaceEnvironmentInFlags(currentFlags, *tempEnvFile)
+	if !hasReporters {
+		flags = append(flags, reportersFlag, summaryReporters)
 	}
 
-	// Add report flags if requested
-	if generateReports {
-		currentFlags = addReportFlags(currentFlags, linkSpec.Collection)
+	reportPath, err := createTempReportFile()
+	if err != nil {
+		return flags, "", false, err
 	}
+	return append(flags, jsonExportFlag, reportPath), reportPath, true, nil
+}
 
-	// Print execution status
-	printLinkStatus(linkSpec, phase, linkIndex, totalLinks)
-
-	var result *newman.Result
+// summarizeLink summarizes the link's JSON report for the run summary. Links
+// that failed before writing a report have no summary
+func summarizeLink(linkSpec LinkSpec, phase, reportPath string, flags []string) *report.Summary {
+	parsed, err := report.Load(reportPath)
+	if err != nil {
+		return nil
+	}
 
-	// If not the last link, export environment for next link
-	if linkIndex < totalLinks {
-		if *tempEnvFile == "" {
-			*tempEnvFile, err = createTempEnvironmentFile()
-			if err != nil {
-				return fmt.Errorf("creating temporary environment file: %v", err)
-			}
-		}
-		result, err = service.RunWithEnvironmentExport(collectionPath, currentFlags, *tempEnvFile)
-	} else {
-		result, err = service.RunWithFlags(collectionPath, currentFlags)
+	var labels []report.Label
+	if dataFile := extractCSVFromFlags(flags); dataFile != "" {
+		labels, _ = report.ReadLabels(dataFile)
 	}
+	summary := report.Summarize(linkSpec.String(), phase, parsed, labels)
+	return &summary
+}
 
-	if err != nil {
-		if result != nil && result.Output != "" {
-			fmt.Println("Newman output:")
-			fmt.Println(result.Output)
-		}
-		return fmt.Errorf("execution failed: %v", err)
+// newRunner returns the runner for the named engine
+func newRunner(engine string) (runner, error) {
+	switch engine {
+	case "", newmanEngine:
+		service := newman.NewService()
+		service.SetDebug(debugNewman)
+		return service, nil
+	case nativeEngine:
+		service := native.NewService()
+		service.SetDebug(debugNewman)
+		service.SetScriptEngine(sandbox.New())
+		return service, nil
+	default:
+		return nil, fmt.Errorf("unknown engine: %s. Available: [%s %s]", engine, newmanEngine, nativeEngine)
 	}
+}
 
-	return handleResult(result, linkSpec.Collection, currentFlags)
+// plural returns n followed by word, with an s unless n is 1
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
 }
 
 // getCollectionPath validates and returns the collection path
@@ -238,20 +1496,20 @@ func getCollectionPath(collectionName string, config *DiscoveryConfig) (string,
 }
 
 // handleResult processes Newman execution result and returns appropriate error
-func handleResult(result *newman.Result, collectionName string, flags []string) error {
+func handleResult(out io.Writer, result *newman.Result, collectionName string, flags []string) error {
 	if result.Success {
 		if isVerboseMode(flags) && result.Output != "" {
-			fmt.Println(result.Output)
+			fmt.Fprintln(out, result.Output)
 		} else {
-			fmt.Printf("%s link: All tests passed!\n", collectionName)
+			fmt.Fprintf(out, "%s link: All tests passed!\n", collectionName)
 		}
 		return nil
 	}
 
-	fmt.Printf("%s link: Tests completed with exit code: %d\n", collectionName, result.ExitCode)
+	fmt.Fprintf(out, "%s link: Tests completed with exit code: %d\n", collectionName, result.ExitCode)
 	if result.Output != "" {
-		fmt.Println("Newman output:")
-		fmt.Println(result.Output)
+		fmt.Fprintln(out, "Newman output:")
+		fmt.Fprintln(out, result.Output)
 	}
 	return fmt.Errorf("tests failed with exit code %d", result.ExitCode)
 }
@@ -274,13 +1532,8 @@ func removeCsvFlags(flags []string) []string {
 }
 
 // printLinkStatus prints the status of the current link being executed
-func printLinkStatus(linkSpec LinkSpec, phase string, linkIndex, totalLinks int) {
-	prefix := fmt.Sprintf("Running %s link %d/%d", phase, linkIndex, totalLinks)
-	if len(linkSpec.Items) > 0 {
-		fmt.Printf("%s: %s.%s\n", prefix, linkSpec.Collection, strings.Join(linkSpec.Items, ","))
-	} else {
-		fmt.Printf("%s: %s\n", prefix, linkSpec.Collection)
-	}
+func printLinkStatus(out io.Writer, linkSpec LinkSpec, phase string, linkIndex, totalLinks int) {
+	fmt.Fprintf(out, "Running %s link %d/%d: %s\n", phase, linkIndex, totalLinks, linkSpec)
 }
 
 // buildRunCommandLong creates the long description with available collections
@@ -316,35 +1569,83 @@ Examples:
   plaintest run --setup db.Init --setup auth.Login --test api_tests --reports
 
 Setup phase runs once, test phase iterates with CSV data.
-All Newman flags are supported. PlainTest-specific flags are listed below.`, availableNames, envNames, dataNames)
+All Newman flags are supported. PlainTest-specific flags are listed below.
+Use --engine native to run requests in-process without Newman.`, availableNames, envNames, dataNames)
 }
 
 var runCmd = &cobra.Command{
 	Use:   "run",
 	Short: "Execute API tests with Newman",
-	Long:  buildRunCommandLong(),
 	Args:  cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Clear any previously tracked reports
 		generatedReports = nil
+		linkSummaries = nil
+
+		// Without --seed, each run generates new data
+		if !cmd.Flags().Changed("seed") {
+			dataSeed = time.Now().UnixNano()
+		}
+
+		// Expand a named suite into its links
+		var suite project.Suite
+		if suiteName != "" {
+			var err error
+			suite, err = projectConfig.Suite(suiteName)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			setupLinks = append(append([]string{}, suite.Setup...), setupLinks...)
+			testLinks = append(append([]string{}, suite.Test...), testLinks...)
+			if rowSelection == "" {
+				rowSelection = suite.Rows
+			}
+		}
 
 		// Validate that at least setup or test is specified
 		if len(setupLinks) == 0 && len(testLinks) == 0 {
-			fmt.Println("Error: Must specify at least one --setup or --test link")
+			fmt.Println("Error: Must specify at least one --setup or --test link, or a --suite")
 			fmt.Println("Examples:")
 			fmt.Println("  plaintest run --test smoke")
 			fmt.Println("  plaintest run --setup auth --test api_tests")
+			fmt.Println("  plaintest run --suite regression")
 			os.Exit(1)
 		}
 
-		service := newman.NewService()
-		service.SetDebug(debugNewman)
+		if parallelLinks < 1 {
+			fmt.Printf("Error: --parallel must be at least 1, got %d\n", parallelLinks)
+			os.Exit(1)
+		}
+		if retryFailed < 0 {
+			fmt.Printf("Error: --retry-failed must not be negative, got %d\n", retryFailed)
+			os.Exit(1)
+		}
+		if shardCount < 1 {
+			fmt.Printf("Error: --shards must be at least 1, got %d\n", shardCount)
+			os.Exit(1)
+		}
+		if err := checkTimeouts(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		service, err := newRunner(engineName)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
 
 		if !service.IsInstalled() {
 			fmt.Println("Error: Newman is not installed. Install with: npm install -g newman newman-reporter-htmlextra")
+			fmt.Println("Or run without Newman: plaintest run --engine native ...")
 			os.Exit(1)
 		}
 
+		if generateReports && engineName == nativeEngine {
+			fmt.Println("Warning: the native engine writes JSON reports only; HTML reports are skipped")
+		}
+
 		// Discover available collections, environments, and data files
 		config := discoverAllFiles()
 
@@ -356,13 +1657,26 @@ var runCmd = &cobra.Command{
 		}
 
 		// Get Newman flags from raw args (skip "plaintest run")
-		rawArgs := os.Args[2:] // Skip "plaintest run"
+		rawArgs := runArguments(os.Args[1:])
 		_, newmanFlags, err := parseArguments(rawArgs, config)
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
 
+		// Fill in what the command line leaves out from the suite and plaintest.yaml
+		newmanFlags, err = applySuite(newmanFlags, suite, config)
+		if err != nil {
+			fmt.Printf("Error in suite %s: %v\n", suiteName, err)
+			os.Exit(1)
+		}
+		newmanFlags = applyProjectDefaults(newmanFlags, config)
+		newmanFlags, err = applyMatrix(newmanFlags, config)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
 		// Add default environment if not specified and only one environment exists
 		if !hasEnvironmentFlag(newmanFlags) {
 			if len(config.Environments) == 1 {
@@ -372,52 +1686,119 @@ var runCmd = &cobra.Command{
 				}
 			}
 		}
+		newmanFlags, err = applyEnvironmentLayers(newmanFlags, config)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
 
-		// Execute phases in order: setup first, then test
-		var tempEnvFile string
-		var exitCode int
-		defer func() {
-			if tempEnvFile != "" {
-				cleanupTempFile(tempEnvFile)
-			}
-		}()
+		ctx, stop := interruptContext()
+		exitCode := executeRun(ctx, phases, &config, newmanFlags, service)
+		stop()
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	},
+}
 
-		var linkIndex int
-		for _, phase := range phases {
-			for _, linkSpec := range phase.Links {
-				linkIndex++
-				err := executeLinkSpec(linkSpec, phase.Phase, linkIndex, len(setupLinks)+len(testLinks),
-					&config, newmanFlags, service, &tempEnvFile)
-				if err != nil {
-					fmt.Printf("Error executing %s link '%s': %v\n", phase.Phase, linkSpec.Collection, err)
-					exitCode = 1
-					break
-				}
-			}
-			if exitCode != 0 {
-				break
-			}
+var rerunCmd = &cobra.Command{
+	Use:   "rerun",
+	Short: "Repeat the last run, or only its failures",
+	Long: `Repeat the last plaintest run with the same links, flags and environment.
+
+With --failed, only the failures are repeated: setup links run again, failed
+test links run only the data rows and requests that failed, and links skipped
+after a failure run in full. Passed test links are left out.
+
+Every run is recorded in .plaintest/last_run.json under the project root.
+Pinned variables are recorded without their values: --var-file files are read
+again, and each --var of the last run must be given again.
+
+Examples:
+  plaintest rerun --failed
+  plaintest rerun --failed --junit reports/junit.xml
+  plaintest rerun --failed --var token=$API_TOKEN`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		generatedReports = nil
+		linkSummaries = nil
+
+		last, err := manifest.Load(projectConfig.Resolve(manifest.Path))
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
 		}
 
-		// Show summary of generated reports
-		if len(generatedReports) > 0 {
-			fmt.Println()
-			fmt.Println("Generated Reports:")
-			for _, report := range generatedReports {
-				if strings.HasSuffix(report, ".json") {
-					fmt.Printf("   JSON: %s\n", report)
-				} else {
-					fmt.Printf("   HTML: %s\n", report)
-				}
+		links := last.Links
+		if rerunFailed {
+			if links, err = last.Failed(); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
 			}
 		}
 
+		if err := checkTimeouts(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := restorePinnedVariables(last); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Run as the last run did; row selections are kept per link
+		engineName = last.Engine
+		skipExpectations = last.NoExpect
+		matrixData = last.Matrix
+		dataSeed = last.Seed
+		parallelLinks = max(last.Parallel, 1)
+		shardCount = max(last.Shards, 1)
+		retryFailed = last.RetryFailed
+		rowSelection = ""
+
+		service, err := newRunner(engineName)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !service.IsInstalled() {
+			fmt.Println("Error: Newman is not installed. Install with: npm install -g newman newman-reporter-htmlextra")
+			os.Exit(1)
+		}
+
+		config := discoverAllFiles()
+		phases := phasesFromManifest(links)
+		ctx, stop := interruptContext()
+		exitCode := executeRun(ctx, phases, &config, last.Flags, service)
+		stop()
 		if exitCode != 0 {
 			os.Exit(exitCode)
 		}
 	},
 }
 
+// phasesFromManifest turns recorded links back into execution phases
+func phasesFromManifest(links []manifest.Link) []ExecutionPhase {
+	var phases []ExecutionPhase
+	for _, name := range []string{"setup", "test"} {
+		phase := ExecutionPhase{Phase: name}
+		for _, link := range links {
+			if link.Phase != name {
+				continue
+			}
+			items := link.Items
+			if items == nil {
+				items = []string{}
+			}
+			phase.Links = append(phase.Links, LinkSpec{Collection: link.Collection, Items: items, Rows: link.Rows})
+		}
+		if len(phase.Links) > 0 {
+			phases = append(phases, phase)
+		}
+	}
+	return phases
+}
+
 var scriptsCmd = &cobra.Command{
 	Use:   "scripts",
 	Short: "Manage collection scripts",
@@ -431,7 +1812,7 @@ var scriptsPullCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		collectionName := args[0]
-		service := scriptsync.NewService(scriptsync.Config{})
+		service := scriptsync.NewService(scriptSyncConfig())
 		if err := service.Extract(collectionName); err != nil {
 			fmt.Printf("Error pulling scripts: %v\n", err)
 			os.Exit(1)
@@ -446,7 +1827,7 @@ var scriptsPushCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		collectionName := args[0]
-		service := scriptsync.NewService(scriptsync.Config{})
+		service := scriptsync.NewService(scriptSyncConfig())
 		if err := service.Build(collectionName); err != nil {
 			fmt.Printf("Error pushing scripts: %v\n", err)
 			os.Exit(1)
@@ -467,12 +1848,12 @@ var payloadsPullCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		collectionName := args[0]
-		service := payloadsync.NewService(payloadsync.Config{})
+		service := payloadsync.NewService(payloadSyncConfig())
 		if err := service.Extract(collectionName); err != nil {
 			fmt.Printf("Error pulling payloads: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("Successfully extracted payloads from %s to payloads/%s/\n", collectionName, collectionName)
+		fmt.Printf("Successfully extracted payloads from %s to %s/\n", collectionName, filepath.Join(projectConfig.PayloadsDir(), collectionName))
 	},
 }
 
@@ -483,19 +1864,19 @@ var payloadsPushCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		collectionName := args[0]
-		service := payloadsync.NewService(payloadsync.Config{})
+		service := payloadsync.NewService(payloadSyncConfig())
 		if err := service.Build(collectionName); err != nil {
 			fmt.Printf("Error pushing payloads: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("Successfully updated %s with payloads from payloads/%s/\n", collectionName, collectionName)
+		fmt.Printf("Successfully updated %s with payloads from %s/\n", collectionName, filepath.Join(projectConfig.PayloadsDir(), collectionName))
 	},
 }
 
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List project resources",
-	Long:  "List available collections, data files, scripts, or environments in the current PlainTest project.",
+	Long:  "List available collections, data files, scripts, environments, or suites in the current PlainTest project.",
 }
 
 var listCollectionsCmd = &cobra.Command{
@@ -505,7 +1886,7 @@ var listCollectionsCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		config := discoverAllFiles()
 		if len(config.Collections) == 0 {
-			fmt.Println("No collections found in collections/ directory")
+			fmt.Printf("No collections found in %s/ directory\n", projectConfig.CollectionsDir())
 			return
 		}
 
@@ -519,74 +1900,380 @@ var listCollectionsCmd = &cobra.Command{
 var listDataCmd = &cobra.Command{
 	Use:   "data",
 	Short: "List available data files",
-	Long:  "Lists all CSV data files found in the data directory.",
+	Long:  "Lists all CSV, JSON and YAML data files found in the data directory.",
+	Run: func(cmd *cobra.Command, args []string) {
+		config := discoverAllFiles()
+		if len(config.DataFiles) == 0 {
+			fmt.Printf("No data files found in %s/ directory\n", projectConfig.DataDir())
+			return
+		}
+
+		fmt.Println("Available data files:")
+		for name, path := range config.DataFiles {
+			fmt.Printf("  %s (%s)\n", name, path)
+		}
+
+		if len(projectConfig.Matrices) > 0 {
+			fmt.Println("Data matrices:")
+			names := make([]string, 0, len(projectConfig.Matrices))
+			for name := range projectConfig.Matrices {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("  %s (%s)\n", name, strings.Join(projectConfig.Matrices[name], " x "))
+			}
+		}
+	},
+}
+
+var listEnvironmentsCmd = &cobra.Command{
+	Use:   "environments",
+	Short: "List available environments",
+	Long:  "Lists all Postman environment files found in the environments directory.",
+	Run: func(cmd *cobra.Command, args []string) {
+		config := discoverAllFiles()
+		if len(config.Environments) == 0 {
+			fmt.Printf("No environments found in %s/ directory\n", projectConfig.EnvironmentsDir())
+			return
+		}
+
+		fmt.Println("Available environments:")
+		for name, path := range config.Environments {
+			fmt.Printf("  %s (%s)\n", name, path)
+		}
+	},
+}
+
+var listScriptsCmd = &cobra.Command{
+	Use:   "scripts",
+	Short: "List extracted scripts",
+	Long:  "Lists all extracted script directories found in the scripts directory.",
+	Run: func(cmd *cobra.Command, args []string) {
+		scriptsDir := projectConfig.ScriptsDir()
+
+		entries, err := os.ReadDir(scriptsDir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				fmt.Println("No scripts directory found")
+				return
+			}
+			fmt.Printf("Error reading scripts directory: %v\n", err)
+			return
+		}
+
+		scriptDirs := make([]string, 0)
+		for _, entry := range entries {
+			if entry.IsDir() {
+				scriptDirs = append(scriptDirs, entry.Name())
+			}
+		}
+
+		if len(scriptDirs) == 0 {
+			fmt.Printf("No extracted scripts found in %s/ directory\n", scriptsDir)
+			fmt.Println("Use 'plaintest scripts pull [collection]' to pull scripts")
+			return
+		}
+
+		fmt.Println("Available script directories:")
+		for _, dir := range scriptDirs {
+			scriptPath := filepath.Join(scriptsDir, dir)
+			fileCount := countScriptFiles(scriptPath)
+			fmt.Printf("  %s (%d script files)\n", dir, fileCount)
+		}
+	},
+}
+
+var listSuitesCmd = &cobra.Command{
+	Use:   "suites",
+	Short: "List named suites",
+	Long:  "Lists the named suites defined in plaintest.yaml.",
+	Run: func(cmd *cobra.Command, args []string) {
+		names := projectConfig.SuiteNames()
+		if len(names) == 0 {
+			fmt.Printf("No suites found. Define them under suites: in %s\n", project.FileName)
+			return
+		}
+
+		fmt.Println("Available suites:")
+		for _, name := range names {
+			suite := projectConfig.Suites[name]
+			fmt.Printf("  %s (setup: %v, test: %v)\n", name, suite.Setup, suite.Test)
+			if suite.Description != "" {
+				fmt.Printf("    %s\n", suite.Description)
+			}
+		}
+	},
+}
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Inspect environments",
+	Long:  "Inspect the environments of the current PlainTest project.",
+}
+
+var envShowCmd = &cobra.Command{
+	Use:   "show <environment>...",
+	Short: "Print the effective variables of an environment",
+	Long: `Prints the variables a run with the given environments would start with, and
+the file each one comes from. Several environments are layered as with
+several -e flags, after the environments each one extends in plaintest.yaml.
+
+Secret references are printed as they are written, not resolved. Values of
+type secret are hidden.
+
+Examples:
+  plaintest env show staging
+  plaintest env show base staging`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		config := discoverAllFiles()
+		var flags []string
+		for _, name := range args {
+			flags = append(flags, envShortFlag, resolveFilePathFromName(name, config.Environments))
+		}
+		flags, err := applyEnvironmentLayers(flags, config)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		files := environmentFilesFromFlags(flags)
+		merged, err := environment.Merge(files)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		var names []string
+		for _, file := range files {
+			names = append(names, environmentName(file, config))
+		}
+		fmt.Printf("Environment %s (%s)\n", strings.Join(args, " + "), strings.Join(names, " + "))
+		if len(merged.Variables) == 0 {
+			fmt.Println("  No variables")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "  VARIABLE\tVALUE\tFROM")
+		for _, v := range merged.Variables {
+			value := v.Text()
+			if v.Type == "secret" && !secrets.IsReference(value) {
+				value = "****"
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", v.Key, value, environmentName(v.Source, config))
+		}
+		w.Flush()
+	},
+}
+
+var envDiffCmd = &cobra.Command{
+	Use:   "diff <snapshot> <snapshot> | diff <run directory>",
+	Short: "Show the variables changed between environment snapshots",
+	Long: `Shows which variables were added (+), changed (~) or removed (-) between two
+environment files, such as the snapshots plaintest run --keep-env saves in
+reports/run_<timestamp>/.
+
+Given a run directory, each snapshot is compared with the one before it, so
+the output shows what every link of the chain changed. env_0_start.json is the
+environment the run started with.
+
+Examples:
+  plaintest env diff reports/run_20250101T120000
+  plaintest env diff reports/run_20250101T120000/env_1_auth.json reports/run_20250101T120000/env_2_users.json`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		files := args
+		if len(args) == 1 {
+			var err error
+			if files, err = environmentSnapshots(args[0]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			if len(files) < 2 {
+				fmt.Printf("Error: %s holds %s; run with --keep-env to save one after each link\n", args[0], plural(len(files), "environment snapshot"))
+				os.Exit(1)
+			}
+		}
+		for i := 1; i < len(files); i++ {
+			if i > 1 {
+				fmt.Println()
+			}
+			if err := printEnvironmentDiff(os.Stdout, files[i-1], files[i]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+var vaultCmd = &cobra.Command{
+	Use:   "vault",
+	Short: "Manage the local secrets vault",
+	Long: `Manage the encrypted vault of secrets that environment values reference as
+vault:<name>. The vault is .plaintest/vault.json under the project root,
+encrypted with the passphrase in ` + secrets.KeyVariable + `.`,
+}
+
+var vaultSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Store a secret read from standard input",
+	Long: `Stores a secret in the vault under name. The value is read from standard
+input, without its final line break, so it stays out of the shell history:
+
+  plaintest vault set api_token < token.txt
+  printf '%s' "$API_TOKEN" | plaintest vault set api_token`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		vault := openVault()
+		value, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Printf("Error reading the secret: %v\n", err)
+			os.Exit(1)
+		}
+		secret := secrets.TrimLineBreak(string(value))
+		if secret == "" {
+			fmt.Println("Error: the secret is empty; pipe its value to standard input")
+			os.Exit(1)
+		}
+		vault.Set(args[0], secret)
+		if err := vault.Save(); err != nil {
+			fmt.Printf("Error saving the vault: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Stored %s; reference it as vault:%s\n", args[0], args[0])
+	},
+}
+
+var vaultListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the names of the stored secrets",
+	Args:  cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		config := discoverAllFiles()
-		if len(config.DataFiles) == 0 {
-			fmt.Println("No data files found in data/ directory")
+		names := openVault().Names()
+		if len(names) == 0 {
+			fmt.Println("The vault is empty")
 			return
 		}
-
-		fmt.Println("Available data files:")
-		for name, path := range config.DataFiles {
-			fmt.Printf("  %s (%s)\n", name, path)
+		fmt.Println("Secrets:")
+		for _, name := range names {
+			fmt.Printf("  %s\n", name)
 		}
 	},
 }
 
-var listEnvironmentsCmd = &cobra.Command{
-	Use:   "environments",
-	Short: "List available environments",
-	Long:  "Lists all Postman environment files found in the environments directory.",
+var vaultRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a secret from the vault",
+	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		config := discoverAllFiles()
-		if len(config.Environments) == 0 {
-			fmt.Println("No environments found in environments/ directory")
-			return
+		vault := openVault()
+		if !vault.Remove(args[0]) {
+			fmt.Printf("Error: no secret named %s. Available: %v\n", args[0], vault.Names())
+			os.Exit(1)
 		}
-
-		fmt.Println("Available environments:")
-		for name, path := range config.Environments {
-			fmt.Printf("  %s (%s)\n", name, path)
+		if err := vault.Save(); err != nil {
+			fmt.Printf("Error saving the vault: %v\n", err)
+			os.Exit(1)
 		}
+		fmt.Printf("Removed %s\n", args[0])
 	},
 }
 
-var listScriptsCmd = &cobra.Command{
-	Use:   "scripts",
-	Short: "List extracted scripts",
-	Long:  "Lists all extracted script directories found in the scripts directory.",
+// openVault opens the project's vault or exits
+func openVault() *secrets.Vault {
+	vault, err := secrets.OpenVault(projectConfig.Resolve(secrets.VaultPath), os.Getenv(secrets.KeyVariable))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return vault
+}
+
+var dataCmd = &cobra.Command{
+	Use:   "data",
+	Short: "Check data files",
+	Long:  "Check the CSV data files of the current PlainTest project.",
+}
+
+var dataValidateCmd = &cobra.Command{
+	Use:   "validate <file>",
+	Short: "Validate a CSV data file",
+	Long: `Validate a CSV data file before running it.
+
+Checks that every row has one field per column, test_ids are unique, test_id
+and --require columns are filled, and expected_* columns hold values of their
+kind: expected_status a status code such as 201 or a class such as 4xx,
+expected_body.<path> a valid path and, when the cell starts with {, [ or ",
+valid JSON, and expected_header.<name> a valid header name and a value without
+line breaks. Cells with {{variables}} are checked at run time.
+
+With a collection, input_* columns are also compared with the variables its
+requests and scripts reference. The collection defaults to the one named like
+the data file.
+
+Examples:
+  plaintest data validate users
+  plaintest data validate data/users.csv --collection api_tests
+  plaintest data validate users --require test_name,input_email`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		scriptsDir := "scripts"
+		config := discoverAllFiles()
+		dataFile := resolveFilePathFromName(args[0], config.DataFiles)
+		if format := dataset.FormatOf(dataFile); format != dataset.CSV {
+			fmt.Printf("Error: data validate checks CSV files; %s is %s\n", dataFile, strings.ToUpper(format))
+			os.Exit(1)
+		}
 
-		entries, err := os.ReadDir(scriptsDir)
-		if err != nil {
-			if os.IsNotExist(err) {
-				fmt.Println("No scripts directory found")
-				return
+		rules := csv.Rules{Required: requiredColumns}
+		collectionName := validateCollection
+		if collectionName == "" {
+			name := strings.TrimSuffix(filepath.Base(dataFile), filepath.Ext(dataFile))
+			if _, ok := config.Collections[name]; ok {
+				collectionName = name
 			}
-			fmt.Printf("Error reading scripts directory: %v\n", err)
-			return
 		}
-
-		scriptDirs := make([]string, 0)
-		for _, entry := range entries {
-			if entry.IsDir() {
-				scriptDirs = append(scriptDirs, entry.Name())
+		if collectionName != "" {
+			collectionPath, err := getCollectionPath(collectionName, &config)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			coll, err := collection.Load(collectionPath)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
 			}
+			rules.References, rules.CheckReferences = coll.References(), true
+			fmt.Printf("Validating %s against collection %s\n", dataFile, collectionName)
+		} else {
+			fmt.Printf("Validating %s\n", dataFile)
+			fmt.Println("No collection named like the data file; use --collection to check input_* columns")
 		}
 
-		if len(scriptDirs) == 0 {
-			fmt.Println("No extracted scripts found in scripts/ directory")
-			fmt.Println("Use 'plaintest scripts pull [collection]' to pull scripts")
-			return
+		issues, rows, err := csv.NewProcessor().Validate(dataFile, rules)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
 		}
 
-		fmt.Println("Available script directories:")
-		for _, dir := range scriptDirs {
-			scriptPath := filepath.Join(scriptsDir, dir)
-			fileCount := countScriptFiles(scriptPath)
-			fmt.Printf("  %s (%d script files)\n", dir, fileCount)
+		var errorCount, warningCount int
+		for _, issue := range issues {
+			if issue.Warning {
+				warningCount++
+				fmt.Printf("  ! %s\n", issue)
+			} else {
+				errorCount++
+				fmt.Printf("  ✗ %s\n", issue)
+			}
+		}
+		if len(issues) == 0 {
+			fmt.Printf("✓ %s, no problems found\n", plural(rows, "row"))
+			return
+		}
+		fmt.Printf("%s: %s, %s\n", plural(rows, "row"), plural(errorCount, "error"), plural(warningCount, "warning"))
+		if errorCount > 0 {
+			os.Exit(1)
 		}
 	},
 }
@@ -646,16 +2333,267 @@ func discoverFiles(patterns []string, suffix string) map[string]string {
 func discoverAllFiles() DiscoveryConfig {
 	return DiscoveryConfig{
 		Collections: discoverFiles([]string{
-			filepath.Join("collections", "build", "*.postman_collection.json"),
-			filepath.Join("collections", "*.postman_collection.json"),
+			filepath.Join(projectConfig.CollectionsDir(), "build", "*.postman_collection.json"),
+			filepath.Join(projectConfig.CollectionsDir(), "*.postman_collection.json"),
 		}, ".postman_collection.json"),
 		Environments: discoverFiles([]string{
-			filepath.Join("environments", "*.postman_environment.json"),
+			filepath.Join(projectConfig.EnvironmentsDir(), "*.postman_environment.json"),
 		}, ".postman_environment.json"),
-		DataFiles: discoverFiles([]string{
-			filepath.Join("data", "*.csv"),
-		}, ".csv"),
+		DataFiles: discoverDataFiles(),
+	}
+}
+
+// discoverDataFiles discovers data files of every format. When two share a
+// name, CSV is preferred over JSON and JSON over YAML
+func discoverDataFiles() map[string]string {
+	files := make(map[string]string)
+	for _, ext := range dataset.Extensions {
+		found := discoverFiles([]string{filepath.Join(projectConfig.DataDir(), "*"+ext)}, ext)
+		for name, path := range found {
+			if _, exists := files[name]; !exists {
+				files[name] = path
+			}
+		}
+	}
+	return files
+}
+
+// loadProjectConfig opens the project given by --project, or the one whose
+// root is found from the working directory upwards, if any
+func loadProjectConfig() error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	var cfg *project.Config
+	if projectDir != "" {
+		cfg, err = project.Open(projectDir, cwd)
+	} else {
+		cfg, err = project.Discover(cwd)
+	}
+	if err != nil {
+		return err
+	}
+	projectConfig = cfg
+	return nil
+}
+
+// openInitProject opens the project plaintest init creates: the --project
+// directory, made if it does not exist, or else the working directory
+func openInitProject() error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	dir := cwd
+	if projectDir != "" {
+		dir = projectDir
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	cfg, err := project.Open(dir, cwd)
+	if err != nil {
+		return err
+	}
+	projectConfig = cfg
+	return nil
+}
+
+// runArguments returns the arguments after the run subcommand, skipping the
+// global flags that may come before it
+func runArguments(args []string) []string {
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == projectFlag:
+			i++ // Skip its value too
+		case strings.HasPrefix(args[i], projectFlag+"="):
+		default:
+			return args[i+1:]
+		}
+	}
+	return nil
+}
+
+// scriptSyncConfig returns the script sync directories of the project
+func scriptSyncConfig() scriptsync.Config {
+	return scriptsync.Config{CollectionsDir: projectConfig.CollectionsDir(), ScriptsDir: projectConfig.ScriptsDir()}
+}
+
+// payloadSyncConfig returns the payload sync directories of the project
+func payloadSyncConfig() payloadsync.Config {
+	return payloadsync.Config{CollectionsDir: projectConfig.CollectionsDir(), PayloadsDir: projectConfig.PayloadsDir()}
+}
+
+// resolveProjectFile resolves a name from plaintest.yaml using the lookup
+// map, or as a path relative to plaintest.yaml
+func resolveProjectFile(name string, lookupMap map[string]string) string {
+	if resolvedPath, exists := lookupMap[name]; exists {
+		return resolvedPath
+	}
+	return projectConfig.Resolve(name)
+}
+
+// applySuite adds a suite's flags, environment and data to the Newman flags.
+// Flags given on the command line take precedence
+func applySuite(flags []string, suite project.Suite, config DiscoveryConfig) ([]string, error) {
+	_, suiteFlags, err := parseArguments(suite.Flags, config)
+	if err != nil {
+		return flags, err
+	}
+	flags = append(suiteFlags, flags...)
+
+	if suite.Environment != "" && !hasEnvironmentFlag(flags) {
+		flags = append(flags, envShortFlag, resolveProjectFile(suite.Environment, config.Environments))
+	}
+	if suite.Data != "" && extractCSVFromFlags(flags) == "" {
+		data := suite.Data
+		if _, ok := projectConfig.Matrices[data]; !ok {
+			data = resolveProjectFile(data, config.DataFiles)
+		}
+		flags = append(flags, dataShortFlag, data)
+	}
+	return flags, nil
+}
+
+// applyProjectDefaults adds the default environment and reporters of
+// plaintest.yaml when the flags do not set them
+func applyProjectDefaults(flags []string, config DiscoveryConfig) []string {
+	defaults := projectConfig.Defaults
+	if defaults.Environment != "" && !hasEnvironmentFlag(flags) {
+		flags = append(flags, envShortFlag, resolveProjectFile(defaults.Environment, config.Environments))
+	}
+	if defaults.Reporters != "" && !hasReportersFlag(flags) {
+		flags = append(flags, reportersFlag, defaults.Reporters)
+	}
+	return flags
+}
+
+// applyMatrix replaces the name of a matrix from plaintest.yaml given to -d
+// with its data files and turns on --matrix. Several data files are only
+// accepted with --matrix, as Newman would read just one of them
+func applyMatrix(flags []string, config DiscoveryConfig) ([]string, error) {
+	var result []string
+	for i := 0; i < len(flags); i++ {
+		flag := flags[i]
+		if (flag == dataShortFlag || flag == dataLongFlag) && i+1 < len(flags) {
+			if members, ok := projectConfig.Matrices[flags[i+1]]; ok {
+				for _, member := range members {
+					result = append(result, dataShortFlag, resolveProjectFile(member, config.DataFiles))
+				}
+				matrixData = true
+				i++
+				continue
+			}
+		}
+		result = append(result, flag)
+	}
+
+	switch files := dataFilesFromFlags(result); {
+	case matrixData && len(files) < 2:
+		return result, fmt.Errorf("%s needs at least two -d data files, got %d", matrixFlag, len(files))
+	case !matrixData && len(files) > 1:
+		return result, fmt.Errorf("several data files given (%s); add %s to run every combination of their rows", strings.Join(files, ", "), matrixFlag)
+	}
+	return result, nil
+}
+
+// applyEnvironmentLayers puts the environments each -e environment extends in
+// plaintest.yaml before it, as more -e flags. Each file appears once
+func applyEnvironmentLayers(flags []string, config DiscoveryConfig) ([]string, error) {
+	var files []string
+	for _, envFile := range environmentFilesFromFlags(flags) {
+		layers, err := projectConfig.EnvironmentLayers(environmentName(envFile, config))
+		if err != nil {
+			return flags, err
+		}
+		for i, layer := range layers {
+			file := envFile
+			if i < len(layers)-1 {
+				file = resolveProjectFile(layer, config.Environments)
+			}
+			if !slices.Contains(files, file) {
+				files = append(files, file)
+			}
+		}
+	}
+	return setEnvironmentFiles(flags, files), nil
+}
+
+// applyPinnedVariables adds the variables of the --var-file files and then
+// the --var assignments as --env-var flags, which both engines apply over the
+// environment at the start of every link. Later assignments win
+func applyPinnedVariables(flags, files, assignments []string) ([]string, error) {
+	flags = slices.Clip(flags)
+	var vars [][2]string
+	for _, file := range files {
+		fileVars, err := environment.LoadVariables(file)
+		if err != nil {
+			return flags, err
+		}
+		vars = append(vars, fileVars...)
+	}
+	for _, assignment := range assignments {
+		pair, err := environment.ParseVariable(assignment)
+		if err != nil {
+			return flags, fmt.Errorf("%s: %v", varFlag, err)
+		}
+		vars = append(vars, pair)
+	}
+	for _, pair := range vars {
+		flags = append(flags, envVarFlag, pair[0]+"="+pair[1])
+	}
+	return flags, nil
+}
+
+// pinnedVariables returns the variables set by --env-var flags, the last
+// value of each key winning
+func pinnedVariables(flags []string) map[string]string {
+	pinned := make(map[string]string)
+	for i := 0; i < len(flags)-1; i++ {
+		if flags[i] == envVarFlag {
+			if key, value, ok := strings.Cut(flags[i+1], "="); ok {
+				pinned[key] = value
+			}
+			i++
+		}
+	}
+	return pinned
+}
+
+// warnPinnedOverwrites warns about pinned variables that a link changed in the
+// environment it exported. The next link starts from the pinned value again
+func warnPinnedOverwrites(out io.Writer, linkSpec LinkSpec, phase, envFile string, flags []string) {
+	pinned := pinnedVariables(flags)
+	if len(pinned) == 0 {
+		return
 	}
+	exported, err := environment.Merge([]string{envFile})
+	if err != nil {
+		return
+	}
+	for _, v := range exported.Variables {
+		if value, ok := pinned[v.Key]; ok && v.Text() != value {
+			fmt.Fprintf(out, "Warning: %s link %s set pinned variable %s; later links keep the --var value\n", phase, linkSpec, v.Key)
+		}
+	}
+}
+
+// environmentName returns the name of an environment file: its discovered
+// name, or its base name without extension
+func environmentName(path string, config DiscoveryConfig) string {
+	for name, envPath := range config.Environments {
+		if envPath == path {
+			return name
+		}
+	}
+	base := filepath.Base(path)
+	if name, ok := strings.CutSuffix(base, ".postman_environment.json"); ok {
+		return name
+	}
+	return strings.TrimSuffix(base, filepath.Ext(base))
 }
 
 // resolveFilePathFromName attempts to resolve a name to a file path using the lookup map
@@ -710,7 +2648,21 @@ func skipPlainTestFlag(arg string, argIndex *int) bool {
 		*argIndex += 2 // Skip flag and its value
 		return true
 	}
-	if arg == debugFlag || arg == reportsFlag {
+	if arg == engineFlag || arg == junitFlag || arg == suiteFlag || arg == projectFlag || arg == parallelFlag || arg == shardsFlag || arg == retryFlag || arg == seedFlag ||
+		arg == varFlag || arg == varFileFlag || arg == linkTimeoutFlag || arg == runTimeoutFlag {
+		*argIndex += 2 // Skip flag and its value
+		return true
+	}
+	if strings.HasPrefix(arg, engineFlag+"=") || strings.HasPrefix(arg, junitFlag+"=") ||
+		strings.HasPrefix(arg, suiteFlag+"=") || strings.HasPrefix(arg, projectFlag+"=") ||
+		strings.HasPrefix(arg, parallelFlag+"=") || strings.HasPrefix(arg, shardsFlag+"=") ||
+		strings.HasPrefix(arg, retryFlag+"=") || strings.HasPrefix(arg, seedFlag+"=") ||
+		strings.HasPrefix(arg, varFlag+"=") || strings.HasPrefix(arg, varFileFlag+"=") ||
+		strings.HasPrefix(arg, linkTimeoutFlag+"=") || strings.HasPrefix(arg, runTimeoutFlag+"=") {
+		*argIndex++
+		return true
+	}
+	if arg == debugFlag || arg == reportsFlag || arg == noExpectFlag || arg == matrixFlag || arg == keepEnvFlag || arg == keepTempFlag {
 		*argIndex++
 		return true
 	}
@@ -762,6 +2714,17 @@ func extractCSVFromFlags(flags []string) string {
 	return ""
 }
 
+// dataFilesFromFlags returns every data file in flags, in order
+func dataFilesFromFlags(flags []string) []string {
+	var files []string
+	for i, flag := range flags {
+		if (flag == dataShortFlag || flag == dataLongFlag) && i+1 < len(flags) {
+			files = append(files, flags[i+1])
+		}
+	}
+	return files
+}
+
 // replaceCSVInFlags replaces CSV file in Newman flags with new file
 func replaceCSVInFlags(flags []string, newCSVFile string) []string {
 	result := make([]string, len(flags))
@@ -787,31 +2750,135 @@ func hasEnvironmentFlag(flags []string) bool {
 	return false
 }
 
-func applyRowSelection(flags []string, rowSelection string) []string {
-	csvFile := extractCSVFromFlags(flags)
-	if csvFile == "" {
-		fmt.Println("Warning: Row selection specified but no CSV file found in flags")
-		return flags
+// flagValue returns the value of flag in flags, or ""
+func flagValue(flags []string, flag string) string {
+	for i, f := range flags {
+		if f == flag && i+1 < len(flags) {
+			return flags[i+1]
+		}
+	}
+	return ""
+}
+
+// flagValues returns the values of every occurrence of flag
+func flagValues(flags []string, flag string) []string {
+	var values []string
+	for i, f := range flags {
+		if f == flag && i+1 < len(flags) {
+			values = append(values, flags[i+1])
+		}
+	}
+	return values
+}
+
+// replaceFlagValue returns a copy of flags with the value of flag replaced
+func replaceFlagValue(flags []string, flag, value string) []string {
+	result := make([]string, len(flags))
+	copy(result, flags)
+	for i, f := range result {
+		if f == flag && i+1 < len(result) {
+			result[i+1] = value
+		}
+	}
+	return result
+}
+
+// environmentFilesFromFlags returns every environment file in flags, in order
+func environmentFilesFromFlags(flags []string) []string {
+	var files []string
+	for i, flag := range flags {
+		if (flag == envShortFlag || flag == envLongFlag) && i+1 < len(flags) {
+			files = append(files, flags[i+1])
+		}
+	}
+	return files
+}
+
+// setEnvironmentFiles returns a copy of flags whose environment flags are
+// replaced by one -e per file, where the first environment flag was
+func setEnvironmentFiles(flags []string, files []string) []string {
+	var envFlags []string
+	for _, file := range files {
+		envFlags = append(envFlags, envShortFlag, file)
+	}
+
+	result := make([]string, 0, len(flags))
+	inserted := false
+	for i := 0; i < len(flags); i++ {
+		if flags[i] == envShortFlag || flags[i] == envLongFlag {
+			if !inserted {
+				result = append(result, envFlags...)
+				inserted = true
+			}
+			if i+1 < len(flags) {
+				i++
+			}
+			continue
+		}
+		result = append(result, flags[i])
+	}
+	if !inserted {
+		result = append(result, envFlags...)
+	}
+	return result
+}
+
+// environmentFromFlags returns the environment file in flags, or ""
+func environmentFromFlags(flags []string) string {
+	if env := flagValue(flags, envShortFlag); env != "" {
+		return env
+	}
+	return flagValue(flags, envLongFlag)
+}
+
+// hasReportersFlag checks if reporters are already specified in flags
+func hasReportersFlag(flags []string) bool {
+	for _, flag := range flags {
+		if flag == reportersFlag {
+			return true
+		}
+	}
+	return false
+}
+
+func applyRowSelection(out io.Writer, flags []string, rowSelection string) ([]string, error) {
+	dataFile := extractCSVFromFlags(flags)
+	if dataFile == "" {
+		fmt.Fprintln(out, "Warning: Row selection specified but no data file found in flags")
+		return flags, nil
 	}
 
-	processor := csv.NewProcessor()
-	tempCSVFile, err := processor.ProcessRows(csvFile, rowSelection)
+	tempDataFile, err := dataset.Select(dataFile, rowSelection)
 	if err != nil {
-		fmt.Printf("Error processing CSV rows: %v\n", err)
-		os.Exit(1)
+		return flags, fmt.Errorf("processing data rows: %v", err)
 	}
 
-	fmt.Printf("Using row selection: %s from %s\n", rowSelection, csvFile)
-	return replaceCSVInFlags(flags, tempCSVFile)
+	fmt.Fprintf(out, "Using row selection: %s from %s\n", rowSelection, dataFile)
+	return replaceCSVInFlags(flags, tempDataFile), nil
 }
 
 func init() {
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(rerunCmd)
 	rootCmd.AddCommand(scriptsCmd)
 	rootCmd.AddCommand(payloadsCmd)
 	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(dataCmd)
+	rootCmd.AddCommand(envCmd)
+	rootCmd.AddCommand(vaultCmd)
+
+	// The run help lists the project's files, so it is built once the
+	// project is known; help skips PersistentPreRun
+	defaultHelp := runCmd.HelpFunc()
+	runCmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		if err := loadProjectConfig(); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		cmd.Long = buildRunCommandLong()
+		defaultHelp(cmd, args)
+	})
 
 	scriptsCmd.AddCommand(scriptsPullCmd)
 	scriptsCmd.AddCommand(scriptsPushCmd)
@@ -823,13 +2890,56 @@ func init() {
 	listCmd.AddCommand(listDataCmd)
 	listCmd.AddCommand(listEnvironmentsCmd)
 	listCmd.AddCommand(listScriptsCmd)
+	listCmd.AddCommand(listSuitesCmd)
+
+	dataCmd.AddCommand(dataValidateCmd)
+
+	envCmd.AddCommand(envShowCmd)
+	envCmd.AddCommand(envDiffCmd)
+
+	vaultCmd.AddCommand(vaultSetCmd)
+	vaultCmd.AddCommand(vaultListCmd)
+	vaultCmd.AddCommand(vaultRemoveCmd)
+
+	rootCmd.PersistentFlags().StringVar(&projectDir, "project", "", "Project root directory (default: found from the working directory upwards)")
+
+	initCmd.Flags().BoolVar(&initForce, "force", false, "Overwrite template files that already exist")
 
 	// Only PlainTest-specific flags
 	runCmd.Flags().StringSliceVar(&setupLinks, "setup", []string{}, "Setup links to run once (collection or collection.items)")
 	runCmd.Flags().StringSliceVar(&testLinks, "test", []string{}, "Test links to run with CSV iteration (collection or collection.items)")
-	runCmd.Flags().StringVarP(&rowSelection, "rows", "r", "", "CSV row selection (2 | 2-5 | 1,3,5)")
+	runCmd.Flags().StringVar(&suiteName, "suite", "", "Run a named suite from plaintest.yaml")
+	runCmd.Flags().StringVarP(&rowSelection, "rows", "r", "", "CSV row selection (2 | 2-5 | 1,4-6,10 | 5- | !3 | test_id=x | test_name~=x)")
+	runCmd.Flags().IntVar(&parallelLinks, "parallel", 1, "Run up to N test links at once after setup")
+	runCmd.Flags().IntVar(&shardCount, "shards", 1, "Split each test link's CSV rows across N concurrent workers")
+	runCmd.Flags().IntVar(&retryFailed, "retry-failed", 0, "Rerun failed CSV rows of test links up to N times")
+	runCmd.Flags().Int64Var(&dataSeed, "seed", 0, "Seed for {{$fake.*}} and {{$uuid}} data generators, to repeat a run's data (default: new each run)")
+	runCmd.Flags().StringArrayVar(&pinnedVars, "var", []string{}, "Pin an environment variable for every link (key=value), over the environment and setup exports")
+	runCmd.Flags().StringArrayVar(&pinnedVarFiles, "var-file", []string{}, "Pin the key=value variables of a .env file for every link")
+	runCmd.Flags().DurationVar(&linkTimeout, "link-timeout", 0, "Stop a link that runs longer than this (e.g. 30s, 5m); exit code 124")
+	runCmd.Flags().DurationVar(&runTimeout, "run-timeout", 0, "Stop the run after this long (e.g. 20m); exit code 124")
+	runCmd.Flags().BoolVar(&keepTemp, "keep-temp", false, "Keep the run's temporary directory of filtered data, environments and reports")
+	runCmd.Flags().BoolVar(&keepEnv, "keep-env", false, "Save the environment after each link in reports/run_<timestamp>/ (see plaintest env diff)")
+	runCmd.Flags().BoolVar(&matrixData, "matrix", false, "Run every combination of the rows of several -d data files")
 	runCmd.Flags().BoolVar(&debugNewman, "debug", false, "Print the Newman command before running")
 	runCmd.Flags().BoolVar(&generateReports, "reports", false, "Generate timestamped HTML and JSON report files")
+	runCmd.Flags().StringVar(&junitPath, "junit", "", "Write one JUnit XML file for the whole run (e.g. reports/junit.xml)")
+	runCmd.Flags().BoolVar(&skipExpectations, "no-expect", false, "Do not assert expected_* data columns automatically")
+	runCmd.Flags().StringVar(&engineName, "engine", newmanEngine, "Test engine: newman (Newman CLI) or native (in-process, no Node required)")
+
+	dataValidateCmd.Flags().StringVarP(&validateCollection, "collection", "c", "", "Collection whose variables input_* columns must match")
+	dataValidateCmd.Flags().StringSliceVar(&requiredColumns, "require", []string{}, "Columns that must not be empty (test_id always)")
+
+	rerunCmd.Flags().BoolVar(&rerunFailed, "failed", false, "Repeat only the failed rows and requests of the last run")
+	rerunCmd.Flags().BoolVar(&generateReports, "reports", false, "Generate timestamped HTML and JSON report files")
+	rerunCmd.Flags().StringVar(&junitPath, "junit", "", "Write one JUnit XML file for the whole run (e.g. reports/junit.xml)")
+	rerunCmd.Flags().BoolVar(&debugNewman, "debug", false, "Print the Newman command before running")
+	rerunCmd.Flags().DurationVar(&linkTimeout, "link-timeout", 0, "Stop a link that runs longer than this (e.g. 30s, 5m); exit code 124")
+	rerunCmd.Flags().DurationVar(&runTimeout, "run-timeout", 0, "Stop the run after this long (e.g. 20m); exit code 124")
+	rerunCmd.Flags().BoolVar(&keepTemp, "keep-temp", false, "Keep the run's temporary directory of filtered data, environments and reports")
+	rerunCmd.Flags().StringArrayVar(&pinnedVars, "var", []string{}, "Pin an environment variable again (key=value); required for each --var of the last run")
+	rerunCmd.Flags().StringArrayVar(&pinnedVarFiles, "var-file", []string{}, "Pin the variables of a .env file, in place of the last run's --var-file files")
+	rerunCmd.Flags().BoolVar(&keepEnv, "keep-env", false, "Save the environment after each link in reports/run_<timestamp>/ (see plaintest env diff)")
 
 	// Allow unknown flags to be passed to Newman
 	runCmd.FParseErrWhitelist.UnknownFlags = true
@@ -837,10 +2947,21 @@ func init() {
 
 // createTempEnvironmentFile creates a temporary environment file for collection chaining
 func createTempEnvironmentFile() (string, error) {
-	tmpDir := os.TempDir()
-	timestamp := time.Now().UnixNano()
-	tempFile := filepath.Join(tmpDir, fmt.Sprintf("plaintest_env_%d.json", timestamp))
-	return tempFile, nil
+	file, err := tempdir.CreateTemp("plaintest_env_*.json")
+	if err != nil {
+		return "", err
+	}
+	return file.Name(), file.Close()
+}
+
+// createTempReportFile returns a path for a link's summary JSON report. The
+// name is unique, so links running in parallel never share one
+func createTempReportFile() (string, error) {
+	file, err := tempdir.CreateTemp("plaintest_report_*.json")
+	if err != nil {
+		return "", err
+	}
+	return file.Name(), file.Close()
 }
 
 // replaceEnvironmentInFlags replaces environment file in Newman flags
@@ -894,20 +3015,50 @@ func needsJSONReporter(flags []string, reportersIndex int) bool {
 	return reportersValueIndex < len(flags) && !strings.Contains(flags[reportersValueIndex], jsonReporter)
 }
 
+// replaceGeneratedReport lists paths in place of the generated report path
+func replaceGeneratedReport(path string, paths []string) {
+	generatedReportsMu.Lock()
+	defer generatedReportsMu.Unlock()
+	for i, generated := range generatedReports {
+		if generated == path {
+			rest := append(append([]string{}, paths...), generatedReports[i+1:]...)
+			generatedReports = append(generatedReports[:i], rest...)
+			return
+		}
+	}
+	generatedReports = append(generatedReports, paths...)
+}
+
 func addExportPaths(flags []string, collectionName string) []string {
 	timestamp := time.Now().Format(timestampFormat)
-	jsonFile := filepath.Join(reportsDir, fmt.Sprintf("%s_%s.json", collectionName, timestamp))
-	htmlFile := filepath.Join(reportsDir, fmt.Sprintf("%s_%s.html", collectionName, timestamp))
+	reportsDir := projectConfig.ReportsDir()
+	base := filepath.Join(reportsDir, fmt.Sprintf("%s_%s", collectionName, timestamp))
+
+	// Track generated reports for summary at the end. Links of the same
+	// collection in the same second get a numbered name of their own
+	generatedReportsMu.Lock()
+	name := base
+	for n := 2; containsPath(generatedReports, name+".json"); n++ {
+		name = fmt.Sprintf("%s_%d", base, n)
+	}
+	jsonFile, htmlFile := name+".json", name+".html"
+	generatedReports = append(generatedReports, jsonFile, htmlFile)
+	generatedReportsMu.Unlock()
 
 	flags = append(flags, jsonExportFlag, jsonFile)
 	flags = append(flags, htmlExportFlag, htmlFile)
-
-	// Track generated reports for summary at the end
-	generatedReports = append(generatedReports, jsonFile, htmlFile)
-
 	return flags
 }
 
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
 func isVerboseMode(flags []string) bool {
 	for _, flag := range flags {
 		if flag == "--verbose" {
@@ -1,10 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/ssd532/plaintest/internal/manifest"
+	"github.com/ssd532/plaintest/internal/newman"
+	"github.com/ssd532/plaintest/internal/project"
+	"github.com/ssd532/plaintest/internal/report"
+	"github.com/ssd532/plaintest/internal/secrets"
+	"github.com/ssd532/plaintest/internal/tempdir"
+	"github.com/ssd532/plaintest/internal/templates"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -63,6 +79,58 @@ func TestInitCommand(t *testing.T) {
 	})
 }
 
+func TestInitCommand_InsideProject(t *testing.T) {
+	withTempDir(t, func(tempDir string) {
+		defer func() { projectConfig = project.Default() }()
+
+		// SETUP - A project with its own smoke collection, and a new directory in it
+		assert.NoError(t, os.MkdirAll("collections", 0755))
+		assert.NoError(t, os.WriteFile("collections/smoke.postman_collection.json", []byte("{}"), 0644))
+		assert.NoError(t, os.Mkdir("new", 0755))
+		assert.NoError(t, os.Chdir("new"))
+		os.Args = []string{"plaintest", "init"}
+
+		// WHEN
+		main()
+
+		// THEN - The new directory got the project, and the enclosing one was left alone
+		_, err := os.Stat("collections/smoke.postman_collection.json")
+		assert.NoError(t, err, "init should create the project in the working directory")
+		data, err := os.ReadFile(filepath.Join(tempDir, "collections/smoke.postman_collection.json"))
+		assert.NoError(t, err)
+		assert.Equal(t, "{}", string(data), "init should not write into the enclosing project")
+
+		// WHEN - It is initialized again
+		err = templates.NewProjectInitializer(templates.Config{}).CreateProjectStructure()
+
+		// THEN - Existing files are kept unless forced
+		assert.ErrorIs(t, err, templates.ErrExists)
+		assert.NoError(t, templates.NewProjectInitializer(templates.Config{Force: true}).CreateProjectStructure())
+	})
+}
+
+func TestRunHelp_ListsProjectCollections(t *testing.T) {
+	withTempDir(t, func(tempDir string) {
+		defer func() { projectConfig = project.Default() }()
+
+		// SETUP - A project keeping its collections in apis/, seen from a subdirectory
+		assert.NoError(t, os.WriteFile(project.FileName, []byte("paths:\n  collections: apis\n"), 0644))
+		assert.NoError(t, os.MkdirAll("apis", 0755))
+		assert.NoError(t, os.WriteFile("apis/orders.postman_collection.json", []byte("{}"), 0644))
+		assert.NoError(t, os.Mkdir("scripts", 0755))
+		assert.NoError(t, os.Chdir("scripts"))
+
+		// WHEN
+		var out strings.Builder
+		runCmd.SetOut(&out)
+		defer runCmd.SetOut(nil)
+		runCmd.HelpFunc()(runCmd, nil)
+
+		// THEN
+		assert.Contains(t, out.String(), "Available collections: [orders]")
+	})
+}
+
 func TestDiscoverCollections(t *testing.T) {
 	withTempDir(t, func(tempDir string) {
 		// SETUP - Create collections directory hierarchy
@@ -99,6 +167,211 @@ func TestDiscoverCollections(t *testing.T) {
 	})
 }
 
+func TestDiscoverDataFiles(t *testing.T) {
+	withTempDir(t, func(tempDir string) {
+		// SETUP - Data files of every format, two sharing a name
+		assert.NoError(t, os.MkdirAll("data", 0755))
+		for _, file := range []string{"users.csv", "users.json", "orders.json", "payments.yaml", "refunds.yml", "notes.txt"} {
+			assert.NoError(t, os.WriteFile(filepath.Join("data", file), nil, 0644))
+		}
+
+		// WHEN
+		config := discoverAllFiles()
+
+		// THEN - CSV wins a shared name, and other files are not data
+		assert.Equal(t, map[string]string{
+			"users":    "data/users.csv",
+			"orders":   "data/orders.json",
+			"payments": "data/payments.yaml",
+			"refunds":  "data/refunds.yml",
+		}, config.DataFiles)
+	})
+}
+
+func TestProjectConfig(t *testing.T) {
+	withTempDir(t, func(tempDir string) {
+		defer func() { projectConfig = project.Default() }()
+
+		// SETUP - A project with a custom layout, started from a subdirectory
+		config := `paths:
+  collections: api
+  environments: envs
+defaults:
+  environment: staging
+  reporters: cli,json
+suites:
+  regression:
+    setup: [auth]
+    test: [users]
+    data: data/users.csv
+    flags: [--bail, -e, dev]
+matrices:
+  users_by_currency: [users, currencies]
+environments:
+  staging:
+    extends: [dev]
+`
+		assert.NoError(t, os.WriteFile(project.FileName, []byte(config), 0644))
+		assert.NoError(t, os.MkdirAll("api", 0755))
+		assert.NoError(t, os.MkdirAll("envs", 0755))
+		assert.NoError(t, os.MkdirAll("sub", 0755))
+		assert.NoError(t, os.MkdirAll("data", 0755))
+		for _, file := range []string{"api/users.postman_collection.json", "envs/staging.postman_environment.json", "envs/dev.postman_environment.json", "data/users.csv", "data/currencies.yaml"} {
+			assert.NoError(t, os.WriteFile(file, []byte("{}"), 0644))
+		}
+		assert.NoError(t, os.Chdir("sub"))
+
+		// WHEN
+		assert.NoError(t, loadProjectConfig())
+		discovered := discoverAllFiles()
+
+		// THEN - Discovery follows plaintest.yaml
+		assert.Equal(t, map[string]string{"users": "../api/users.postman_collection.json"}, discovered.Collections)
+		assert.Equal(t, "../envs/staging.postman_environment.json", discovered.Environments["staging"])
+
+		t.Run("applySuite", func(t *testing.T) {
+			suite, err := projectConfig.Suite("regression")
+			assert.NoError(t, err)
+
+			// WHEN
+			got, err := applySuite([]string{"--verbose"}, suite, discovered)
+
+			// THEN - Suite flags come first so the command line wins, names resolve, paths are relative to plaintest.yaml
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"--bail", "-e", "../envs/dev.postman_environment.json", "--verbose", "-d", "../data/users.csv"}, got)
+
+			// WHEN - The command line sets the data file
+			got, err = applySuite([]string{"-d", "other.csv"}, suite, discovered)
+
+			// THEN
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"--bail", "-e", "../envs/dev.postman_environment.json", "-d", "other.csv"}, got)
+		})
+
+		t.Run("applyMatrix", func(t *testing.T) {
+			defer func() { matrixData = false }()
+
+			// WHEN - Several data files are given without --matrix
+			_, err := applyMatrix([]string{"-d", "a.csv", "-d", "b.csv"}, discovered)
+
+			// THEN
+			assert.ErrorContains(t, err, "add --matrix")
+
+			// WHEN - A matrix from plaintest.yaml is named
+			got, err := applyMatrix([]string{"--bail", "-d", "users_by_currency"}, discovered)
+
+			// THEN - Its data files replace it and --matrix is on
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"--bail", "-d", "../data/users.csv", "-d", "../data/currencies.yaml"}, got)
+			assert.True(t, matrixData)
+
+			// WHEN - --matrix is given with a single data file
+			_, err = applyMatrix([]string{"-d", "a.csv"}, discovered)
+
+			// THEN
+			assert.ErrorContains(t, err, "at least two")
+		})
+
+		t.Run("applyEnvironmentLayers", func(t *testing.T) {
+			// WHEN - staging extends dev in plaintest.yaml
+			got, err := applyEnvironmentLayers([]string{"--bail", "-e", "../envs/staging.postman_environment.json", "-d", "x.csv"}, discovered)
+
+			// THEN - dev comes first so staging wins
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"--bail", "-e", "../envs/dev.postman_environment.json", "-e", "../envs/staging.postman_environment.json", "-d", "x.csv"}, got)
+
+			// WHEN - Layers are given on the command line too
+			got, err = applyEnvironmentLayers([]string{"-e", "../envs/dev.postman_environment.json", "--environment", "../envs/staging.postman_environment.json", "-e", "local.json"}, discovered)
+
+			// THEN - Each file appears once, in order
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"-e", "../envs/dev.postman_environment.json", "-e", "../envs/staging.postman_environment.json", "-e", "local.json"}, got)
+		})
+
+		t.Run("applyProjectDefaults", func(t *testing.T) {
+			// GIVEN
+			tests := []struct {
+				name  string
+				flags []string
+				want  []string
+			}{
+				{"adds environment and reporters", []string{"--bail"}, []string{"--bail", "-e", "../envs/staging.postman_environment.json", "--reporters", "cli,json"}},
+				{"keeps command line values", []string{"-e", "prod.json", "--reporters", "cli"}, []string{"-e", "prod.json", "--reporters", "cli"}},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					// WHEN
+					got := applyProjectDefaults(tt.flags, discovered)
+
+					// THEN
+					assert.Equal(t, tt.want, got, "should apply plaintest.yaml defaults")
+				})
+			}
+		})
+	})
+}
+
+func TestProjectRoot(t *testing.T) {
+	withTempDir(t, func(tempDir string) {
+		defer func() { projectConfig, projectDir = project.Default(), "" }()
+
+		// SETUP - A project without plaintest.yaml and a second project next to it
+		for _, file := range []string{
+			"api/collections/users.postman_collection.json",
+			"other/collections/orders.postman_collection.json",
+		} {
+			assert.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+			assert.NoError(t, os.WriteFile(file, []byte("{}"), 0644))
+		}
+		assert.NoError(t, os.MkdirAll("api/scripts/users", 0755))
+		assert.NoError(t, os.Chdir("api/scripts/users"))
+
+		// WHEN - Run from a subdirectory of the project
+		assert.NoError(t, loadProjectConfig())
+
+		// THEN - The collections/ directory marks the root
+		assert.Equal(t, map[string]string{"users": "../../collections/users.postman_collection.json"}, discoverAllFiles().Collections)
+
+		// WHEN - --project points at the other project
+		projectDir = "../../../other"
+		assert.NoError(t, loadProjectConfig())
+
+		// THEN
+		assert.Equal(t, map[string]string{"orders": "../../../other/collections/orders.postman_collection.json"}, discoverAllFiles().Collections)
+
+		// WHEN - --project points nowhere
+		projectDir = "../../../missing"
+
+		// THEN
+		assert.Error(t, loadProjectConfig(), "a missing project directory should be an error")
+	})
+}
+
+func TestRunArguments(t *testing.T) {
+	// GIVEN
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"run first", []string{"run", "--test", "smoke"}, []string{"--test", "smoke"}},
+		{"project before run", []string{"--project", "../api", "run", "--test", "smoke"}, []string{"--test", "smoke"}},
+		{"project with equals", []string{"--project=../api", "run", "--bail"}, []string{"--bail"}},
+		{"no subcommand", []string{"--project", "../api"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// WHEN
+			got := runArguments(tt.args)
+
+			// THEN
+			assert.Equal(t, tt.want, got, "should return the arguments after run")
+		})
+	}
+}
+
 func TestParseLinkSpec(t *testing.T) {
 	// GIVEN
 	tests := []struct {
@@ -202,6 +475,14 @@ func TestParseArguments(t *testing.T) {
 		{"Newman flags only", []string{"--verbose", "-d", "data.csv"}, []string{"--verbose", "-d", "data.csv"}},
 		{"skip setup and test flags", []string{"--setup", "auth", "--test", "smoke", "--verbose"}, []string{"--verbose"}},
 		{"skip row selection", []string{"-r", "2-5", "--verbose"}, []string{"--verbose"}},
+		{"skip engine selection", []string{"--engine", "native", "--engine=newman", "--bail"}, []string{"--bail"}},
+		{"skip expectation opt-out", []string{"--no-expect", "--bail"}, []string{"--bail"}},
+		{"skip junit output", []string{"--junit", "reports/junit.xml", "--junit=out.xml", "--bail"}, []string{"--bail"}},
+		{"skip suite selection", []string{"--suite", "regression", "--suite=smoke", "--bail"}, []string{"--bail"}},
+		{"skip project root", []string{"--project", "../api", "--project=../api", "--bail"}, []string{"--bail"}},
+		{"skip parallel", []string{"--parallel", "3", "--parallel=2", "--bail"}, []string{"--bail"}},
+		{"skip shards", []string{"--shards", "4", "--shards=2", "--bail"}, []string{"--bail"}},
+		{"skip retries", []string{"--retry-failed", "2", "--retry-failed=1", "--bail"}, []string{"--bail"}},
 		{"mixed flags", []string{"--setup", "auth.Login", "-d", "data.csv", "--test", "api_tests", "--verbose"}, []string{"-d", "data.csv", "--verbose"}},
 	}
 
@@ -217,7 +498,706 @@ func TestParseArguments(t *testing.T) {
 	}
 }
 
+func TestNewRunner(t *testing.T) {
+	// GIVEN
+	tests := []struct {
+		engine    string
+		wantError bool
+	}{
+		{"", false},
+		{"newman", false},
+		{"native", false},
+		{"k6", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.engine, func(t *testing.T) {
+			// WHEN
+			service, err := newRunner(tt.engine)
+
+			// THEN
+			if tt.wantError {
+				assert.Error(t, err, "unknown engines should be rejected")
+				return
+			}
+			assert.NoError(t, err, "known engines should be accepted")
+			assert.NotNil(t, service, "should return a runner")
+		})
+	}
+
+	t.Run("native engine needs no installation", func(t *testing.T) {
+		// WHEN
+		service, _ := newRunner("native")
+
+		// THEN
+		assert.True(t, service.IsInstalled(), "native engine should always be available")
+	})
+}
+
+// fakeRunner records the links it runs. Each link appends its collection to
+// the environment file it was given, so shared files would show up, and
+// writes a JSON report with one execution per data row, named after the row
+type fakeRunner struct {
+	mu         sync.Mutex
+	running    int
+	maxRunning int
+	envs       map[string]string
+	fail       string
+	// failing counts how many more runs a data row fails; -1 fails forever
+	failing map[string]int
+	runs    int
+	// hang blocks the named collection until its context ends
+	hang string
+	// flags are the flags of the last run
+	flags []string
+}
+
+func (f *fakeRunner) RunWithFlags(ctx context.Context, collection string, flags []string) (*newman.Result, error) {
+	f.mu.Lock()
+	f.running++
+	if f.running > f.maxRunning {
+		f.maxRunning = f.running
+	}
+	f.flags = flags
+	f.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+
+	name := strings.TrimSuffix(filepath.Base(collection), ".postman_collection.json")
+	if name == f.hang {
+		<-ctx.Done()
+		f.mu.Lock()
+		f.running--
+		f.mu.Unlock()
+		return &newman.Result{ExitCode: -1, Output: name + " stopped"}, context.Cause(ctx)
+	}
+	for i, flag := range flags {
+		if flag == "-e" && i+1 < len(flags) {
+			data, _ := os.ReadFile(flags[i+1])
+			_ = os.WriteFile(flags[i+1], append(data, []byte(name)...), 0644)
+			f.mu.Lock()
+			f.envs[name] = string(data)
+			f.mu.Unlock()
+		}
+	}
+	if reportPath := flagValue(flags, "--reporter-json-export"); reportPath != "" {
+		r := &report.Report{}
+		data, _ := os.ReadFile(flagValue(flags, "-d"))
+		rows := strings.Split(strings.TrimSpace(string(data)), "\n")
+		f.mu.Lock()
+		f.runs++
+		for i, row := range rows[1:] {
+			exec := report.Execution{Cursor: report.Cursor{Iteration: i}, Item: report.Item{Name: row}}
+			assertion := report.Assertion{Assertion: "ok"}
+			if f.failing[row] != 0 {
+				if f.failing[row] > 0 {
+					f.failing[row]--
+				}
+				assertion.Error = &report.Error{Name: "AssertionError", Message: row + " failed", Test: "ok"}
+				r.Run.Failures = append(r.Run.Failures, report.Failure{Error: *assertion.Error, At: "assertion:0 in test-script", Source: exec.Item, Cursor: exec.Cursor})
+			}
+			exec.Assertions = []report.Assertion{assertion}
+			r.Run.Executions = append(r.Run.Executions, exec)
+		}
+		f.mu.Unlock()
+		r.Run.Stats.Iterations.Total = len(rows) - 1
+		r.Run.Stats.Requests.Total = len(rows) - 1
+		r.Run.Stats.Assertions = report.Count{Total: len(rows) - 1, Failed: len(r.Run.Failures)}
+		_ = r.Save(reportPath)
+	}
+
+	f.mu.Lock()
+	f.running--
+	f.mu.Unlock()
+	if name == f.fail {
+		return &newman.Result{ExitCode: 1, Output: name + " failed"}, nil
+	}
+	return &newman.Result{Success: true}, nil
+}
+
+func (f *fakeRunner) RunWithEnvironmentExport(ctx context.Context, collection string, flags []string, exportEnvPath string) (*newman.Result, error) {
+	result, err := f.RunWithFlags(ctx, collection, flags)
+	_ = os.WriteFile(exportEnvPath, []byte("exported by "+flagValue(flags, "-d")), 0644)
+	return result, err
+}
+
+func (f *fakeRunner) IsInstalled() bool { return true }
+
+func TestMaskedRunner(t *testing.T) {
+	withTempDir(t, func(tempDir string) {
+		// GIVEN - A runner whose output shows a secret
+		service := maskedRunner{
+			runner: &fakeRunner{envs: map[string]string{}, fail: "s3cr3t-token"},
+			masker: secrets.NewMasker([]string{"s3cr3t-token"}),
+		}
+
+		// WHEN
+		result, err := service.RunWithFlags(context.Background(), "s3cr3t-token.postman_collection.json", nil)
+
+		// THEN
+		assert.NoError(t, err)
+		assert.Equal(t, "**** failed", result.Output, "secrets should be masked in output")
+		assert.True(t, service.IsInstalled(), "other methods should pass through")
+	})
+}
+
+func TestPinnedVariablesNotRecorded(t *testing.T) {
+	withTempDir(t, func(tempDir string) {
+		defer func() { pinnedVars, pinnedVarFiles = nil, nil }()
+
+		// SETUP - A run pinning a token with --var and a key with --var-file
+		assert.NoError(t, os.WriteFile("env.json", []byte(`{"values": []}`), 0o644))
+		assert.NoError(t, os.WriteFile("demo.env", []byte("api_key=file-s3cr3t\n"), 0o644))
+		pinnedVars = []string{"token=var-s3cr3t"}
+		pinnedVarFiles = []string{"demo.env"}
+		config := DiscoveryConfig{Collections: map[string]string{"users": "users.postman_collection.json"}}
+		phases := []ExecutionPhase{{Phase: "test", Links: []LinkSpec{newLinkSpec("users")}}}
+		service := &fakeRunner{envs: map[string]string{}}
+
+		// WHEN
+		exitCode := executeRun(context.Background(), phases, &config, []string{"-e", "env.json"}, service)
+
+		// THEN - The link ran with the pinned values
+		assert.Equal(t, 0, exitCode)
+		assert.Equal(t, map[string]string{"api_key": "file-s3cr3t", "token": "var-s3cr3t"}, pinnedVariables(service.flags))
+
+		// THEN - The saved manifest names them without their values
+		data, err := os.ReadFile(manifest.Path)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), "var-s3cr3t")
+		assert.NotContains(t, string(data), "file-s3cr3t")
+		last, err := manifest.Load(manifest.Path)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"token"}, last.Vars)
+		assert.Equal(t, []string{filepath.Join(tempDir, "demo.env")}, last.VarFiles)
+
+		// WHEN - rerun is not given the --var again
+		pinnedVars, pinnedVarFiles = nil, nil
+		err = restorePinnedVariables(last)
+
+		// THEN
+		assert.ErrorContains(t, err, "pass --var token=<value> again")
+
+		// WHEN - It is
+		pinnedVars = []string{"token=new-s3cr3t"}
+		err = restorePinnedVariables(last)
+
+		// THEN - The --var-file is read again
+		assert.NoError(t, err)
+		assert.Equal(t, last.VarFiles, pinnedVarFiles)
+	})
+}
+
+func TestRunLinksParallel(t *testing.T) {
+	withTempDir(t, func(tempDir string) {
+		defer func() { parallelLinks = 1 }()
+
+		// SETUP - Four test links after a setup link that exported its environment
+		config := DiscoveryConfig{Collections: map[string]string{}}
+		phase := ExecutionPhase{Phase: "test"}
+		for _, name := range []string{"a", "b", "c", "d"} {
+			config.Collections[name] = name + ".postman_collection.json"
+			phase.Links = append(phase.Links, newLinkSpec(name))
+		}
+		setupEnv := filepath.Join(tempDir, "setup.json")
+		assert.NoError(t, os.WriteFile(setupEnv, []byte("setup:"), 0644))
+
+		service := &fakeRunner{envs: map[string]string{}, fail: "c"}
+		parallelLinks = 2
+
+		// WHEN
+		results := runLinksParallel(context.Background(), phase, 1, 5, &config, []string{"-e", "env.json"}, service, setupEnv)
+
+		// THEN - At most two links ran at once, each from its own copy of the setup environment
+		var links []string
+		for _, result := range results {
+			links = append(links, result.Summary.Link)
+			assert.Equal(t, result.Spec.Collection == "c", result.Err != nil, "only the failing link should have an error")
+		}
+		assert.Equal(t, []string{"a", "b", "c", "d"}, links, "results should be in link order")
+		assert.Equal(t, 2, service.maxRunning, "should run up to --parallel links at once")
+		assert.Equal(t, map[string]string{"a": "setup:", "b": "setup:", "c": "setup:", "d": "setup:"}, service.envs)
+
+		data, err := os.ReadFile(setupEnv)
+		assert.NoError(t, err)
+		assert.Equal(t, "setup:", string(data), "the shared environment should not change")
+	})
+}
+
+func TestExecuteLinkSpec_Timeout(t *testing.T) {
+	withTempDir(t, func(tempDir string) {
+		defer func() { linkTimeout = 0 }()
+
+		// SETUP - A link that hangs until it is stopped
+		config := DiscoveryConfig{Collections: map[string]string{"slow": "slow.postman_collection.json"}}
+		service := &fakeRunner{envs: map[string]string{}, hang: "slow"}
+		linkTimeout = 50 * time.Millisecond
+		tempEnvFile := ""
+
+		// WHEN
+		var out strings.Builder
+		summary, err := executeLinkSpec(context.Background(), newLinkSpec("slow"), "test", 1, 1,
+			&config, nil, service, &tempEnvFile, &out)
+
+		// THEN - The link timed out and its summary says it stopped
+		assert.ErrorIs(t, err, errTimedOut)
+		assert.Equal(t, exitTimedOut, exitCodeOf(err))
+		if assert.NotNil(t, summary) {
+			assert.Equal(t, "slow", summary.Link)
+			assert.Contains(t, summary.Stopped, "timed out after 50ms")
+			assert.False(t, summary.Passed(), "a stopped link should not pass")
+		}
+	})
+}
+
+func TestExecuteLinkSpec_NewmanStopped(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Newman is killed at once on Windows")
+	}
+	withTempDir(t, func(tempDir string) {
+		defer func() { linkTimeout = 0 }()
+
+		// SETUP - A slow Newman which, like Newman, exits on SIGTERM without a report
+		script := "#!/bin/sh\nsleep 30 &\nwait\n"
+		binDir := filepath.Join(tempDir, "bin")
+		assert.NoError(t, os.Mkdir(binDir, 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(binDir, "newman"), []byte(script), 0755))
+		t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+		config := DiscoveryConfig{Collections: map[string]string{"users": "users.postman_collection.json"}}
+		linkTimeout = 300 * time.Millisecond
+		tempEnvFile := ""
+
+		// WHEN
+		var out strings.Builder
+		started := time.Now()
+		summary, err := executeLinkSpec(context.Background(), newLinkSpec("users"), "test", 1, 1,
+			&config, nil, newman.NewService(), &tempEnvFile, &out)
+
+		// THEN - The link timed out at once and its summary holds only the reason
+		assert.ErrorIs(t, err, errTimedOut)
+		assert.Less(t, time.Since(started), 3*time.Second, "a Newman that exits on SIGTERM should not wait to be killed")
+		if assert.NotNil(t, summary) {
+			assert.Contains(t, summary.Stopped, "timed out after 300ms")
+			assert.Zero(t, summary.Requests)
+		}
+	})
+}
+
+func TestExitCodeOf(t *testing.T) {
+	// GIVEN - Links that failed, timed out and were interrupted
+	failed := errors.New("execution failed")
+	timedOut := fmt.Errorf("execution failed: link %w after 1m0s", errTimedOut)
+	interrupted := fmt.Errorf("execution failed: %w by interrupt", errInterrupted)
+
+	// THEN - Each gets its own exit code, and an interrupt outranks a timeout
+	assert.Equal(t, exitFailed, exitCodeOf(failed))
+	assert.Equal(t, exitTimedOut, exitCodeOf(timedOut))
+	assert.Equal(t, exitInterrupted, exitCodeOf(interrupted))
+	assert.Equal(t, exitInterrupted, max(exitCodeOf(timedOut), exitCodeOf(interrupted)))
+}
+
+func TestRunShards(t *testing.T) {
+	withTempDir(t, func(tempDir string) {
+		defer func() { shardCount = 1 }()
+
+		// SETUP - Five data rows split over two shards
+		assert.NoError(t, os.WriteFile("users.csv", []byte("name\nr1\nr2\nr3\nr4\nr5\n"), 0644))
+		assert.NoError(t, os.WriteFile("env.json", []byte("setup:"), 0644))
+		service := &fakeRunner{envs: map[string]string{}}
+		shardCount = 2
+		reportPath := filepath.Join(tempDir, "report.json")
+		exportEnv := filepath.Join(tempDir, "exported.json")
+		flags := []string{"-d", "users.csv", "-e", "env.json", "--reporter-json-export", reportPath}
+
+		// WHEN
+		var out strings.Builder
+		result, err := runShards(context.Background(), &out, service, "users.postman_collection.json", flags, reportPath, exportEnv)
+
+		// THEN - Both shards ran at once against the same environment
+		assert.NoError(t, err)
+		assert.True(t, result.Success)
+		assert.Equal(t, 2, service.maxRunning, "shards should run concurrently")
+		assert.Contains(t, out.String(), "Running 2 shards of users.csv")
+
+		// THEN - The merged report is in the original row order
+		merged, err := report.Load(reportPath)
+		assert.NoError(t, err)
+		var names []string
+		var iterations []int
+		for _, exec := range merged.Run.Executions {
+			names = append(names, exec.Item.Name)
+			iterations = append(iterations, exec.Cursor.Iteration)
+		}
+		assert.Equal(t, []string{"r1", "r2", "r3", "r4", "r5"}, names)
+		assert.Equal(t, []int{0, 1, 2, 3, 4}, iterations)
+		assert.Equal(t, 5, merged.Run.Stats.Iterations.Total)
+
+		// THEN - The last shard's environment is exported for the next link
+		data, err := os.ReadFile(exportEnv)
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), "exported by", "the last shard should export the environment")
+
+		// WHEN - The link writes an HTML report
+		generatedReports = []string{"reports/users.json", "reports/users.html"}
+		defer func() { generatedReports = nil }()
+		htmlFlags := append(flags, "--reporter-htmlextra-export", "reports/users.html")
+		_, err = runShards(context.Background(), &out, service, "users.postman_collection.json", htmlFlags, reportPath, "")
+
+		// THEN - Each shard's HTML report is listed in place of the link's, which is never written
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"reports/users.json", "reports/users_shard1.html", "reports/users_shard2.html"}, generatedReports)
+
+		// WHEN - The data file is not a CSV
+		_, err = runShards(context.Background(), &out, service, "users.postman_collection.json", []string{"-d", "users.json"}, reportPath, "")
+
+		// THEN
+		assert.Error(t, err, "only CSV data files can be sharded")
+	})
+}
+
+func TestRetryFailedRows(t *testing.T) {
+	withTempDir(t, func(tempDir string) {
+		defer func() { retryFailed = 0 }()
+
+		// SETUP - r2 fails twice then passes, r4 always fails
+		assert.NoError(t, os.WriteFile("users.csv", []byte("name\nr1\nr2\nr3\nr4\n"), 0644))
+		service := &fakeRunner{envs: map[string]string{}, failing: map[string]int{"r2": 2, "r4": -1}}
+		reportPath := filepath.Join(tempDir, "report.json")
+		flags := []string{"-d", "users.csv", "--reporter-json-export", reportPath}
+		_, _ = service.RunWithFlags(context.Background(), "users.postman_collection.json", flags)
+		retryFailed = 3
+
+		// WHEN
+		var out strings.Builder
+		flaky, failing, err := retryFailedRows(context.Background(), &out, service, "users.postman_collection.json", flags, nil, reportPath, "")
+
+		// THEN - r2 passed on the second retry, r4 was retried every time
+		assert.NoError(t, err)
+		assert.True(t, failing, "r4 should still fail")
+		assert.Equal(t, map[int]int{1: 2}, flaky)
+		assert.Equal(t, 4, service.runs, "one run plus three retries")
+		assert.Contains(t, out.String(), "Retrying failed rows 2,4 (attempt 1/3)")
+		assert.Contains(t, out.String(), "Retrying failed rows 4 (attempt 3/3)")
+
+		// THEN - The report holds the final attempt of each row
+		final, err := report.Load(reportPath)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{3}, final.FailedIterations())
+		assert.Equal(t, report.Count{Total: 4, Failed: 1}, final.Run.Stats.Assertions)
+
+		// WHEN - Every failed row passes on retry
+		service.failing = map[string]int{"r1": 1}
+		_, _ = service.RunWithFlags(context.Background(), "users.postman_collection.json", flags)
+		flaky, failing, err = retryFailedRows(context.Background(), &out, service, "users.postman_collection.json", flags, nil, reportPath, "")
+
+		// THEN
+		assert.NoError(t, err)
+		assert.False(t, failing, "no row should fail after retry")
+		assert.Equal(t, map[int]int{0: 1}, flaky)
+
+		// WHEN - The run stops before the retry finishes
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		service.failing = map[string]int{"r1": 1}
+		_, _ = service.RunWithFlags(context.Background(), "users.postman_collection.json", flags)
+		flaky, failing, err = retryFailedRows(ctx, &out, service, "users.postman_collection.json", flags, nil, reportPath, "")
+
+		// THEN - The stopped retry does not count
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.True(t, failing)
+		assert.Empty(t, flaky)
+
+		// WHEN - The retry cannot run at all
+		t.Setenv("PATH", "")
+		_, _, err = retryFailedRows(context.Background(), &out, newman.NewService(), "users.postman_collection.json", flags, nil, reportPath, "")
+
+		// THEN - Its error is returned for the attempt
+		assert.ErrorContains(t, err, "retry 1: ")
+		assert.ErrorIs(t, err, exec.ErrNotFound)
+	})
+}
+
+func TestExecuteLinkSpec_RetryRowSelection(t *testing.T) {
+	withTempDir(t, func(tempDir string) {
+		defer func() { retryFailed = 0 }()
+
+		// SETUP - Rows 3 and 5 of the data file are selected; row 3 fails once
+		assert.NoError(t, os.WriteFile("users.csv", []byte("name\nr1\nr2\nr3\nr4\nr5\n"), 0644))
+		config := DiscoveryConfig{Collections: map[string]string{"users": "users.postman_collection.json"}}
+		service := &fakeRunner{envs: map[string]string{}, failing: map[string]int{"r3": 1}}
+		retryFailed = 2
+		tempEnvFile := ""
+		link := newLinkSpec("users")
+		link.Rows = "3,5"
+
+		// WHEN
+		var out strings.Builder
+		summary, err := executeLinkSpec(context.Background(), link, "test", 1, 1,
+			&config, []string{"-d", "users.csv"}, service, &tempEnvFile, &out)
+
+		// THEN - The retried and flaky row is row 3 of the data file, not the first selected row
+		assert.NoError(t, err)
+		assert.Contains(t, out.String(), "Retrying failed rows 3 (attempt 1/2)")
+		if assert.NotNil(t, summary) {
+			assert.Equal(t, []report.FlakyRow{{Row: 3, Attempt: 1}}, summary.Flaky)
+		}
+	})
+}
+
+func TestInjectExpectations(t *testing.T) {
+	withTempDir(t, func(tempDir string) {
+		// SETUP
+		collectionPath := "users.postman_collection.json"
+		err := os.WriteFile(collectionPath, []byte(`{"info": {"name": "Users"}, "item": [{"name": "Get User", "request": "https://example.com"}]}`), 0o644)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile("plain.csv", []byte("test_id\nTC_001\n"), 0o644))
+		assert.NoError(t, os.WriteFile("expected.csv", []byte("test_id,expected_status\nTC_001,200\n"), 0o644))
+
+		t.Run("no data file", func(t *testing.T) {
+			// WHEN
+			injected, err := injectExpectations(io.Discard, collectionPath, []string{"--verbose"})
+
+			// THEN
+			assert.NoError(t, err)
+			assert.Empty(t, injected, "nothing to assert without a data file")
+		})
+
+		t.Run("no expected columns", func(t *testing.T) {
+			// WHEN
+			injected, err := injectExpectations(io.Discard, collectionPath, []string{"-d", "plain.csv"})
+
+			// THEN
+			assert.NoError(t, err)
+			assert.Empty(t, injected, "nothing to assert without expected_* columns")
+		})
+
+		t.Run("expected columns", func(t *testing.T) {
+			// WHEN
+			injected, err := injectExpectations(io.Discard, collectionPath, []string{"-d", "expected.csv"})
+			defer cleanupTempFile(injected)
+
+			// THEN
+			assert.NoError(t, err)
+			data, readErr := os.ReadFile(injected)
+			assert.NoError(t, readErr, "should write a collection copy")
+			assert.Contains(t, string(data), "expected_status", "copy should assert the expected_status column")
+		})
+	})
+}
+
+func TestGenerateData(t *testing.T) {
+	withTempDir(t, func(tempDir string) {
+		// SETUP
+		defer func() { dataSeed = 0 }()
+		dataSeed = 42
+		assert.NoError(t, os.WriteFile("plain.csv", []byte("test_id\nTC_001\n"), 0o644))
+		assert.NoError(t, os.WriteFile("users.csv", []byte("test_id,input_email\nTC_001,{{$fake.email}}\nTC_002,user{{$seq}}@test.com\n"), 0o644))
+
+		// WHEN - The data has no generator expressions
+		generated, err := generateData("plain.csv", "plain")
+
+		// THEN
+		assert.NoError(t, err)
+		assert.Empty(t, generated, "plain data is used as it is")
+
+		// WHEN
+		generated, err = generateData("users.csv", "users")
+
+		// THEN - The expanded copy is kept in the reports directory
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(generated, filepath.Join("reports", "users_")) && strings.HasSuffix(generated, "_data.csv"), generated)
+		first, err := os.ReadFile(generated)
+		assert.NoError(t, err)
+		assert.Regexp(t, `^test_id,input_email\nTC_001,[a-z.]+\d{6}@example\.com\nTC_002,user2@test\.com\n$`, string(first))
+
+		// WHEN - The same seed generates again
+		assert.NoError(t, os.Remove(generated))
+		generated, err = generateData("users.csv", "users")
+
+		// THEN
+		assert.NoError(t, err)
+		second, _ := os.ReadFile(generated)
+		assert.Equal(t, string(first), string(second), "the same seed should give the same data")
+	})
+}
+
+func TestPinnedVariables(t *testing.T) {
+	withTempDir(t, func(tempDir string) {
+		// SETUP
+		assert.NoError(t, os.WriteFile("demo.env", []byte("# demo\nbase_url=https://demo.example.com\ntenant=acme\n"), 0o644))
+
+		// WHEN - A --var overrides the same key of a --var-file
+		flags, err := applyPinnedVariables([]string{"-e", "dev.json"}, []string{"demo.env"}, []string{"tenant=globex", "empty="})
+
+		// THEN - Each variable becomes an --env-var flag, later ones winning
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"-e", "dev.json",
+			"--env-var", "base_url=https://demo.example.com", "--env-var", "tenant=acme",
+			"--env-var", "tenant=globex", "--env-var", "empty="}, flags)
+		assert.Equal(t, map[string]string{"base_url": "https://demo.example.com", "tenant": "globex", "empty": ""}, pinnedVariables(flags))
+
+		// WHEN
+		_, err = applyPinnedVariables(nil, nil, []string{"tenant"})
+
+		// THEN
+		assert.ErrorContains(t, err, "use key=value")
+
+		// WHEN - A setup link exported a new value for a pinned variable
+		exported := filepath.Join(tempDir, "exported.json")
+		assert.NoError(t, os.WriteFile(exported, []byte(`{"values": [
+			{"key": "tenant", "value": "initech"},
+			{"key": "base_url", "value": "https://demo.example.com"},
+			{"key": "token", "value": "abc"}
+		]}`), 0o644))
+		var out strings.Builder
+		warnPinnedOverwrites(&out, newLinkSpec("auth"), "setup", exported, flags)
+
+		// THEN - Only the changed variable is reported
+		assert.Equal(t, "Warning: setup link auth set pinned variable tenant; later links keep the --var value\n", out.String())
+	})
+}
+
+func TestEnvironmentSnapshots(t *testing.T) {
+	withTempDir(t, func(tempDir string) {
+		defer func() { envSnapshotDir, envSnapshotMasker = "", nil }()
+
+		// SETUP - The environments a run started with and its links exported
+		start := filepath.Join(tempDir, "start.json")
+		assert.NoError(t, os.WriteFile(start, []byte(`{"values": [{"key": "auth_token", "value": ""}, {"key": "password", "value": "hunter22"}]}`), 0o644))
+		auth := filepath.Join(tempDir, "auth.json")
+		assert.NoError(t, os.WriteFile(auth, []byte(`{"values": [{"key": "auth_token", "value": "abc"}, {"key": "password", "value": "hunter22"}, {"key": "user_id", "value": 7}]}`), 0o644))
+		empty := filepath.Join(tempDir, "empty.json")
+		assert.NoError(t, os.WriteFile(empty, nil, 0o644))
+
+		dir, err := createSnapshotDir()
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(dir, filepath.Join("reports", "run_")), dir)
+		second, err := createSnapshotDir()
+		assert.NoError(t, err)
+		assert.NotEqual(t, dir, second, "runs in the same second should get directories of their own")
+		envSnapshotDir, envSnapshotMasker = dir, secrets.NewMasker([]string{"hunter22"})
+
+		// WHEN
+		for i, file := range []string{start, auth, auth} {
+			_, err := saveEnvironmentSnapshot([]int{0, 2, 10}[i], []string{"start", "auth", "users"}[i], file)
+			assert.NoError(t, err)
+		}
+		path, err := saveEnvironmentSnapshot(11, "crashed", empty)
+
+		// THEN - Snapshots are listed in link order, secrets masked, empty exports skipped
+		assert.NoError(t, err)
+		assert.Empty(t, path)
+		snapshots, err := environmentSnapshots(dir)
+		assert.NoError(t, err)
+		var names []string
+		for _, snapshot := range snapshots {
+			names = append(names, filepath.Base(snapshot))
+		}
+		assert.Equal(t, []string{"env_0_start.json", "env_2_auth.json", "env_10_users.json"}, names)
+		data, _ := os.ReadFile(snapshots[0])
+		assert.NotContains(t, string(data), "hunter22")
+
+		// WHEN
+		var out strings.Builder
+		assert.NoError(t, printEnvironmentDiff(&out, snapshots[0], snapshots[1]))
+		assert.NoError(t, printEnvironmentDiff(&out, snapshots[1], snapshots[2]))
+		assert.NoError(t, printEnvironmentDiff(&out, snapshots[2], snapshots[0]))
+
+		// THEN
+		assert.Equal(t, `env_0_start.json -> env_2_auth.json
+  ~ auth_token: "" -> "abc"
+  + user_id = "7"
+env_2_auth.json -> env_10_users.json
+  No changes
+env_10_users.json -> env_0_start.json
+  ~ auth_token: "abc" -> ""
+  - user_id (was "7")
+`, out.String())
+	})
+}
+
+func TestStartTempDir(t *testing.T) {
+	withTempDir(t, func(tempDir string) {
+		t.Setenv("TMPDIR", tempDir)
+		defer func() { keepTemp = false }()
+
+		// WHEN
+		removeTempDir, err := startTempDir()
+		assert.NoError(t, err)
+		envFile, err := createTempEnvironmentFile()
+		assert.NoError(t, err)
+		reportFile, err := createTempReportFile()
+		assert.NoError(t, err)
+
+		// THEN - Temporary files go to a private directory of the run
+		runDir := filepath.Dir(envFile)
+		assert.True(t, strings.HasPrefix(filepath.Base(runDir), "plaintest_run_"), runDir)
+		assert.Equal(t, runDir, filepath.Dir(reportFile))
+
+		// WHEN - The run ends
+		removeTempDir()
+
+		// THEN - The directory is gone with its files
+		assert.NoDirExists(t, runDir)
+
+		// WHEN - The run keeps its temporary files
+		keepTemp = true
+		removeTempDir, err = startTempDir()
+		assert.NoError(t, err)
+		envFile, err = createTempEnvironmentFile()
+		assert.NoError(t, err)
+		removeTempDir()
+
+		// THEN
+		assert.FileExists(t, envFile)
+		assert.NoError(t, os.RemoveAll(filepath.Dir(envFile)))
+
+		// WHEN - The run's directory is removed under it
+		keepTemp = false
+		removeTempDir, err = startTempDir()
+		assert.NoError(t, err)
+		assert.NoError(t, os.RemoveAll(tempdir.Dir()))
+		_, reportFile, _, err = addJSONExport([]string{"--bail"})
+		removeTempDir()
+
+		// THEN - The error is returned rather than a guessable path used
+		assert.Error(t, err)
+		assert.Empty(t, reportFile)
+	})
+}
+
 func TestFlagManipulation(t *testing.T) {
+	t.Run("addJSONExport", func(t *testing.T) {
+		// GIVEN
+		tests := []struct {
+			name      string
+			flags     []string
+			wantFlags []string
+			wantTemp  bool
+		}{
+			{"no reporters", []string{"--bail"}, []string{"--bail", "--reporters", "cli,json", "--reporter-json-export"}, true},
+			{"reporters without json", []string{"--reporters", "cli"}, []string{"--reporters", "cli,json", "--reporter-json-export"}, true},
+			{"existing export", []string{"--reporters", "cli,json", "--reporter-json-export", "reports/a.json"}, []string{"--reporters", "cli,json", "--reporter-json-export", "reports/a.json"}, false},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				// WHEN
+				got, path, temp, err := addJSONExport(tt.flags)
+
+				// THEN
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantTemp, temp, "only added exports should be temporary")
+				assert.Equal(t, path, got[len(got)-1], "report path should be the export flag value")
+				if temp {
+					assert.Contains(t, path, "plaintest_report_", "temporary report should live in the temp dir")
+					got = got[:len(got)-1]
+				}
+				assert.Equal(t, tt.wantFlags, got, "should ask for a JSON export")
+			})
+		}
+	})
+
 	t.Run("extractCSVFromFlags", func(t *testing.T) {
 		// GIVEN
 		tests := []struct {
@@ -395,6 +1375,18 @@ func TestReportGeneration(t *testing.T) {
 		assert.Contains(t, result, "--reporter-htmlextra-export", "should add HTML export flag")
 	})
 
+	t.Run("addExportPaths keeps links of one collection apart", func(t *testing.T) {
+		defer func() { generatedReports = nil }()
+
+		// WHEN
+		first := addExportPaths(nil, "users")
+		second := addExportPaths(nil, "users")
+
+		// THEN
+		assert.NotEqual(t, first[1], second[1], "JSON report paths should differ")
+		assert.NotEqual(t, first[3], second[3], "HTML report paths should differ")
+	})
+
 	t.Run("ensureJSONReporter", func(t *testing.T) {
 		// GIVEN
 		tests := []struct {
@@ -418,3 +1410,57 @@ func TestReportGeneration(t *testing.T) {
 		}
 	})
 }
+
+func TestRecordRun(t *testing.T) {
+	withTempDir(t, func(tempDir string) {
+		defer func() { rowSelection, shardCount, retryFailed = "", 1, 0 }()
+
+		// SETUP - A sharded run of rows 2-5 where rows 3 and 5 of the data file
+		// failed, and the link after the failure never ran
+		assert.NoError(t, os.WriteFile("users.csv", []byte("name\nr1\nr2\nr3\nr4\nr5\n"), 0644))
+		rowSelection = "2-5"
+		shardCount, retryFailed = 2, 1
+		phases := []ExecutionPhase{
+			{Phase: "setup", Links: []LinkSpec{newLinkSpec("auth")}},
+			{Phase: "test", Links: []LinkSpec{newLinkSpec("users", "Create User", "Get User"), newLinkSpec("orders")}},
+		}
+		failed := &report.Summary{Failures: []report.FailureDetail{
+			{Iteration: 2, Item: "Get User"},
+			{Iteration: 4, Item: "Get User"},
+			{Iteration: 4, Item: "Create User"},
+		}}
+		results := []linkResult{
+			{Phase: "setup", Spec: phases[0].Links[0], Summary: &report.Summary{}},
+			{Phase: "test", Spec: phases[1].Links[0], Summary: failed, Err: assert.AnError},
+		}
+
+		// WHEN
+		run := recordRun(time.Now(), phases, results, []string{"-e", "dev.json", "-d", "users.csv"}, "users.csv")
+
+		// THEN - Failed iterations are rows of the original data file
+		assert.Equal(t, filepath.Join(tempDir, "users.csv"), run.Data, "paths should be absolute")
+		assert.Equal(t, []int{1, 2, 1}, []int{run.Parallel, run.Shards, run.RetryFailed}, "run options should be recorded")
+		failures := []manifest.Failure{{Row: 3, Item: "Get User"}, {Row: 5, Item: "Get User"}, {Row: 5, Item: "Create User"}}
+		assert.Equal(t, []manifest.Link{
+			{Phase: "setup", Collection: "auth", Items: []string{}, Status: manifest.StatusPassed},
+			{Phase: "test", Collection: "users", Items: []string{"Create User", "Get User"}, Rows: "2-5",
+				Status: manifest.StatusFailed, Failures: failures},
+			{Phase: "test", Collection: "orders", Items: []string{}, Rows: "2-5", Status: manifest.StatusSkipped},
+		}, run.Links)
+
+		// WHEN - The failures are turned back into phases
+		links, err := run.Failed()
+		assert.NoError(t, err)
+		rerun := phasesFromManifest(links)
+
+		// THEN - Setup runs again, then each failed request on the rows it failed on
+		assert.Equal(t, []ExecutionPhase{
+			{Phase: "setup", Links: []LinkSpec{newLinkSpec("auth")}},
+			{Phase: "test", Links: []LinkSpec{
+				{Collection: "users", Items: []string{"Get User"}, Rows: "3,5"},
+				{Collection: "users", Items: []string{"Create User"}, Rows: "5"},
+				{Collection: "orders", Items: []string{}, Rows: "2-5"},
+			}},
+		}, rerun)
+	})
+}
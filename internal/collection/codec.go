@@ -0,0 +1,184 @@
+package collection
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// decodeObject decodes the JSON object in data into v, a pointer to a struct
+// without JSON methods. It returns the members the struct does not model and
+// the names of all members the object had.
+func decodeObject(data []byte, v any) (map[string]json.RawMessage, map[string]bool, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, nil, err
+	}
+
+	known := fieldNames(reflect.TypeOf(v).Elem())
+	present := make(map[string]bool, len(members))
+	var extra map[string]json.RawMessage
+	for key, value := range members {
+		present[key] = true
+		if known[key] {
+			continue
+		}
+		if extra == nil {
+			extra = make(map[string]json.RawMessage)
+		}
+		extra[key] = value
+	}
+	return extra, present, nil
+}
+
+// encodeObject encodes v, a struct without JSON methods, followed by the
+// members in extra. Modelled members holding an empty value are left out
+// unless present says the decoded object had them, so that an object written
+// back has the same members it was read with.
+func encodeObject(v any, extra map[string]json.RawMessage, present map[string]bool) ([]byte, error) {
+	data, err := marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	members, err := objectMembers(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	first := true
+	write := func(key string, value []byte) {
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		keyData, _ := marshal(key)
+		buf.Write(keyData)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	for _, m := range members {
+		if isEmptyValue(m.value) && !present[m.key] {
+			continue
+		}
+		write(m.key, m.value)
+	}
+
+	for _, key := range sortedKeys(extra) {
+		write(key, extra[key])
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type member struct {
+	key   string
+	value json.RawMessage
+}
+
+// objectMembers splits a JSON object into its members, keeping their order.
+func objectMembers(data []byte) ([]member, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	var members []member
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		members = append(members, member{key: tok.(string), value: value})
+	}
+	return members, nil
+}
+
+// fieldNames returns the JSON member names of a struct type, including those
+// of embedded structs.
+func fieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			for embedded := range fieldNames(ft) {
+				names[embedded] = true
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+func isEmptyValue(value []byte) bool {
+	switch string(bytes.TrimSpace(value)) {
+	case "null", `""`, "false", "[]", "{}":
+		return true
+	}
+	return false
+}
+
+// isJSONString reports whether a raw JSON value is a string.
+func isJSONString(value []byte) bool {
+	value = bytes.TrimSpace(value)
+	return len(value) > 0 && value[0] == '"'
+}
+
+// marshal encodes v without escaping HTML characters, which Postman never
+// does either.
+func marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// indent formats compact JSON with the given indentation unit.
+func indent(data []byte, unit string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", unit); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,299 @@
+// Package collection models Postman v2.1 collections.
+//
+// Every type keeps the members it does not model in Extra so that a collection
+// can be loaded, edited and written back without losing data Postman or other
+// tools put there.
+package collection
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FileSuffix is the suffix Postman uses for exported collection files.
+const FileSuffix = ".postman_collection.json"
+
+// Collection is the root of a Postman collection file.
+type Collection struct {
+	Info Info `json:"info"`
+	ItemGroup
+	Extra map[string]json.RawMessage `json:"-"`
+
+	present map[string]bool
+	// source is the document the collection was parsed from. Marshal keeps
+	// its layout so that saving an edited collection yields a minimal diff.
+	source []byte
+}
+
+// ItemGroup holds the members shared by the collection root and folders.
+type ItemGroup struct {
+	Items     []*Item    `json:"item"`
+	Events    []Event    `json:"event"`
+	Variables []Variable `json:"variable"`
+	Auth      *Auth      `json:"auth"`
+}
+
+// Info describes the collection itself.
+type Info struct {
+	PostmanID string                     `json:"_postman_id"`
+	Name      string                     `json:"name"`
+	Schema    string                     `json:"schema"`
+	Extra     map[string]json.RawMessage `json:"-"`
+
+	present map[string]bool
+}
+
+// Item is one entry of an item array. Requests have Request set; folders have
+// a non-nil Items slice, even when the folder is empty.
+type Item struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Request   *Request          `json:"request"`
+	Responses []json.RawMessage `json:"response"`
+	ItemGroup
+	Extra map[string]json.RawMessage `json:"-"`
+
+	present map[string]bool
+}
+
+// IsFolder reports whether the item is a folder rather than a request.
+func (i *Item) IsFolder() bool {
+	return i.Items != nil
+}
+
+// Event attaches a script to a lifecycle hook such as "prerequest" or "test".
+type Event struct {
+	ID       string                     `json:"id"`
+	Listen   string                     `json:"listen"`
+	Script   *Script                    `json:"script"`
+	Disabled bool                       `json:"disabled"`
+	Extra    map[string]json.RawMessage `json:"-"`
+
+	present map[string]bool
+}
+
+// Script is the source of an event. Exec holds one string per line.
+type Script struct {
+	ID    string                     `json:"id"`
+	Type  string                     `json:"type"`
+	Exec  []string                   `json:"exec"`
+	Extra map[string]json.RawMessage `json:"-"`
+
+	present    map[string]bool
+	execString bool
+}
+
+// Source returns the script as a single newline separated string.
+func (s *Script) Source() string {
+	if s == nil {
+		return ""
+	}
+	return strings.Join(s.Exec, "\n")
+}
+
+// Request describes the HTTP request an item sends.
+type Request struct {
+	Method string                     `json:"method"`
+	Header []Header                   `json:"header"`
+	Body   *Body                      `json:"body"`
+	URL    *URL                       `json:"url"`
+	Auth   *Auth                      `json:"auth"`
+	Extra  map[string]json.RawMessage `json:"-"`
+
+	present map[string]bool
+	// short is set when the request was written as a bare URL string.
+	short bool
+}
+
+// Header is a single request header.
+type Header struct {
+	Key      string                     `json:"key"`
+	Value    string                     `json:"value"`
+	Disabled bool                       `json:"disabled"`
+	Extra    map[string]json.RawMessage `json:"-"`
+
+	present map[string]bool
+}
+
+// Body is a request body. Raw is used for mode "raw"; URLEncoded and FormData
+// for the corresponding form modes.
+type Body struct {
+	Mode       string                     `json:"mode"`
+	Raw        string                     `json:"raw"`
+	URLEncoded []Param                    `json:"urlencoded"`
+	FormData   []Param                    `json:"formdata"`
+	Disabled   bool                       `json:"disabled"`
+	Extra      map[string]json.RawMessage `json:"-"`
+
+	present map[string]bool
+}
+
+// Param is a key/value pair of a form body or a URL query.
+type Param struct {
+	Key      string                     `json:"key"`
+	Value    string                     `json:"value"`
+	Type     string                     `json:"type"`
+	Disabled bool                       `json:"disabled"`
+	Extra    map[string]json.RawMessage `json:"-"`
+
+	present   map[string]bool
+	nullValue bool
+}
+
+// URL is a request URL. Raw is the URL as typed in Postman; the remaining
+// members are Postman's parsed breakdown of it.
+type URL struct {
+	Raw       string                     `json:"raw"`
+	Protocol  string                     `json:"protocol"`
+	Host      []string                   `json:"host"`
+	Port      string                     `json:"port"`
+	Path      []string                   `json:"path"`
+	Query     []Param                    `json:"query"`
+	Variables []Variable                 `json:"variable"`
+	Hash      string                     `json:"hash"`
+	Extra     map[string]json.RawMessage `json:"-"`
+
+	present    map[string]bool
+	hostString bool
+	pathString bool
+	// short is set when the URL was written as a plain string.
+	short bool
+}
+
+// String returns the URL as Postman sends it: Raw when present, otherwise
+// the URL assembled from its parts without disabled query parameters.
+func (u *URL) String() string {
+	if u == nil {
+		return ""
+	}
+	if u.Raw != "" {
+		return u.Raw
+	}
+
+	var b strings.Builder
+	if u.Protocol != "" {
+		b.WriteString(u.Protocol + "://")
+	}
+	b.WriteString(strings.Join(u.Host, "."))
+	if u.Port != "" {
+		b.WriteString(":" + u.Port)
+	}
+	if len(u.Path) > 0 {
+		b.WriteString("/" + strings.Join(u.Path, "/"))
+	}
+	var query []string
+	for _, q := range u.Query {
+		if q.Disabled {
+			continue
+		}
+		query = append(query, q.Key+"="+q.Value)
+	}
+	if len(query) > 0 {
+		b.WriteString("?" + strings.Join(query, "&"))
+	}
+	if u.Hash != "" {
+		b.WriteString("#" + u.Hash)
+	}
+	return b.String()
+}
+
+// Auth configures request authentication. Attributes are keyed by auth type,
+// for example Attributes["bearer"] holds the token for Type "bearer".
+type Auth struct {
+	Type       string
+	Attributes map[string][]Variable
+}
+
+// Param returns the value of the named attribute of the active auth type.
+func (a *Auth) Param(key string) string {
+	if a == nil {
+		return ""
+	}
+	for _, attr := range a.Attributes[a.Type] {
+		if attr.Key == key {
+			return attr.String()
+		}
+	}
+	return ""
+}
+
+// Variable is a collection, folder, URL or auth variable.
+type Variable struct {
+	ID       string                     `json:"id"`
+	Key      string                     `json:"key"`
+	Value    any                        `json:"value"`
+	Type     string                     `json:"type"`
+	Disabled bool                       `json:"disabled"`
+	Extra    map[string]json.RawMessage `json:"-"`
+
+	present map[string]bool
+}
+
+// String returns the value formatted the way Postman substitutes it.
+func (v Variable) String() string {
+	switch val := v.Value.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(data)
+	}
+}
+
+// Load reads and parses a collection file.
+func Load(path string) (*Collection, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Parse decodes a collection from JSON.
+func Parse(data []byte) (*Collection, error) {
+	var c Collection
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	c.source = data
+	return &c, nil
+}
+
+// Marshal encodes the collection as JSON. A parsed collection is written in
+// the layout it was read with: key order, indentation, line endings and
+// string escapes are kept, and only values that changed are rewritten.
+// Collections built in code are indented with tabs, as Postman does.
+func (c *Collection) Marshal() ([]byte, error) {
+	data, err := marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	if c.source == nil {
+		return indent(data, "\t")
+	}
+	return rewrite(c.source, data)
+}
+
+// Save writes the collection to path.
+func (c *Collection) Save(path string) error {
+	data, err := c.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	c.source = data
+	return nil
+}
@@ -0,0 +1,311 @@
+package collection
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testCollection = `{
+	"info": {
+		"_postman_id": "abc-123",
+		"name": "Users",
+		"schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json",
+		"description": "Collection with <html> & friends",
+		"_exporter_id": "42"
+	},
+	"event": [
+		{"listen": "prerequest", "script": {"type": "text/javascript", "exec": ["console.log('root');"]}}
+	],
+	"variable": [
+		{"key": "page_size", "value": 20, "type": "number"},
+		{"key": "empty", "value": ""}
+	],
+	"item": [
+		{
+			"name": "Accounts",
+			"auth": {"type": "bearer", "bearer": [{"key": "token", "value": "{{auth_token}}", "type": "string"}]},
+			"item": [
+				{
+					"name": "Create Account",
+					"protocolProfileBehavior": {"disableBodyPruning": true},
+					"request": {
+						"method": "POST",
+						"header": [{"key": "Content-Type", "value": "application/json", "type": "text"}],
+						"body": {"mode": "raw", "raw": "{\"name\": \"{{input_name}}\"}", "options": {"raw": {"language": "json"}}},
+						"url": {
+							"raw": "{{base_url}}/accounts?verbose",
+							"host": ["{{base_url}}"],
+							"path": ["accounts"],
+							"query": [{"key": "verbose", "value": null}]
+						}
+					},
+					"event": [
+						{"listen": "test", "script": {"exec": "pm.test('created', function () {});"}}
+					],
+					"response": []
+				}
+			]
+		},
+		{"name": "Empty Folder", "item": []},
+		{"name": "Health", "request": "{{base_url}}/health"}
+	]
+}`
+
+func parseTestCollection(t *testing.T) *Collection {
+	t.Helper()
+	c, err := Parse([]byte(testCollection))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	return c
+}
+
+func decodeGeneric(t *testing.T, data []byte) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, data)
+	}
+	return v
+}
+
+func TestParse_TypedFields(t *testing.T) {
+	c := parseTestCollection(t)
+
+	if c.Info.Name != "Users" || c.Info.PostmanID != "abc-123" {
+		t.Errorf("Info = %+v", c.Info)
+	}
+	if got := c.Events[0].Script.Source(); got != "console.log('root');" {
+		t.Errorf("collection script = %q", got)
+	}
+
+	accounts := c.Items[0]
+	if !accounts.IsFolder() {
+		t.Fatal("Accounts should be a folder")
+	}
+	if got := accounts.Auth.Param("token"); got != "{{auth_token}}" {
+		t.Errorf("folder auth token = %q", got)
+	}
+
+	create := accounts.Items[0]
+	if create.IsFolder() {
+		t.Error("Create Account should not be a folder")
+	}
+	req := create.Request
+	if req.Method != "POST" || req.Body.Mode != "raw" || req.URL.Raw != "{{base_url}}/accounts?verbose" {
+		t.Errorf("request = %+v", req)
+	}
+	if !reflect.DeepEqual(req.URL.Host, []string{"{{base_url}}"}) {
+		t.Errorf("URL.Host = %v", req.URL.Host)
+	}
+	if got := create.Events[0].Script.Exec; !reflect.DeepEqual(got, []string{"pm.test('created', function () {});"}) {
+		t.Errorf("string exec = %v", got)
+	}
+
+	if !c.Items[1].IsFolder() {
+		t.Error("empty folder should still be a folder")
+	}
+
+	health := c.Items[2]
+	if health.Request == nil || health.Request.URL.Raw != "{{base_url}}/health" {
+		t.Errorf("short request = %+v", health.Request)
+	}
+}
+
+func TestMarshal_RoundTrip(t *testing.T) {
+	c := parseTestCollection(t)
+
+	data, err := c.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	want := decodeGeneric(t, []byte(testCollection))
+	got := decodeGeneric(t, data)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("round trip changed the collection:\n%s", data)
+	}
+	if strings.Contains(string(data), `\u003c`) {
+		t.Error("Marshal() should not escape HTML characters")
+	}
+}
+
+func TestMarshal_AfterEdit(t *testing.T) {
+	c := parseTestCollection(t)
+
+	create := c.Items[0].Items[0]
+	create.Events[0].Script.Exec = []string{"line 1", "line 2"}
+	create.Request.Body.Raw = `{"name": "fixed"}`
+	health := c.Items[2]
+	health.Request.Body = &Body{Mode: "raw", Raw: "{}"}
+
+	data, err := c.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	reparsed, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse() of marshalled collection error = %v", err)
+	}
+	if got := reparsed.Items[0].Items[0].Events[0].Script.Source(); got != "line 1\nline 2" {
+		t.Errorf("edited exec = %q", got)
+	}
+	if got := reparsed.Items[0].Items[0].Request.Body.Raw; got != `{"name": "fixed"}` {
+		t.Errorf("edited body = %q", got)
+	}
+	if body := reparsed.Items[2].Request.Body; body == nil || body.Raw != "{}" {
+		t.Errorf("added body = %+v", body)
+	}
+	if _, ok := reparsed.Items[0].Items[0].Request.Body.Extra["options"]; !ok {
+		t.Error("unknown body members should survive an edit")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	c := parseTestCollection(t)
+
+	var visited []string
+	err := c.Walk(func(path []string, item *Item) error {
+		visited = append(visited, strings.Join(path, "/"))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+
+	want := []string{"Accounts", "Accounts/Create Account", "Empty Folder", "Health"}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("Walk() visited %v, want %v", visited, want)
+	}
+}
+
+func TestWalk_SkipFolder(t *testing.T) {
+	c := parseTestCollection(t)
+
+	var visited []string
+	err := c.Walk(func(path []string, item *Item) error {
+		visited = append(visited, item.Name)
+		if item.Name == "Accounts" {
+			return SkipFolder
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+
+	want := []string{"Accounts", "Empty Folder", "Health"}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("Walk() visited %v, want %v", visited, want)
+	}
+}
+
+func TestRequestsAndFind(t *testing.T) {
+	c := parseTestCollection(t)
+
+	var names []string
+	for _, item := range c.Requests() {
+		names = append(names, item.Name)
+	}
+	if want := []string{"Create Account", "Health"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Requests() = %v, want %v", names, want)
+	}
+
+	if item := c.Find("Create Account"); item == nil || item.Request == nil {
+		t.Error("Find() should locate nested requests")
+	}
+	if item := c.Find("missing"); item != nil {
+		t.Errorf("Find() = %v, want nil", item)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	c := parseTestCollection(t)
+	if got, want := c.References(), []string{"auth_token", "base_url", "input_name"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("References() = %v, want %v", got, want)
+	}
+
+	c.Events = append(c.Events, Event{Listen: "test", Script: &Script{Exec: []string{
+		`var age = pm.iterationData.get("input_age");`,
+		`if (data.input_city && data['input_zip']) { pm.expect(json.data.user).to.exist; }`,
+		`pm.environment.set("token", "{{ session }}");`,
+	}}})
+	want := []string{"auth_token", "base_url", "input_age", "input_city", "input_name", "input_zip", "session"}
+	if got := c.References(); !reflect.DeepEqual(got, want) {
+		t.Errorf("References() with scripts = %v, want %v", got, want)
+	}
+}
+
+func TestMarshal_Unchanged(t *testing.T) {
+	sources := map[string]string{
+		"tabs":    testCollection,
+		"crlf":    strings.ReplaceAll(testCollection, "\n", "\r\n"),
+		"compact": `{"info":{"name":"c"},"item":[{"name":"r","request":{"method":"GET","url":"x"}}]}`,
+	}
+	for name, source := range sources {
+		t.Run(name, func(t *testing.T) {
+			c, err := Parse([]byte(source))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			data, err := c.Marshal()
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(data) != source {
+				t.Errorf("unchanged collection was rewritten:\n%s", data)
+			}
+		})
+	}
+}
+
+func TestMarshal_MinimalDiff(t *testing.T) {
+	c := parseTestCollection(t)
+	c.Events[0].Script.Exec = []string{"console.log('edited');"}
+
+	data, err := c.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	want := strings.Replace(testCollection, "console.log('root');", "console.log('edited');", 1)
+	if string(data) != want {
+		t.Errorf("Marshal() changed more than the edited script:\n%s", data)
+	}
+}
+
+func TestMarshal_AddedMembersFollowLayout(t *testing.T) {
+	source := "{\r\n    \"info\": {\r\n        \"name\": \"c\"\r\n    },\r\n    \"item\": []\r\n}"
+	c, err := Parse([]byte(source))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	c.Items = append(c.Items, &Item{Name: "new"})
+
+	data, err := c.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	want := "{\r\n    \"info\": {\r\n        \"name\": \"c\"\r\n    },\r\n    \"item\": [\r\n        {\r\n            \"name\": \"new\"\r\n        }\r\n    ]\r\n}"
+	if string(data) != want {
+		t.Errorf("Marshal() =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestMarshal_NewCollection(t *testing.T) {
+	c := &Collection{Info: Info{Name: "fresh"}, ItemGroup: ItemGroup{Items: []*Item{{Name: "ping"}}}}
+
+	data, err := c.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	want := "{\n\t\"info\": {\n\t\t\"name\": \"fresh\"\n\t},\n\t\"item\": [\n\t\t{\n\t\t\t\"name\": \"ping\"\n\t\t}\n\t]\n}"
+	if string(data) != want {
+		t.Errorf("Marshal() =\n%s\nwant\n%s", data, want)
+	}
+}
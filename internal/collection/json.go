@@ -0,0 +1,348 @@
+package collection
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// The JSON methods below keep unknown members in Extra and remember which
+// members the source had, so that a collection survives a round trip.
+
+func (c *Collection) UnmarshalJSON(data []byte) error {
+	type plain Collection
+	var err error
+	c.Extra, c.present, err = decodeObject(data, (*plain)(c))
+	return err
+}
+
+func (c Collection) MarshalJSON() ([]byte, error) {
+	type plain Collection
+	present := c.present
+	if present == nil {
+		present = map[string]bool{"info": true, "item": true}
+	}
+	return encodeObject(plain(c), c.Extra, present)
+}
+
+func (i *Info) UnmarshalJSON(data []byte) error {
+	type plain Info
+	var err error
+	i.Extra, i.present, err = decodeObject(data, (*plain)(i))
+	return err
+}
+
+func (i Info) MarshalJSON() ([]byte, error) {
+	type plain Info
+	return encodeObject(plain(i), i.Extra, withName(i.present))
+}
+
+func (i *Item) UnmarshalJSON(data []byte) error {
+	type plain Item
+	var err error
+	i.Extra, i.present, err = decodeObject(data, (*plain)(i))
+	return err
+}
+
+func (i Item) MarshalJSON() ([]byte, error) {
+	type plain Item
+	return encodeObject(plain(i), i.Extra, withName(i.present))
+}
+
+func (e *Event) UnmarshalJSON(data []byte) error {
+	type plain Event
+	var err error
+	e.Extra, e.present, err = decodeObject(data, (*plain)(e))
+	return err
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	type plain Event
+	return encodeObject(plain(e), e.Extra, e.present)
+}
+
+func (s *Script) UnmarshalJSON(data []byte) error {
+	type plain Script
+	var shadow struct {
+		*plain
+		Exec json.RawMessage `json:"exec"`
+	}
+	shadow.plain = (*plain)(s)
+
+	var err error
+	s.Extra, s.present, err = decodeObject(data, &shadow)
+	if err != nil {
+		return err
+	}
+
+	s.Exec, s.execString, err = decodeLines(shadow.Exec, "\n")
+	return err
+}
+
+func (s Script) MarshalJSON() ([]byte, error) {
+	type plain Script
+	present := s.present
+	if present == nil {
+		present = map[string]bool{"exec": true}
+	}
+	return encodeObject(struct {
+		plain
+		Exec any `json:"exec"`
+	}{plain(s), encodeLines(s.Exec, s.execString, "\n")}, s.Extra, present)
+}
+
+func (r *Request) UnmarshalJSON(data []byte) error {
+	if isJSONString(data) {
+		var raw string
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+		*r = Request{URL: &URL{Raw: raw, short: true}, short: true}
+		return nil
+	}
+
+	type plain Request
+	var shadow struct {
+		*plain
+		Header json.RawMessage `json:"header"`
+	}
+	shadow.plain = (*plain)(r)
+
+	var err error
+	r.Extra, r.present, err = decodeObject(data, &shadow)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(shadow.Header) == 0:
+	case isJSONString(shadow.Header):
+		// Headers written as one "Key: Value" string are rare enough to be
+		// carried through untouched rather than modelled.
+		if r.Extra == nil {
+			r.Extra = make(map[string]json.RawMessage)
+		}
+		r.Extra["header"] = shadow.Header
+		delete(r.present, "header")
+	default:
+		if err := json.Unmarshal(shadow.Header, &r.Header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r Request) MarshalJSON() ([]byte, error) {
+	if r.short && r.URL != nil && r.URL.short && r.Method == "" && r.Header == nil &&
+		r.Body == nil && r.Auth == nil && len(r.Extra) == 0 {
+		return marshal(r.URL.Raw)
+	}
+	type plain Request
+	return encodeObject(plain(r), r.Extra, r.present)
+}
+
+func (h *Header) UnmarshalJSON(data []byte) error {
+	type plain Header
+	var err error
+	h.Extra, h.present, err = decodeObject(data, (*plain)(h))
+	return err
+}
+
+func (h Header) MarshalJSON() ([]byte, error) {
+	type plain Header
+	present := h.present
+	if present == nil {
+		present = map[string]bool{"key": true, "value": true}
+	}
+	return encodeObject(plain(h), h.Extra, present)
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	type plain Body
+	var err error
+	b.Extra, b.present, err = decodeObject(data, (*plain)(b))
+	return err
+}
+
+func (b Body) MarshalJSON() ([]byte, error) {
+	type plain Body
+	return encodeObject(plain(b), b.Extra, b.present)
+}
+
+func (p *Param) UnmarshalJSON(data []byte) error {
+	type plain Param
+	var shadow struct {
+		*plain
+		Value json.RawMessage `json:"value"`
+	}
+	shadow.plain = (*plain)(p)
+
+	var err error
+	p.Extra, p.present, err = decodeObject(data, &shadow)
+	if err != nil {
+		return err
+	}
+	// Postman writes null for values that were never filled in.
+	switch {
+	case isJSONString(shadow.Value):
+		return json.Unmarshal(shadow.Value, &p.Value)
+	case string(shadow.Value) == "null":
+		p.nullValue = true
+	case len(shadow.Value) > 0:
+		p.Value = string(shadow.Value)
+	}
+	return nil
+}
+
+func (p Param) MarshalJSON() ([]byte, error) {
+	type plain Param
+	present := p.present
+	if present == nil {
+		present = map[string]bool{"key": true, "value": true}
+	}
+	var value any = p.Value
+	if p.nullValue && p.Value == "" {
+		value = nil
+	}
+	return encodeObject(struct {
+		plain
+		Value any `json:"value"`
+	}{plain(p), value}, p.Extra, present)
+}
+
+func (u *URL) UnmarshalJSON(data []byte) error {
+	if isJSONString(data) {
+		var raw string
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+		*u = URL{Raw: raw, short: true}
+		return nil
+	}
+
+	type plain URL
+	var shadow struct {
+		*plain
+		Host json.RawMessage `json:"host"`
+		Path json.RawMessage `json:"path"`
+	}
+	shadow.plain = (*plain)(u)
+
+	var err error
+	u.Extra, u.present, err = decodeObject(data, &shadow)
+	if err != nil {
+		return err
+	}
+	if u.Host, u.hostString, err = decodeLines(shadow.Host, "."); err != nil {
+		return err
+	}
+	u.Path, u.pathString, err = decodeLines(shadow.Path, "/")
+	return err
+}
+
+func (u URL) MarshalJSON() ([]byte, error) {
+	if u.short && u.Protocol == "" && u.Host == nil && u.Port == "" && u.Path == nil &&
+		u.Query == nil && u.Variables == nil && u.Hash == "" && len(u.Extra) == 0 {
+		return marshal(u.Raw)
+	}
+	type plain URL
+	return encodeObject(struct {
+		plain
+		Host any `json:"host"`
+		Path any `json:"path"`
+	}{plain(u), encodeLines(u.Host, u.hostString, "."), encodeLines(u.Path, u.pathString, "/")}, u.Extra, u.present)
+}
+
+func (a *Auth) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	a.Type = ""
+	a.Attributes = nil
+	if raw, ok := members["type"]; ok {
+		if err := json.Unmarshal(raw, &a.Type); err != nil {
+			return err
+		}
+	}
+	for key, raw := range members {
+		if key == "type" {
+			continue
+		}
+		var attrs []Variable
+		if err := json.Unmarshal(raw, &attrs); err != nil {
+			return err
+		}
+		if a.Attributes == nil {
+			a.Attributes = make(map[string][]Variable)
+		}
+		a.Attributes[key] = attrs
+	}
+	return nil
+}
+
+func (a Auth) MarshalJSON() ([]byte, error) {
+	extra := make(map[string]json.RawMessage, len(a.Attributes))
+	for key, attrs := range a.Attributes {
+		data, err := marshal(attrs)
+		if err != nil {
+			return nil, err
+		}
+		extra[key] = data
+	}
+	return encodeObject(struct {
+		Type string `json:"type"`
+	}{a.Type}, extra, map[string]bool{"type": true})
+}
+
+func (v *Variable) UnmarshalJSON(data []byte) error {
+	type plain Variable
+	var err error
+	v.Extra, v.present, err = decodeObject(data, (*plain)(v))
+	return err
+}
+
+func (v Variable) MarshalJSON() ([]byte, error) {
+	type plain Variable
+	present := v.present
+	if present == nil {
+		present = map[string]bool{"key": true}
+	}
+	return encodeObject(plain(v), v.Extra, present)
+}
+
+// decodeLines decodes a member Postman accepts either as a list of strings or
+// as one string joined with sep. It reports whether the member was a string.
+func decodeLines(raw json.RawMessage, sep string) ([]string, bool, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, false, nil
+	}
+	if isJSONString(raw) {
+		var joined string
+		if err := json.Unmarshal(raw, &joined); err != nil {
+			return nil, false, err
+		}
+		return strings.Split(joined, sep), true, nil
+	}
+	var lines []string
+	if err := json.Unmarshal(raw, &lines); err != nil {
+		return nil, false, err
+	}
+	return lines, false, nil
+}
+
+// encodeLines is the inverse of decodeLines.
+func encodeLines(lines []string, joined bool, sep string) any {
+	if joined {
+		return strings.Join(lines, sep)
+	}
+	return lines
+}
+
+// withName makes sure objects that require a name keep it even when empty.
+func withName(present map[string]bool) map[string]bool {
+	if present != nil {
+		return present
+	}
+	return map[string]bool{"name": true}
+}
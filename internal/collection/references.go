@@ -0,0 +1,69 @@
+package collection
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+)
+
+var (
+	// templatePattern matches a {{variable}} reference
+	templatePattern = regexp.MustCompile(`\{\{\s*([^{}\s]+)\s*\}\}`)
+	// dataPattern matches a script reading an iteration data field through
+	// pm.iterationData.get("x"), data["x"] or data.x
+	dataPattern = regexp.MustCompile(`iterationData\.get\(\s*["']([^"']+)["']|(?:^|[^.\w$])data\[\s*["']([^"']+)["']|(?:^|[^.\w$])data\.([A-Za-z_$][\w$]*)`)
+)
+
+// References returns the names of the {{variables}} used by the collection's
+// requests, auth, variables and scripts, and the data fields its scripts read
+// through pm.iterationData.get() or data, sorted and once each.
+func (c *Collection) References() []string {
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if name != "" {
+			seen[name] = true
+		}
+	}
+	templates := func(v any) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return
+		}
+		for _, m := range templatePattern.FindAllSubmatch(data, -1) {
+			add(string(m[1]))
+		}
+	}
+	group := func(g *ItemGroup) {
+		if g.Auth != nil {
+			templates(g.Auth)
+		}
+		if len(g.Variables) > 0 {
+			templates(g.Variables)
+		}
+		for _, evt := range g.Events {
+			source := evt.Script.Source()
+			for _, m := range templatePattern.FindAllStringSubmatch(source, -1) {
+				add(m[1])
+			}
+			for _, m := range dataPattern.FindAllStringSubmatch(source, -1) {
+				add(m[1] + m[2] + m[3])
+			}
+		}
+	}
+
+	group(&c.ItemGroup)
+	_ = c.Walk(func(_ []string, item *Item) error {
+		group(&item.ItemGroup)
+		if item.Request != nil {
+			templates(item.Request)
+		}
+		return nil
+	})
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
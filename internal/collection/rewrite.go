@@ -0,0 +1,491 @@
+package collection
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// rewrite produces updated, a compact JSON document, in the layout of source.
+// Values that did not change are copied from source byte for byte, so key
+// order, indentation, line endings and string escapes survive. Only the
+// values that differ are written anew, using the layout detected in source.
+func rewrite(source, updated []byte) ([]byte, error) {
+	orig, err := parseNode(source)
+	if err != nil {
+		return nil, fmt.Errorf("parsing original document: %w", err)
+	}
+	next, err := parseNode(updated)
+	if err != nil {
+		return nil, fmt.Errorf("parsing updated document: %w", err)
+	}
+
+	w := &rewriter{source: source, updated: updated, style: detectStyle(source)}
+	w.out.Write(source[:orig.start])
+	w.emit(orig, next)
+	w.out.Write(source[orig.end:])
+	return w.out.Bytes(), nil
+}
+
+type nodeKind int
+
+const (
+	kindScalar nodeKind = iota
+	kindString
+	kindObject
+	kindArray
+)
+
+// node is a parsed JSON value together with its byte span in the document.
+type node struct {
+	kind       nodeKind
+	start, end int
+	keys       []string // object member names, in document order
+	children   []*node  // object member values or array elements
+}
+
+// style is the layout a document was written in.
+type style struct {
+	unit    string // one level of indentation
+	newline string
+	colon   string // separator between a member name and its value
+}
+
+type rewriter struct {
+	source, updated []byte
+	style           style
+	out             bytes.Buffer
+}
+
+// emit writes next, reusing as much of orig as possible.
+func (w *rewriter) emit(orig, next *node) {
+	if w.equal(orig, next) {
+		w.out.Write(w.source[orig.start:orig.end])
+		return
+	}
+
+	switch {
+	case orig.kind == kindObject && next.kind == kindObject && sameKeySet(orig.keys, next.keys):
+		w.splice(orig, func(i int) *node { return next.member(orig.keys[i]) })
+	case orig.kind == kindArray && next.kind == kindArray && len(orig.children) == len(next.children):
+		w.splice(orig, func(i int) *node { return next.children[i] })
+	case orig.kind == kindObject && next.kind == kindObject:
+		w.renderObject(orig, next)
+	case orig.kind == kindArray && next.kind == kindArray:
+		w.renderArray(orig, next)
+	default:
+		w.render(next, indentAt(w.source, orig.start), w.style.newline != "")
+	}
+}
+
+// splice copies orig from source, replacing each child with its counterpart.
+func (w *rewriter) splice(orig *node, counterpart func(int) *node) {
+	pos := orig.start
+	for i, child := range orig.children {
+		w.out.Write(w.source[pos:child.start])
+		w.emit(child, counterpart(i))
+		pos = child.end
+	}
+	w.out.Write(w.source[pos:orig.end])
+}
+
+// renderObject writes an object whose members were added or removed. Kept
+// members stay in their original order; new members follow them.
+func (w *rewriter) renderObject(orig, next *node) {
+	base := indentAt(w.source, orig.start)
+	multiline := w.multiline(orig) || len(orig.keys) == 0 && w.style.newline != ""
+	sep := w.separator(orig, multiline)
+
+	w.out.WriteByte('{')
+	written := 0
+	writeMember := func(key string, write func()) {
+		if written > 0 {
+			w.out.WriteString(sep)
+		}
+		if multiline {
+			w.out.WriteString(w.style.newline + base + w.style.unit)
+		}
+		keyData, _ := marshal(key)
+		w.out.Write(keyData)
+		w.out.WriteString(w.style.colon)
+		write()
+		written++
+	}
+
+	for i, key := range orig.keys {
+		value := next.member(key)
+		if value == nil {
+			continue
+		}
+		writeMember(key, func() { w.emit(orig.children[i], value) })
+	}
+	for i, key := range next.keys {
+		if orig.member(key) != nil {
+			continue
+		}
+		value := next.children[i]
+		writeMember(key, func() { w.render(value, base+w.style.unit, multiline) })
+	}
+
+	if multiline && written > 0 {
+		w.out.WriteString(w.style.newline + base)
+	}
+	w.out.WriteByte('}')
+}
+
+// renderArray writes an array whose length changed. Elements are matched by
+// position, so unchanged leading elements are copied verbatim.
+func (w *rewriter) renderArray(orig, next *node) {
+	base := indentAt(w.source, orig.start)
+	multiline := w.multiline(orig) || len(orig.children) == 0 && w.style.newline != ""
+	sep := w.separator(orig, multiline)
+
+	w.out.WriteByte('[')
+	for i, value := range next.children {
+		if i > 0 {
+			w.out.WriteString(sep)
+		}
+		if multiline {
+			w.out.WriteString(w.style.newline + base + w.style.unit)
+		}
+		if i < len(orig.children) {
+			w.emit(orig.children[i], value)
+		} else {
+			w.render(value, base+w.style.unit, multiline)
+		}
+	}
+	if multiline && len(next.children) > 0 {
+		w.out.WriteString(w.style.newline + base)
+	}
+	w.out.WriteByte(']')
+}
+
+// render writes a value from the updated document that has no counterpart in
+// source. base is the indentation of the line the value starts on.
+func (w *rewriter) render(n *node, base string, multiline bool) {
+	if n.kind != kindObject && n.kind != kindArray || len(n.children) == 0 {
+		w.out.Write(w.updated[n.start:n.end])
+		return
+	}
+
+	open, close := byte('['), byte(']')
+	if n.kind == kindObject {
+		open, close = '{', '}'
+	}
+	sep := ","
+	if !multiline && w.style.colon == ": " {
+		sep = ", "
+	}
+
+	w.out.WriteByte(open)
+	for i, child := range n.children {
+		if i > 0 {
+			w.out.WriteString(sep)
+		}
+		if multiline {
+			w.out.WriteString(w.style.newline + base + w.style.unit)
+		}
+		if n.kind == kindObject {
+			keyData, _ := marshal(n.keys[i])
+			w.out.Write(keyData)
+			w.out.WriteString(w.style.colon)
+		}
+		w.render(child, base+w.style.unit, multiline)
+	}
+	if multiline {
+		w.out.WriteString(w.style.newline + base)
+	}
+	w.out.WriteByte(close)
+}
+
+// multiline reports whether a container from source spans several lines.
+func (w *rewriter) multiline(n *node) bool {
+	return w.style.newline != "" && bytes.IndexByte(w.source[n.start:n.end], '\n') >= 0
+}
+
+// separator returns the text placed between the elements of a container.
+func (w *rewriter) separator(n *node, multiline bool) string {
+	if multiline {
+		return ","
+	}
+	if len(n.children) > 1 {
+		gap := w.source[n.children[0].end:]
+		comma := bytes.IndexByte(gap, ',')
+		if comma >= 0 && comma+1 < len(gap) && gap[comma+1] != ' ' {
+			return ","
+		}
+	}
+	return ", "
+}
+
+// equal reports whether two values are the same JSON value.
+func (w *rewriter) equal(a, b *node) bool {
+	if a.kind != b.kind {
+		return false
+	}
+	switch a.kind {
+	case kindString:
+		var sa, sb string
+		if json.Unmarshal(w.source[a.start:a.end], &sa) != nil || json.Unmarshal(w.updated[b.start:b.end], &sb) != nil {
+			return false
+		}
+		return sa == sb
+	case kindScalar:
+		ta, tb := w.source[a.start:a.end], w.updated[b.start:b.end]
+		if bytes.Equal(ta, tb) {
+			return true
+		}
+		fa, errA := strconv.ParseFloat(string(ta), 64)
+		fb, errB := strconv.ParseFloat(string(tb), 64)
+		return errA == nil && errB == nil && fa == fb
+	case kindObject:
+		if !sameKeySet(a.keys, b.keys) {
+			return false
+		}
+		for i, key := range a.keys {
+			if !w.equal(a.children[i], b.member(key)) {
+				return false
+			}
+		}
+		return true
+	default:
+		if len(a.children) != len(b.children) {
+			return false
+		}
+		for i := range a.children {
+			if !w.equal(a.children[i], b.children[i]) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func (n *node) member(key string) *node {
+	for i, k := range n.keys {
+		if k == key {
+			return n.children[i]
+		}
+	}
+	return nil
+}
+
+func sameKeySet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]bool, len(a))
+	for _, key := range a {
+		seen[key] = true
+	}
+	for _, key := range b {
+		if !seen[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// detectStyle works out the indentation unit, line ending and member
+// separator a document uses. Compact documents get an empty newline.
+func detectStyle(source []byte) style {
+	s := style{unit: "\t", colon: ": "}
+
+	nl := bytes.IndexByte(source, '\n')
+	if nl < 0 {
+		s.newline = ""
+		s.unit = ""
+		s.colon = ":"
+		if bytes.Contains(source, []byte(`": `)) {
+			s.colon = ": "
+		}
+		return s
+	}
+
+	s.newline = "\n"
+	if nl > 0 && source[nl-1] == '\r' {
+		s.newline = "\r\n"
+	}
+	if unit := indentAt(source, nl+1); unit != "" {
+		s.unit = unit
+	}
+	if !bytes.Contains(source, []byte(`": `)) && bytes.Contains(source, []byte(`":`)) {
+		s.colon = ":"
+	}
+	return s
+}
+
+// indentAt returns the leading whitespace of the line containing pos.
+func indentAt(source []byte, pos int) string {
+	lineStart := bytes.LastIndexByte(source[:pos], '\n') + 1
+	end := lineStart
+	for end < len(source) && (source[end] == ' ' || source[end] == '\t') {
+		end++
+	}
+	return string(source[lineStart:end])
+}
+
+// parseNode parses a JSON document, recording the span of every value.
+func parseNode(data []byte) (*node, error) {
+	p := &nodeParser{data: data}
+	p.skipSpace()
+	n, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(data) {
+		return nil, fmt.Errorf("unexpected data at offset %d", p.pos)
+	}
+	return n, nil
+}
+
+type nodeParser struct {
+	data []byte
+	pos  int
+}
+
+var errUnexpectedEnd = errors.New("unexpected end of JSON input")
+
+func (p *nodeParser) value() (*node, error) {
+	if p.pos >= len(p.data) {
+		return nil, errUnexpectedEnd
+	}
+	switch p.data[p.pos] {
+	case '{':
+		return p.object()
+	case '[':
+		return p.array()
+	case '"':
+		start := p.pos
+		if err := p.skipString(); err != nil {
+			return nil, err
+		}
+		return &node{kind: kindString, start: start, end: p.pos}, nil
+	default:
+		start := p.pos
+		for p.pos < len(p.data) && !bytes.ContainsAny(p.data[p.pos:p.pos+1], " \t\r\n,]}") {
+			p.pos++
+		}
+		if p.pos == start {
+			return nil, fmt.Errorf("unexpected character %q at offset %d", p.data[p.pos], p.pos)
+		}
+		return &node{kind: kindScalar, start: start, end: p.pos}, nil
+	}
+}
+
+func (p *nodeParser) object() (*node, error) {
+	n := &node{kind: kindObject, start: p.pos}
+	p.pos++
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.pos++
+		n.end = p.pos
+		return n, nil
+	}
+	for {
+		p.skipSpace()
+		keyStart := p.pos
+		if p.peek() != '"' {
+			return nil, fmt.Errorf("expected member name at offset %d", p.pos)
+		}
+		if err := p.skipString(); err != nil {
+			return nil, err
+		}
+		var key string
+		if err := json.Unmarshal(p.data[keyStart:p.pos], &key); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.peek() != ':' {
+			return nil, fmt.Errorf("expected ':' at offset %d", p.pos)
+		}
+		p.pos++
+		p.skipSpace()
+		child, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		n.keys = append(n.keys, key)
+		n.children = append(n.children, child)
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			n.end = p.pos
+			return n, nil
+		default:
+			return nil, fmt.Errorf("expected ',' or '}' at offset %d", p.pos)
+		}
+	}
+}
+
+func (p *nodeParser) array() (*node, error) {
+	n := &node{kind: kindArray, start: p.pos}
+	p.pos++
+	p.skipSpace()
+	if p.peek() == ']' {
+		p.pos++
+		n.end = p.pos
+		return n, nil
+	}
+	for {
+		p.skipSpace()
+		child, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		n.children = append(n.children, child)
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			n.end = p.pos
+			return n, nil
+		default:
+			return nil, fmt.Errorf("expected ',' or ']' at offset %d", p.pos)
+		}
+	}
+}
+
+func (p *nodeParser) skipString() error {
+	p.pos++ // opening quote
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case '\\':
+			p.pos += 2
+		case '"':
+			p.pos++
+			return nil
+		default:
+			p.pos++
+		}
+	}
+	return errUnexpectedEnd
+}
+
+func (p *nodeParser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\r', '\n':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *nodeParser) peek() byte {
+	if p.pos >= len(p.data) {
+		return 0
+	}
+	return p.data[p.pos]
+}
@@ -0,0 +1,70 @@
+package collection
+
+import "errors"
+
+// SkipFolder can be returned by a WalkFunc called for a folder to skip the
+// folder's contents. It is not returned as an error by Walk.
+var SkipFolder = errors.New("skip this folder")
+
+// WalkFunc is called by Walk for every item. path holds the names of the
+// enclosing folders followed by the item's own name; it must not be retained
+// after the call returns.
+type WalkFunc func(path []string, item *Item) error
+
+// Walk visits every item of the collection depth first, in document order.
+// A folder is visited before its contents. Walk stops at the first error
+// returned by fn.
+func (c *Collection) Walk(fn WalkFunc) error {
+	return walkItems(nil, c.Items, fn)
+}
+
+func walkItems(parents []string, items []*Item, fn WalkFunc) error {
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		path := append(parents[:len(parents):len(parents)], item.Name)
+
+		err := fn(path, item)
+		if errors.Is(err, SkipFolder) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if item.IsFolder() {
+			if err := walkItems(path, item.Items, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Requests returns every request item of the collection in document order.
+func (c *Collection) Requests() []*Item {
+	var requests []*Item
+	_ = c.Walk(func(_ []string, item *Item) error {
+		if !item.IsFolder() {
+			requests = append(requests, item)
+		}
+		return nil
+	})
+	return requests
+}
+
+// Find returns the first item, folder or request, with the given name.
+func (c *Collection) Find(name string) *Item {
+	var found *Item
+	_ = c.Walk(func(_ []string, item *Item) error {
+		if item.Name == name {
+			found = item
+			return errStop
+		}
+		return nil
+	})
+	return found
+}
+
+var errStop = errors.New("stop walking")
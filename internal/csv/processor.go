@@ -2,13 +2,15 @@ package csv
 
 import (
 	"bufio"
+	"bytes"
+	stdcsv "encoding/csv"
 	"errors"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
+
+	"github.com/ssd532/plaintest/internal/tempdir"
 )
 
 type Processor struct{}
@@ -17,123 +19,229 @@ func NewProcessor() *Processor {
 	return &Processor{}
 }
 
+// ProcessRows writes the header and the data rows of csvFile matched by
+// rowSelection to a temporary CSV, and returns its path. See Selection for
+// the grammar. Rows are CSV records, so quoted fields may span lines; each
+// is copied byte for byte.
 func (p *Processor) ProcessRows(csvFile string, rowSelection string) (string, error) {
 	if rowSelection == "" {
 		return "", errors.New("row selection is required")
 	}
 
-	// Parse which rows to include
-	rows, err := p.ParseRowSelection(rowSelection)
+	t, err := readTable(csvFile)
 	if err != nil {
-		return "", fmt.Errorf("invalid row selection: %w", err)
+		return "", err
 	}
-
-	// Create temporary output file
-	tmpDir := os.TempDir()
-	outputFile := filepath.Join(tmpDir, fmt.Sprintf("plaintest_rows_%s.csv", strings.ReplaceAll(rowSelection, ",", "_")))
-
-	// Read input CSV
-	input, err := os.Open(csvFile)
+	rows, err := p.selectRows(t, rowSelection)
 	if err != nil {
-		return "", fmt.Errorf("failed to open CSV file: %w", err)
+		return "", err
 	}
-	defer input.Close()
 
-	// Create output CSV
-	output, err := os.Create(outputFile)
+	// Create a uniquely named output CSV, so concurrent links do not share one
+	output, err := tempdir.CreateTemp("plaintest_rows_*.csv")
 	if err != nil {
 		return "", fmt.Errorf("failed to create output file: %w", err)
 	}
 	defer output.Close()
 
-	scanner := bufio.NewScanner(input)
 	writer := bufio.NewWriter(output)
-	defer writer.Flush()
+	t.writeRecord(writer, t.headerRaw)
+	for _, row := range rows {
+		t.writeRecord(writer, t.raw[row-1])
+	}
+	if err := writer.Flush(); err != nil {
+		return "", fmt.Errorf("failed to write output file: %w", err)
+	}
+	return output.Name(), nil
+}
+
+// selectRows returns the data rows of t matched by rowSelection.
+func (p *Processor) selectRows(t *table, rowSelection string) ([]int, error) {
+	selection, err := p.ParseRowSelection(rowSelection)
+	if err != nil {
+		return nil, fmt.Errorf("invalid row selection: %w", err)
+	}
+	return selection.Rows(t.header, t.records)
+}
+
+// table is a CSV file split into records, keeping the bytes of each.
+type table struct {
+	header    []string
+	headerRaw []byte
+	records   [][]string
+	// raw[i] holds the bytes of records[i] as found in the file, including
+	// its line ending, if any
+	raw [][]byte
+	// newline ends records that had no line ending of their own
+	newline string
+}
 
-	lineNum := 0
-	for scanner.Scan() {
-		lineNum++
-		line := scanner.Text()
+// readTable reads csvFile record by record. A UTF-8 byte order mark and
+// CRLF line endings are kept in the raw bytes but not in the fields' names.
+func readTable(csvFile string) (*table, error) {
+	data, err := os.ReadFile(csvFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV file: %w", err)
+	}
 
-		// Always include header (line 1)
-		if lineNum == 1 {
-			_, _ = writer.WriteString(line + "\n")
+	reader := stdcsv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	t := &table{newline: "\n"}
+	var start int64
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV file %s: %w", csvFile, err)
+		}
+		end := reader.InputOffset()
+		// The reader skips blank lines before a record; leave them out
+		raw := bytes.TrimLeft(data[start:end], "\r\n")
+		start = end
+
+		if t.headerRaw == nil {
+			t.header, t.headerRaw = fields, raw
+			if bytes.HasSuffix(raw, []byte("\r\n")) {
+				t.newline = "\r\n"
+			}
 			continue
 		}
+		t.records = append(t.records, fields)
+		t.raw = append(t.raw, raw)
+	}
+	return t, nil
+}
 
-		// Include line if it's in our selection (convert to 0-based index)
-		dataRowNum := lineNum - 1
-		if p.containsRow(rows, dataRowNum) {
-			_, _ = writer.WriteString(line + "\n")
-		}
+// writeRecord writes the bytes of a record, ending it with a newline if the
+// file did not.
+func (t *table) writeRecord(w *bufio.Writer, raw []byte) {
+	_, _ = w.Write(raw)
+	if !bytes.HasSuffix(raw, []byte("\n")) {
+		_, _ = w.WriteString(t.newline)
 	}
+}
 
-	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("error reading CSV: %w", err)
+// Records returns the column names and the data rows of csvFile. A byte
+// order mark is removed from the first name.
+func (p *Processor) Records(csvFile string) ([]string, [][]string, error) {
+	t, err := readTable(csvFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := append([]string(nil), t.header...)
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
 	}
+	return header, t.records, nil
+}
 
-	return outputFile, nil
+// Shard is one row range of a data file split by Split.
+type Shard struct {
+	// Path is a temporary data file holding the shard's rows, and for CSV
+	// the header.
+	Path string
+	// First and Last are the shard's data rows, one based and inclusive.
+	First int
+	Last  int
 }
 
-func (p *Processor) ParseRowSelection(rowSelection string) ([]int, error) {
-	if rowSelection == "" {
-		return nil, errors.New("empty row selection")
+// Rewrite writes the file to w with the bytes of each record passed through
+// fn, which gets the one based row. The header is written unchanged.
+func (p *Processor) Rewrite(csvFile string, w io.Writer, fn func(row int, record string) (string, error)) error {
+	t, err := readTable(csvFile)
+	if err != nil {
+		return err
 	}
 
-	// Single number: "2"
-	if matched, _ := regexp.MatchString(`^[0-9]+$`, rowSelection); matched {
-		num, err := strconv.Atoi(rowSelection)
+	writer := bufio.NewWriter(w)
+	if t.headerRaw != nil {
+		t.writeRecord(writer, t.headerRaw)
+	}
+	for i, raw := range t.raw {
+		record, err := fn(i+1, string(raw))
 		if err != nil {
-			return nil, fmt.Errorf("invalid number: %s", rowSelection)
+			return fmt.Errorf("row %d: %w", i+1, err)
 		}
-		return []int{num}, nil
+		t.writeRecord(writer, []byte(record))
 	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	return nil
+}
 
-	// Range: "2-5"
-	if matched, _ := regexp.MatchString(`^[0-9]+-[0-9]+$`, rowSelection); matched {
-		parts := strings.Split(rowSelection, "-")
-		start, err1 := strconv.Atoi(parts[0])
-		end, err2 := strconv.Atoi(parts[1])
-		if err1 != nil || err2 != nil {
-			return nil, fmt.Errorf("invalid range: %s", rowSelection)
-		}
-		if start > end {
-			return nil, fmt.Errorf("invalid range: start > end")
-		}
+// CountRows returns the number of data rows in csvFile, not counting the
+// header. A row is a CSV record, which may span lines.
+func (p *Processor) CountRows(csvFile string) (int, error) {
+	t, err := readTable(csvFile)
+	if err != nil {
+		return 0, err
+	}
+	return len(t.records), nil
+}
 
-		var rows []int
-		for i := start; i <= end; i++ {
-			rows = append(rows, i)
-		}
-		return rows, nil
+// Split divides the data rows of csvFile into at most n consecutive row
+// ranges of nearly equal size, each written with the header by ProcessRows.
+// Files with fewer rows than n give one shard per row.
+func (p *Processor) Split(csvFile string, n int) ([]Shard, error) {
+	rows, err := p.CountRows(csvFile)
+	if err != nil {
+		return nil, err
 	}
+	return SplitRows(rows, n, func(rowSelection string) (string, error) {
+		return p.ProcessRows(csvFile, rowSelection)
+	})
+}
 
-	// Comma-separated: "1,3,5"
-	if matched, _ := regexp.MatchString(`^[0-9,]+$`, rowSelection); matched {
-		parts := strings.Split(rowSelection, ",")
-		var rows []int
-		for _, part := range parts {
-			part = strings.TrimSpace(part)
-			if part == "" {
-				continue
-			}
-			num, err := strconv.Atoi(part)
-			if err != nil {
-				return nil, fmt.Errorf("invalid number in list: %s", part)
+// SplitRows divides rows data rows into at most n consecutive ranges of
+// nearly equal size. write is called with the row selection of each range
+// and returns the path of the file holding it; on error the files written
+// so far are removed.
+func SplitRows(rows, n int, write func(rowSelection string) (string, error)) ([]Shard, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid shard count: %d", n)
+	}
+	if rows == 0 {
+		return nil, errors.New("no data rows to split")
+	}
+	if n > rows {
+		n = rows
+	}
+
+	var shards []Shard
+	first := 1
+	for i := 0; i < n; i++ {
+		// Spread the remainder over the first shards
+		size := rows / n
+		if i < rows%n {
+			size++
+		}
+		last := first + size - 1
+
+		path, err := write(fmt.Sprintf("%d-%d", first, last))
+		if err != nil {
+			for _, shard := range shards {
+				os.Remove(shard.Path)
 			}
-			rows = append(rows, num)
+			return nil, err
 		}
-		return rows, nil
+		shards = append(shards, Shard{Path: path, First: first, Last: last})
+		first = last + 1
 	}
-
-	return nil, fmt.Errorf("invalid row selection format: %s", rowSelection)
+	return shards, nil
 }
 
-func (p *Processor) containsRow(rows []int, target int) bool {
-	for _, row := range rows {
-		if row == target {
-			return true
-		}
+// SelectedRows returns the data rows of csvFile that ProcessRows keeps for
+// rowSelection, one based and in file order. Iteration i of a run on the
+// processed file is row SelectedRows()[i] of csvFile.
+func (p *Processor) SelectedRows(csvFile string, rowSelection string) ([]int, error) {
+	t, err := readTable(csvFile)
+	if err != nil {
+		return nil, err
 	}
-	return false
+	return p.selectRows(t, rowSelection)
 }
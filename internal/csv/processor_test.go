@@ -45,12 +45,24 @@ test5,data5,result5`
 			wantRows:     4, // header + rows 1,3,5
 			wantErr:      false,
 		},
+		{
+			name:         "column filter",
+			rowSelection: "test_name~=test,!input_data=data4",
+			wantRows:     5, // header + rows 1,2,3,5
+			wantErr:      false,
+		},
 		{
 			name:         "invalid format",
 			rowSelection: "abc",
 			wantRows:     0,
 			wantErr:      true,
 		},
+		{
+			name:         "out of range",
+			rowSelection: "9",
+			wantRows:     0,
+			wantErr:      true,
+		},
 		{
 			name:         "empty selection",
 			rowSelection: "",
@@ -98,53 +110,154 @@ test5,data5,result5`
 
 func TestCSVProcessor_ParseRowSelection(t *testing.T) {
 	processor := NewProcessor()
+	header := []string{"\ufefftest_id", "test_name"}
+	records := [][]string{
+		{"TC_001", "valid user"},
+		{"TC_002", "invalid email"},
+		{"TC_003", "missing Email"},
+		{"TC_004", "valid admin"},
+		{"TC_005", "locked user"},
+		{"TC_006", "expired token"},
+	}
 
 	tests := []struct {
 		name         string
 		rowSelection string
 		wantRows     []int
-		wantErr      bool
+		wantErr      string
 	}{
-		{
-			name:         "single row",
-			rowSelection: "2",
-			wantRows:     []int{2},
-			wantErr:      false,
-		},
-		{
-			name:         "range",
-			rowSelection: "2-5",
-			wantRows:     []int{2, 3, 4, 5},
-			wantErr:      false,
-		},
-		{
-			name:         "comma-separated",
-			rowSelection: "1,3,5",
-			wantRows:     []int{1, 3, 5},
-			wantErr:      false,
-		},
-		{
-			name:         "invalid format",
-			rowSelection: "abc",
-			wantRows:     nil,
-			wantErr:      true,
-		},
+		{name: "single row", rowSelection: "2", wantRows: []int{2}},
+		{name: "range", rowSelection: "2-5", wantRows: []int{2, 3, 4, 5}},
+		{name: "comma-separated", rowSelection: "1,3,5", wantRows: []int{1, 3, 5}},
+		{name: "mixed list", rowSelection: "1, 4-5,2", wantRows: []int{1, 2, 4, 5}},
+		{name: "open range", rowSelection: "5-", wantRows: []int{5, 6}},
+		{name: "exclusion only", rowSelection: "!3,!5-", wantRows: []int{1, 2, 4}},
+		{name: "range with exclusion", rowSelection: "2-6,!4", wantRows: []int{2, 3, 5, 6}},
+		{name: "column equals", rowSelection: "test_id=TC_004", wantRows: []int{4}},
+		{name: "column contains", rowSelection: "test_name~=EMAIL", wantRows: []int{2, 3}},
+		{name: "excluded column", rowSelection: "!test_name~=user", wantRows: []int{2, 3, 4, 6}},
+		{name: "invalid format", rowSelection: "abc", wantErr: "invalid row selection format"},
+		{name: "reversed range", rowSelection: "5-2", wantErr: "start > end"},
+		{name: "row zero", rowSelection: "0-2", wantErr: "numbered from 1"},
+		{name: "out of range", rowSelection: "7", wantErr: "out of range: the file has 6 data rows"},
+		{name: "range past the end", rowSelection: "4-9", wantErr: "out of range"},
+		{name: "unknown column", rowSelection: "id=TC_001", wantErr: "unknown column in row selection: id. Available: [test_id test_name]"},
+		{name: "missing column", rowSelection: "=x", wantErr: "missing column name"},
+		{name: "no match", rowSelection: "test_id=TC_999", wantErr: "matches no rows"},
+		{name: "everything excluded", rowSelection: "!1-", wantErr: "matches no rows"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			rows, err := processor.ParseRowSelection(tt.rowSelection)
+			var rows []int
+			selection, err := processor.ParseRowSelection(tt.rowSelection)
+			if err == nil {
+				rows, err = selection.Rows(header, records)
+			}
 
-			if (err != nil) != tt.wantErr {
-				t.Errorf("ParseRowSelection() error = %v, wantErr %v", err, tt.wantErr)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseRowSelection(%q) error = %v, want %q", tt.rowSelection, err, tt.wantErr)
+				}
 				return
 			}
+			if err != nil || !equalSlices(rows, tt.wantRows) {
+				t.Errorf("ParseRowSelection(%q) = %v, %v; want %v", tt.rowSelection, rows, err, tt.wantRows)
+			}
+		})
+	}
+}
+
+func TestCSVProcessor_Split(t *testing.T) {
+	testCSV := `test_name,input_data
+test1,data1
+test2,data2
+test3,data3
+test4,data4
+test5,data5
+`
+	tmpFile := createTempCSV(t, testCSV)
+	processor := NewProcessor()
+
+	tests := []struct {
+		name       string
+		shards     int
+		wantRanges [][2]int
+		wantErr    bool
+	}{
+		{name: "even remainder spread", shards: 2, wantRanges: [][2]int{{1, 3}, {4, 5}}},
+		{name: "three shards", shards: 3, wantRanges: [][2]int{{1, 2}, {3, 4}, {5, 5}}},
+		{name: "more shards than rows", shards: 9, wantRanges: [][2]int{{1, 1}, {2, 2}, {3, 3}, {4, 4}, {5, 5}}},
+		{name: "one shard", shards: 1, wantRanges: [][2]int{{1, 5}}},
+		{name: "zero shards", shards: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shards, err := processor.Split(tmpFile, tt.shards)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Split() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var all []string
+			for i, shard := range shards {
+				defer os.Remove(shard.Path)
+				if i >= len(tt.wantRanges) || shard.First != tt.wantRanges[i][0] || shard.Last != tt.wantRanges[i][1] {
+					t.Errorf("shard %d = %d-%d, want %v", i, shard.First, shard.Last, tt.wantRanges)
+					continue
+				}
 
-			if !tt.wantErr && !equalSlices(rows, tt.wantRows) {
-				t.Errorf("ParseRowSelection() = %v, want %v", rows, tt.wantRows)
+				content, err := os.ReadFile(shard.Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+				if lines[0] != "test_name,input_data" {
+					t.Errorf("shard %d should keep the header, got %q", i, lines[0])
+				}
+				all = append(all, lines[1:]...)
+			}
+			if len(shards) != len(tt.wantRanges) {
+				t.Errorf("Split() returned %d shards, want %d", len(shards), len(tt.wantRanges))
+			}
+
+			// Together the shards hold every row once, in order
+			if !tt.wantErr && strings.Join(all, "\n") != strings.Join(strings.Split(strings.TrimSpace(testCSV), "\n")[1:], "\n") {
+				t.Errorf("shard rows = %v", all)
 			}
 		})
 	}
+
+	empty := createTempCSV(t, "test_name\n")
+	if _, err := processor.Split(empty, 2); err == nil {
+		t.Error("Split() should fail for a file without data rows")
+	}
+}
+
+func TestCSVProcessor_SelectedRows(t *testing.T) {
+	tmpFile := createTempCSV(t, "test_name\nt1\nt2\nt3\nt4\n")
+	processor := NewProcessor()
+
+	tests := []struct {
+		selection string
+		want      []int
+	}{
+		{"2-3", []int{2, 3}},
+		{"4,1,4", []int{1, 4}}, // file order, once each
+		{"3-", []int{3, 4}},
+		{"test_name=t2", []int{2}},
+	}
+	for _, tt := range tests {
+		got, err := processor.SelectedRows(tmpFile, tt.selection)
+		if err != nil || !equalSlices(got, tt.want) {
+			t.Errorf("SelectedRows(%q) = %v, %v; want %v", tt.selection, got, err, tt.want)
+		}
+	}
+	for _, selection := range []string{"x", "3-9"} {
+		if _, err := processor.SelectedRows(tmpFile, selection); err == nil {
+			t.Errorf("SelectedRows(%q) should fail", selection)
+		}
+	}
 }
 
 func createTempCSV(t *testing.T, content string) string {
@@ -170,3 +283,143 @@ func equalSlices(a, b []int) bool {
 	}
 	return true
 }
+
+func TestCSVProcessor_ProcessRows_Records(t *testing.T) {
+	processor := NewProcessor()
+	long := strings.Repeat("x", 100*1024)
+
+	tests := []struct {
+		name      string
+		content   string
+		selection string
+		want      string
+	}{
+		{
+			name:      "quoted newline",
+			content:   "test_id,input_body\nTC_001,\"{\n  \"\"a\"\": 1\n}\"\nTC_002,{}\nTC_003,\"x, \"\"y\"\"\"\n",
+			selection: "2-3",
+			want:      "test_id,input_body\nTC_002,{}\nTC_003,\"x, \"\"y\"\"\"\n",
+		},
+		{
+			name:      "row after a multiline row",
+			content:   "test_id,input_body\nTC_001,\"{\n  \"\"a\"\": 1\n}\"\nTC_002,{}\n",
+			selection: "test_id=TC_001",
+			want:      "test_id,input_body\nTC_001,\"{\n  \"\"a\"\": 1\n}\"\n",
+		},
+		{
+			name:      "BOM and CRLF from Excel",
+			content:   "\ufefftest_id,name\r\nTC_001,a\r\nTC_002,b\r\nTC_003,c",
+			selection: "test_id=TC_003,1",
+			want:      "\ufefftest_id,name\r\nTC_001,a\r\nTC_003,c\r\n",
+		},
+		{
+			name:      "line longer than 64 KB",
+			content:   "test_id,input_body\nTC_001," + long + "\nTC_002,short\n",
+			selection: "1",
+			want:      "test_id,input_body\nTC_001," + long + "\n",
+		},
+		{
+			name:      "blank lines are skipped",
+			content:   "test_id\n\nTC_001\n\nTC_002\n",
+			selection: "2",
+			want:      "test_id\nTC_002\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputFile, err := processor.ProcessRows(createTempCSV(t, tt.content), tt.selection)
+			if err != nil {
+				t.Fatalf("ProcessRows() error = %v", err)
+			}
+			defer os.Remove(outputFile)
+
+			content, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != tt.want {
+				t.Errorf("ProcessRows() wrote %q, want %q", content, tt.want)
+			}
+		})
+	}
+
+	count, err := processor.CountRows(createTempCSV(t, tests[0].content))
+	if err != nil || count != 3 {
+		t.Errorf("CountRows() = %d, %v; want 3 records", count, err)
+	}
+}
+
+func TestCSVProcessor_Validate(t *testing.T) {
+	processor := NewProcessor()
+	content := "test_id,test_name,input_email,input_emial,expected_status\n" +
+		"TC_001,valid,a@b.c,,201\n" +
+		"TC_002,missing field,a@b.c,\n" +
+		"TC_001,duplicate,a@b.c,,4xx\n" +
+		",no id,a@b.c,,{{created}}\n" +
+		"TC_005,,a@b.c,,abc\n" +
+		"TC_006,\"multi\nline\",a@b.c,,700\n"
+
+	issues, rows, err := processor.Validate(createTempCSV(t, content), Rules{
+		Required:        []string{"test_name"},
+		References:      []string{"base_url", "input_email", "input_age"},
+		CheckReferences: true,
+	})
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if rows != 6 {
+		t.Errorf("Validate() rows = %d, want 6", rows)
+	}
+
+	var got []string
+	for _, issue := range issues {
+		prefix := "error: "
+		if issue.Warning {
+			prefix = "warning: "
+		}
+		got = append(got, prefix+issue.String())
+	}
+	want := []string{
+		"error: row 2: has 4 fields, the header has 5 columns",
+		"error: row 3: duplicate test_id TC_001, first used in row 1",
+		"error: row 4: test_id is empty",
+		"error: row 5: test_name is empty",
+		`error: row 5: expected_status "abc" is not a status code such as 201 or a class such as 4xx`,
+		`error: row 6: expected_status "700" is not a status code such as 201 or a class such as 4xx`,
+		"error: collection references input_age, but there is no such column",
+		"warning: column input_emial is not referenced by the collection",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Validate() issues:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	issues, _, err = processor.Validate(createTempCSV(t, "test_id,name,name,\nTC_001,a,b,c\n"), Rules{Required: []string{"input_x"}})
+	if err != nil || len(issues) != 3 {
+		t.Errorf("Validate() header issues = %v, %v; want duplicate, unnamed and missing columns", issues, err)
+	}
+
+	// Every expected_* column is checked for values of its kind
+	content = "test_id,expected_body.user,expected_body.tags[0],expected_body.items[x],expected_header.Location,expected_header.Bad Name,expected_message\n" +
+		"TC_001,{\"id\": 1},vip,1,/users/1,x,{not json}\n" +
+		"TC_002,{id: 1},[1,2,\"/users/\n2\",x,any text\n" +
+		"TC_003,{{user}},Emily,3,{{location}},x,\n"
+	issues, _, err = processor.Validate(createTempCSV(t, content), Rules{})
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	got = nil
+	for _, issue := range issues {
+		got = append(got, issue.String())
+	}
+	want = []string{
+		"column expected_body.items[x] is not a body path such as expected_body.user.name or expected_body.items[0].id",
+		"column expected_header.Bad Name does not name a header such as expected_header.Content-Type",
+		`row 2: expected_body.user "{id: 1}" is not valid JSON`,
+		`row 2: expected_body.tags[0] "[1" is not valid JSON`,
+		"row 2: expected_header.Location holds a line break, which no header value can",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Validate() expected column issues:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
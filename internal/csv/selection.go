@@ -0,0 +1,181 @@
+package csv
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	rowPattern   = regexp.MustCompile(`^[0-9]+$`)
+	rangePattern = regexp.MustCompile(`^([0-9]+)-([0-9]*)$`)
+)
+
+// Selection is a parsed row selection: comma separated terms, each a row
+// (2), a range (2-5), an open range (5-), or a column filter
+// (test_id=valid_user, test_name~=email). A term prefixed with ! excludes
+// the rows it matches. Without an including term every row is included.
+type Selection struct {
+	text    string
+	include []term
+	exclude []term
+}
+
+// term is one term of a selection: a row range, or a column filter when
+// column is set.
+type term struct {
+	text string
+	// first and last are one based rows; last is 0 for an open range
+	first, last int
+	column      string
+	value       string
+	// contains makes the filter match a case-insensitive substring of value
+	contains bool
+}
+
+// ParseRowSelection parses a row selection such as "1,4-6,10", "5-", "!3"
+// or "test_id=valid_user".
+func (p *Processor) ParseRowSelection(rowSelection string) (*Selection, error) {
+	if strings.TrimSpace(rowSelection) == "" {
+		return nil, errors.New("empty row selection")
+	}
+
+	s := &Selection{text: rowSelection}
+	for _, part := range strings.Split(rowSelection, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		exclude := strings.HasPrefix(part, "!")
+		t, err := parseTerm(strings.TrimSpace(strings.TrimPrefix(part, "!")))
+		if err != nil {
+			return nil, err
+		}
+		if exclude {
+			s.exclude = append(s.exclude, t)
+		} else {
+			s.include = append(s.include, t)
+		}
+	}
+	if len(s.include) == 0 && len(s.exclude) == 0 {
+		return nil, errors.New("empty row selection")
+	}
+	return s, nil
+}
+
+func parseTerm(text string) (term, error) {
+	t := term{text: text}
+	if column, value, ok := strings.Cut(text, "~="); ok {
+		t.column, t.value, t.contains = strings.TrimSpace(column), strings.TrimSpace(value), true
+	} else if column, value, ok := strings.Cut(text, "="); ok {
+		t.column, t.value = strings.TrimSpace(column), strings.TrimSpace(value)
+	}
+	if t.column != "" {
+		return t, nil
+	}
+	if strings.Contains(text, "=") {
+		return term{}, fmt.Errorf("missing column name: %s", text)
+	}
+
+	if rowPattern.MatchString(text) {
+		row, err := strconv.Atoi(text)
+		if err != nil {
+			return term{}, fmt.Errorf("invalid number: %s", text)
+		}
+		t.first, t.last = row, row
+	} else if m := rangePattern.FindStringSubmatch(text); m != nil {
+		first, err := strconv.Atoi(m[1])
+		if err != nil {
+			return term{}, fmt.Errorf("invalid range: %s", text)
+		}
+		t.first = first
+		if m[2] != "" {
+			if t.last, err = strconv.Atoi(m[2]); err != nil {
+				return term{}, fmt.Errorf("invalid range: %s", text)
+			}
+			if t.first > t.last {
+				return term{}, fmt.Errorf("invalid range: start > end: %s", text)
+			}
+		}
+	} else {
+		return term{}, fmt.Errorf("invalid row selection format: %s", text)
+	}
+
+	if t.first < 1 {
+		return term{}, fmt.Errorf("rows are numbered from 1: %s", text)
+	}
+	return t, nil
+}
+
+// Rows returns the one based data rows the selection matches, sorted.
+// records[i] is data row i+1.
+// Rows past the end, unknown columns and a selection matching no rows are
+// errors.
+func (s *Selection) Rows(header []string, records [][]string) ([]int, error) {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+
+	var terms []term
+	terms = append(terms, s.include...)
+	terms = append(terms, s.exclude...)
+	for _, t := range terms {
+		if t.column != "" {
+			if _, ok := columns[t.column]; !ok {
+				return nil, fmt.Errorf("unknown column in row selection: %s. Available: %v", t.column, sortedColumns(columns))
+			}
+			continue
+		}
+		if t.first > len(records) || t.last > len(records) {
+			return nil, fmt.Errorf("row selection %s is out of range: the file has %d data rows", t.text, len(records))
+		}
+	}
+
+	var rows []int
+	for i, record := range records {
+		row := i + 1
+		included := len(s.include) == 0
+		for _, t := range s.include {
+			included = included || t.matches(row, record, columns)
+		}
+		for _, t := range s.exclude {
+			included = included && !t.matches(row, record, columns)
+		}
+		if included {
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("row selection %s matches no rows", s.text)
+	}
+	return rows, nil
+}
+
+func (t term) matches(row int, record []string, columns map[string]int) bool {
+	if t.column == "" {
+		return row >= t.first && (t.last == 0 || row <= t.last)
+	}
+
+	i := columns[t.column]
+	if i >= len(record) {
+		return false
+	}
+	value := strings.TrimSpace(record[i])
+	if t.contains {
+		return strings.Contains(strings.ToLower(value), strings.ToLower(t.value))
+	}
+	return value == t.value
+}
+
+func sortedColumns(columns map[string]int) []string {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,214 @@
+package csv
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ssd532/plaintest/internal/expectations"
+)
+
+// Column conventions of plaintest data files.
+const (
+	testIDColumn = "test_id"
+	inputPrefix  = "input_"
+)
+
+var (
+	statusClassPattern = regexp.MustCompile(`^[1-5][xX][xX]$`)
+	variablePattern    = regexp.MustCompile(`^\{\{[^{}]+\}\}$`)
+	// bodyPathPattern matches the path of an expected_body.* column: names
+	// separated by dots, each followed by any [index]
+	bodyPathPattern = regexp.MustCompile(`^[^.\[\]]+(\[\d+\])*(\.[^.\[\]]+(\[\d+\])*)*$`)
+	// headerNamePattern matches an HTTP header name
+	headerNamePattern = regexp.MustCompile("^[!#$%&'*+\\-.^_`|~0-9A-Za-z]+$")
+)
+
+// Rules configure Validate.
+type Rules struct {
+	// Required columns must not be empty. test_id always is, when present.
+	Required []string
+	// References are the variables and data fields the target collection
+	// uses, from collection.References. input_* columns are checked against
+	// them when CheckReferences is set.
+	References      []string
+	CheckReferences bool
+}
+
+// Issue is a problem Validate found in a data file.
+type Issue struct {
+	// Row is the one based data row, or 0 for the header and the file as a
+	// whole.
+	Row     int
+	Message string
+	// Warning marks issues that do not stop a run but likely are mistakes.
+	Warning bool
+}
+
+func (i Issue) String() string {
+	if i.Row == 0 {
+		return i.Message
+	}
+	return fmt.Sprintf("row %d: %s", i.Row, i.Message)
+}
+
+// Validate checks a CSV data file before it is run: every record has one
+// field per column, test_ids are unique, required columns are filled,
+// expected_* columns hold values of their kind (see checkExpected) and
+// input_* columns match what the collection references. It returns the issues found and the number of data
+// rows; the error is for files that cannot be read or parsed at all.
+func (p *Processor) Validate(csvFile string, rules Rules) ([]Issue, int, error) {
+	t, err := readTable(csvFile)
+	if err != nil {
+		return nil, 0, err
+	}
+	if t.headerRaw == nil {
+		return []Issue{{Message: "file is empty; the first row must name the columns"}}, 0, nil
+	}
+
+	var issues []Issue
+	columns := make(map[string]int, len(t.header))
+	for i, name := range t.header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		t.header[i] = name
+		switch _, dup := columns[name]; {
+		case name == "":
+			issues = append(issues, Issue{Message: fmt.Sprintf("column %d has no name", i+1)})
+		case dup:
+			issues = append(issues, Issue{Message: fmt.Sprintf("column %s appears more than once", name)})
+		default:
+			columns[name] = i
+		}
+	}
+
+	required := rules.Required
+	if _, ok := columns[testIDColumn]; ok && !slices.Contains(required, testIDColumn) {
+		required = append([]string{testIDColumn}, required...)
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			issues = append(issues, Issue{Message: fmt.Sprintf("required column %s is missing", name)})
+		}
+	}
+
+	expected := expectations.Columns(t.header)
+	for _, name := range expected {
+		if message := checkExpectedColumn(name); message != "" {
+			issues = append(issues, Issue{Message: message})
+		}
+	}
+
+	firstRow := make(map[string]int)
+	for i, record := range t.records {
+		row := i + 1
+		if len(record) != len(t.header) {
+			issues = append(issues, Issue{Row: row, Message: fmt.Sprintf("has %d fields, the header has %d columns", len(record), len(t.header))})
+		}
+		cell := func(name string) (string, bool) {
+			j, ok := columns[name]
+			if !ok || j >= len(record) {
+				return "", false
+			}
+			return strings.TrimSpace(record[j]), true
+		}
+
+		for _, name := range required {
+			if value, ok := cell(name); ok && value == "" {
+				issues = append(issues, Issue{Row: row, Message: fmt.Sprintf("%s is empty", name)})
+			}
+		}
+		if id, _ := cell(testIDColumn); id != "" {
+			if first, dup := firstRow[id]; dup {
+				issues = append(issues, Issue{Row: row, Message: fmt.Sprintf("duplicate test_id %s, first used in row %d", id, first)})
+			} else {
+				firstRow[id] = row
+			}
+		}
+		for _, name := range expected {
+			if value, _ := cell(name); value != "" {
+				if message := checkExpected(name, value); message != "" {
+					issues = append(issues, Issue{Row: row, Message: message})
+				}
+			}
+		}
+	}
+
+	if rules.CheckReferences {
+		issues = append(issues, checkReferences(t.header, rules.References)...)
+	}
+	return issues, len(t.records), nil
+}
+
+// checkReferences compares the input_* columns with the input_* variables
+// the collection references. A referenced variable without a column stays
+// unresolved, so it is an error; an unused column is a warning, as it is
+// often a misspelt name.
+func checkReferences(header, references []string) []Issue {
+	var issues []Issue
+	for _, name := range references {
+		if strings.HasPrefix(name, inputPrefix) && !slices.Contains(header, name) {
+			issues = append(issues, Issue{Message: fmt.Sprintf("collection references %s, but there is no such column", name)})
+		}
+	}
+	for _, name := range header {
+		if strings.HasPrefix(name, inputPrefix) && !slices.Contains(references, name) {
+			issues = append(issues, Issue{Message: fmt.Sprintf("column %s is not referenced by the collection", name), Warning: true})
+		}
+	}
+	return issues
+}
+
+// checkExpectedColumn returns what is wrong with the name of an expected_*
+// column, or "": a body path must be dotted names with [index]es, and a
+// header must have a valid header name.
+func checkExpectedColumn(name string) string {
+	switch {
+	case strings.HasPrefix(name, expectations.BodyPrefix):
+		if path := strings.TrimPrefix(name, expectations.BodyPrefix); !bodyPathPattern.MatchString(path) {
+			return fmt.Sprintf("column %s is not a body path such as %suser.name or %sitems[0].id", name, expectations.BodyPrefix, expectations.BodyPrefix)
+		}
+	case strings.HasPrefix(name, expectations.HeaderPrefix):
+		if header := strings.TrimPrefix(name, expectations.HeaderPrefix); !headerNamePattern.MatchString(header) {
+			return fmt.Sprintf("column %s does not name a header such as %sContent-Type", name, expectations.HeaderPrefix)
+		}
+	}
+	return ""
+}
+
+// checkExpected returns what is wrong with the value of an expected_* cell,
+// or "". expected_status holds a status code or class; expected_body.* a
+// JSON value when it starts like one, as other text is compared as a string;
+// and expected_header.* a header value, without line breaks. expected_message
+// may hold any text. Cells with {{variables}} are checked once resolved, at
+// run time.
+func checkExpected(name, value string) string {
+	switch {
+	case name == expectations.StatusColumn:
+		if !validStatus(value) {
+			return fmt.Sprintf("%s %q is not a status code such as 201 or a class such as 4xx", name, value)
+		}
+	case strings.Contains(value, "{{"):
+	case strings.HasPrefix(name, expectations.BodyPrefix):
+		if strings.ContainsAny(value[:1], `{["`) && !json.Valid([]byte(value)) {
+			return fmt.Sprintf("%s %q is not valid JSON", name, value)
+		}
+	case strings.HasPrefix(name, expectations.HeaderPrefix):
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Sprintf("%s holds a line break, which no header value can", name)
+		}
+	}
+	return ""
+}
+
+// validStatus reports whether value is an HTTP status code, a status class
+// or a {{variable}} resolved at run time.
+func validStatus(value string) bool {
+	if statusClassPattern.MatchString(value) || variablePattern.MatchString(value) {
+		return true
+	}
+	code, err := strconv.Atoi(value)
+	return err == nil && code >= 100 && code <= 599
+}
@@ -0,0 +1,263 @@
+// Package dataset reads iteration data files in every format plaintest
+// accepts: CSV, JSON arrays of objects and YAML lists of mappings.
+//
+// CSV files are handled by package csv. JSON and YAML rows are selected
+// here with the same row selection grammar, and YAML is converted to JSON,
+// the form Newman reads.
+package dataset
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ssd532/plaintest/internal/csv"
+	"github.com/ssd532/plaintest/internal/tempdir"
+	"gopkg.in/yaml.v3"
+)
+
+// Data file formats
+const (
+	CSV  = "csv"
+	JSON = "json"
+	YAML = "yaml"
+)
+
+// Extensions are the data file extensions, in the order discovery prefers
+// them when two files share a name.
+var Extensions = []string{".csv", ".json", ".yaml", ".yml"}
+
+// FormatOf returns the format of a data file from its extension. Unknown
+// extensions are read as CSV, as Newman does.
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON
+	case ".yaml", ".yml":
+		return YAML
+	default:
+		return CSV
+	}
+}
+
+// rows is a JSON or YAML data file: each row as JSON, and as named fields
+// for column filters.
+type rows struct {
+	items   []json.RawMessage
+	header  []string
+	records [][]string
+}
+
+// read loads the rows of a JSON or YAML data file.
+func read(path string) (*rows, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open data file: %w", err)
+	}
+
+	var items []json.RawMessage
+	if FormatOf(path) == YAML {
+		var values []any
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("invalid YAML data file %s: %w", path, err)
+		}
+		for i, value := range values {
+			item, err := json.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid YAML data file %s: row %d: %w", path, i+1, err)
+			}
+			items = append(items, item)
+		}
+	} else if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("invalid JSON data file %s: %w", path, err)
+	}
+
+	r := &rows{items: items}
+	columns := make(map[string]int)
+	for i, item := range items {
+		var fields map[string]any
+		if err := json.Unmarshal(item, &fields); err != nil || fields == nil {
+			return nil, fmt.Errorf("invalid data file %s: row %d is not an object", path, i+1)
+		}
+		record := make([]string, len(r.header))
+		for _, name := range sortedKeys(fields) {
+			j, ok := columns[name]
+			if !ok {
+				j = len(r.header)
+				columns[name] = j
+				r.header = append(r.header, name)
+				record = append(record, "")
+			}
+			record[j] = text(fields[name])
+		}
+		r.records = append(r.records, record)
+	}
+	return r, nil
+}
+
+// text returns a field the way a column filter compares it: strings as they
+// are, other values as JSON.
+func text(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// Count returns the number of rows in a data file.
+func Count(path string) (int, error) {
+	if FormatOf(path) == CSV {
+		return csv.NewProcessor().CountRows(path)
+	}
+	r, err := read(path)
+	if err != nil {
+		return 0, err
+	}
+	return len(r.items), nil
+}
+
+// SelectedRows returns the one based rows of a data file that Select keeps
+// for rowSelection, in file order.
+func SelectedRows(path, rowSelection string) ([]int, error) {
+	processor := csv.NewProcessor()
+	if FormatOf(path) == CSV {
+		return processor.SelectedRows(path, rowSelection)
+	}
+
+	selection, err := processor.ParseRowSelection(rowSelection)
+	if err != nil {
+		return nil, fmt.Errorf("invalid row selection: %w", err)
+	}
+	r, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	return selection.Rows(r.header, r.records)
+}
+
+// Select writes the rows of a data file matched by rowSelection to a
+// temporary file and returns its path. CSV stays CSV; JSON and YAML rows are
+// written as a JSON array.
+func Select(path, rowSelection string) (string, error) {
+	if FormatOf(path) == CSV {
+		return csv.NewProcessor().ProcessRows(path, rowSelection)
+	}
+
+	selected, err := SelectedRows(path, rowSelection)
+	if err != nil {
+		return "", err
+	}
+	r, err := read(path)
+	if err != nil {
+		return "", err
+	}
+	items := make([]json.RawMessage, len(selected))
+	for i, row := range selected {
+		items[i] = r.items[row-1]
+	}
+	return writeJSON("plaintest_rows_*.json", items)
+}
+
+// Split divides the rows of a data file into at most n consecutive ranges
+// of nearly equal size, each written by Select.
+func Split(path string, n int) ([]csv.Shard, error) {
+	if FormatOf(path) == CSV {
+		return csv.NewProcessor().Split(path, n)
+	}
+	count, err := Count(path)
+	if err != nil {
+		return nil, err
+	}
+	return csv.SplitRows(count, n, func(rowSelection string) (string, error) {
+		return Select(path, rowSelection)
+	})
+}
+
+// ToJSON converts a YAML data file to a temporary JSON file Newman can read,
+// and returns its path.
+func ToJSON(path string) (string, error) {
+	r, err := read(path)
+	if err != nil {
+		return "", err
+	}
+	return writeJSON("plaintest_data_*.json", r.items)
+}
+
+// Generate writes the data file at path to w with the text of each row passed
+// through expand, which gets the one based row. CSV stays CSV, keeping the
+// bytes expand leaves alone; JSON and YAML rows are written as a JSON array.
+func Generate(path string, w io.Writer, expand func(row int, text string) (string, error)) error {
+	if FormatOf(path) == CSV {
+		return csv.NewProcessor().Rewrite(path, w, expand)
+	}
+
+	r, err := read(path)
+	if err != nil {
+		return err
+	}
+	items := make([]json.RawMessage, len(r.items))
+	for i, item := range r.items {
+		text, err := expand(i+1, string(item))
+		if err != nil {
+			return fmt.Errorf("row %d: %w", i+1, err)
+		}
+		if !json.Valid([]byte(text)) {
+			return fmt.Errorf("row %d is not valid JSON once expanded", i+1)
+		}
+		items[i] = json.RawMessage(text)
+	}
+	return encodeJSON(w, items)
+}
+
+// writeJSON writes items as an indented JSON array to a new temporary file.
+func writeJSON(pattern string, items []json.RawMessage) (string, error) {
+	file, err := tempdir.CreateTemp(pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer file.Close()
+	if err := encodeJSON(file, items); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// encodeJSON writes items to w as an indented JSON array.
+func encodeJSON(w io.Writer, items []json.RawMessage) error {
+	if items == nil {
+		items = []json.RawMessage{}
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	if _, err := w.Write(out.Bytes()); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
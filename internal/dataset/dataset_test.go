@@ -0,0 +1,232 @@
+package dataset
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const usersJSON = `[
+  {"test_id": "TC_001", "test_name": "valid user", "input_body": {"name": "Ann", "tags": ["a"]}, "expected_status": 201},
+  {"test_id": "TC_002", "test_name": "invalid email", "input_body": {"email": "x"}, "expected_status": 400},
+  {"test_id": "TC_003", "test_name": "missing email", "expected_status": 400}
+]`
+
+const usersYAML = `- test_id: TC_001
+  test_name: valid user
+  input_body:
+    name: Ann
+    tags: [a]
+  expected_status: 201
+- test_id: TC_002
+  test_name: invalid email
+  input_body: {email: x}
+  expected_status: 400
+- test_id: TC_003
+  test_name: missing email
+  expected_status: 400
+`
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readItems returns the rows of a JSON data file.
+func readItems(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	defer os.Remove(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []map[string]any
+	if err := json.Unmarshal(data, &items); err != nil {
+		t.Fatalf("invalid JSON %s: %v", data, err)
+	}
+	return items
+}
+
+func TestFormatOf(t *testing.T) {
+	for path, want := range map[string]string{
+		"data/users.csv": CSV, "users.JSON": JSON, "users.yaml": YAML, "users.yml": YAML, "users.txt": CSV,
+	} {
+		if got := FormatOf(path); got != want {
+			t.Errorf("FormatOf(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	files := map[string]string{
+		"users.json": writeFile(t, "users.json", usersJSON),
+		"users.yaml": writeFile(t, "users.yaml", usersYAML),
+	}
+	for name, path := range files {
+		t.Run(name, func(t *testing.T) {
+			if count, err := Count(path); err != nil || count != 3 {
+				t.Errorf("Count() = %d, %v; want 3", count, err)
+			}
+
+			tests := []struct {
+				selection string
+				want      []string
+			}{
+				{"2-", []string{"TC_002", "TC_003"}},
+				{"!2", []string{"TC_001", "TC_003"}},
+				{"expected_status=400", []string{"TC_002", "TC_003"}},
+				{"test_name~=EMAIL,!3", []string{"TC_002"}},
+			}
+			for _, tt := range tests {
+				out, err := Select(path, tt.selection)
+				if err != nil {
+					t.Fatalf("Select(%q) error = %v", tt.selection, err)
+				}
+				if filepath.Ext(out) != ".json" {
+					t.Errorf("Select() wrote %s, want a .json file", out)
+				}
+				var ids []string
+				for _, item := range readItems(t, out) {
+					ids = append(ids, item["test_id"].(string))
+				}
+				if !reflect.DeepEqual(ids, tt.want) {
+					t.Errorf("Select(%q) = %v, want %v", tt.selection, ids, tt.want)
+				}
+			}
+
+			if _, err := Select(path, "4"); err == nil || !strings.Contains(err.Error(), "out of range") {
+				t.Errorf("Select(4) error = %v", err)
+			}
+			if _, err := Select(path, "input_email=x"); err == nil || !strings.Contains(err.Error(), "unknown column") {
+				t.Errorf("Select(input_email=x) error = %v", err)
+			}
+		})
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	out, err := ToJSON(writeFile(t, "users.yml", usersYAML))
+	if err != nil {
+		t.Fatalf("ToJSON() error = %v", err)
+	}
+	items := readItems(t, out)
+
+	// Nested values keep their structure and numbers stay numbers
+	want := map[string]any{"name": "Ann", "tags": []any{"a"}}
+	if len(items) != 3 || !reflect.DeepEqual(items[0]["input_body"], want) || items[0]["expected_status"] != float64(201) {
+		t.Errorf("ToJSON() = %v", items)
+	}
+
+	if _, err := ToJSON(writeFile(t, "bad.yaml", "test_id: TC_001\n")); err == nil {
+		t.Error("ToJSON() should fail for a mapping instead of a list")
+	}
+	if _, err := Count(writeFile(t, "bad.json", `[1, 2]`)); err == nil || !strings.Contains(err.Error(), "row 1 is not an object") {
+		t.Errorf("Count() error = %v", err)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	shards, err := Split(writeFile(t, "users.json", usersJSON), 2)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	var sizes []int
+	for _, shard := range shards {
+		sizes = append(sizes, len(readItems(t, shard.Path)))
+	}
+	if !reflect.DeepEqual(sizes, []int{2, 1}) || shards[1].First != 3 {
+		t.Errorf("Split() sizes = %v, shards = %+v", sizes, shards)
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	users := writeFile(t, "users.csv", "test_id,test_name,name,currency,expected_status\nU1,admin,Ann,EUR,200\nU2,guest,Bob,USD,\n")
+	currencies := writeFile(t, "currencies.yaml", "- currency: EUR\n  rate: 1\n- currency: JPY\n  rate: 161.5\n  expected_body.currency: JPY\n- currency: INR\n  rate: 90\n  expected_status: \"200\"\n")
+
+	out, err := Matrix([]string{users, currencies})
+	if err != nil {
+		t.Fatalf("Matrix() error = %v", err)
+	}
+	items := readItems(t, out)
+	if len(items) != 6 {
+		t.Fatalf("Matrix() = %d rows, want 6", len(items))
+	}
+
+	// The first file varies slowest; ids and names are derived
+	var ids []string
+	for _, item := range items {
+		ids = append(ids, item["test_id"].(string))
+	}
+	want := []string{"U1-currencies1", "U1-currencies2", "U1-currencies3", "U2-currencies1", "U2-currencies2", "U2-currencies3"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("Matrix() test_ids = %v, want %v", ids, want)
+	}
+	// Columns are prefixed with their file's name, expected columns are not
+	wantRow := map[string]any{
+		"test_id": "U1-currencies2", "test_name": "admin / currencies2",
+		"users.test_id": "U1", "users.test_name": "admin", "users.name": "Ann",
+		"users.currency": "EUR", "currencies.currency": "JPY", "currencies.rate": 161.5,
+		"expected_status": "200", "expected_body.currency": "JPY",
+	}
+	if !reflect.DeepEqual(items[1], wantRow) {
+		t.Errorf("Matrix() row 2 = %v, want %v", items[1], wantRow)
+	}
+	// A file may set an expected column that another leaves empty, or sets alike
+	for i, want := range map[int]string{2: "200", 4: "", 5: "200"} {
+		if got := items[i]["expected_status"]; got != want {
+			t.Errorf("Matrix() row %d expected_status = %v, want %q", i+1, got, want)
+		}
+	}
+
+	conflicting := writeFile(t, "locked.csv", "name,expected_status\nlocked,403\n")
+	if _, err := Matrix([]string{conflicting, currencies}); err == nil || !strings.Contains(err.Error(), "locked row 1 and currencies row 3 expect different expected_status: 403 and 200") {
+		t.Errorf("Matrix() with conflicting expectations error = %v", err)
+	}
+
+	if _, err := Matrix([]string{users, writeFile(t, "users.json", usersJSON)}); err == nil || !strings.Contains(err.Error(), "same name") {
+		t.Errorf("Matrix() with two users files error = %v", err)
+	}
+	if _, err := Matrix([]string{users, writeFile(t, "empty.csv", "currency\n")}); err == nil || !strings.Contains(err.Error(), "no rows") {
+		t.Errorf("Matrix() with an empty file error = %v", err)
+	}
+	if _, err := Matrix([]string{users}); err == nil {
+		t.Error("Matrix() should need two files")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	expand := func(row int, text string) (string, error) {
+		return strings.ReplaceAll(text, "{{$seq}}", fmt.Sprint(row)), nil
+	}
+
+	var out bytes.Buffer
+	err := Generate(writeFile(t, "users.csv", "test_id,input_email\r\n\"TC,1\",user{{$seq}}@test.com\r\nTC_2,user{{$seq}}@test.com\r\n"), &out, expand)
+	want := "test_id,input_email\r\n\"TC,1\",user1@test.com\r\nTC_2,user2@test.com\r\n"
+	if err != nil || out.String() != want {
+		t.Errorf("Generate(csv) = %q, %v; want %q", out.String(), err, want)
+	}
+
+	out.Reset()
+	if err := Generate(writeFile(t, "users.yaml", "- input_email: \"user{{$seq}}@test.com\"\n- input_email: \"user{{$seq}}@test.com\"\n"), &out, expand); err != nil {
+		t.Fatalf("Generate(yaml) error = %v", err)
+	}
+	var items []map[string]any
+	if err := json.Unmarshal(out.Bytes(), &items); err != nil || len(items) != 2 || items[1]["input_email"] != "user2@test.com" {
+		t.Errorf("Generate(yaml) = %s, %v", out.String(), err)
+	}
+
+	failing := func(row int, text string) (string, error) { return "", errors.New("unknown generator") }
+	if err := Generate(writeFile(t, "users.json", usersJSON), &out, failing); err == nil || !strings.Contains(err.Error(), "row 1: unknown generator") {
+		t.Errorf("Generate() error = %v", err)
+	}
+}
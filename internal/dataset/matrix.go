@@ -0,0 +1,215 @@
+package dataset
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ssd532/plaintest/internal/csv"
+	"github.com/ssd532/plaintest/internal/expectations"
+)
+
+// Columns the combined rows of a matrix derive from the files' own.
+const (
+	testIDColumn   = "test_id"
+	testNameColumn = "test_name"
+)
+
+// source is one data file of a matrix.
+type source struct {
+	name    string
+	columns []string
+	rows    []map[string]json.RawMessage
+}
+
+// Matrix writes the cross product of the rows of the data files at paths to
+// a temporary JSON file and returns its path. The rows of the first file vary
+// slowest.
+//
+// Each file is known by its base name, and its columns are prefixed with it,
+// as in users.currency, so that files may share column names. Expected
+// columns keep their names so that they are asserted; files that set one to
+// different values in the same combination are an error. Each combined row
+// gets a test_id of the files' test_ids joined with "-", and a test_name of
+// their test_names joined with " / ". A file without test_id contributes its
+// name and row number, such as currencies2.
+func Matrix(paths []string) (string, error) {
+	if len(paths) < 2 {
+		return "", errors.New("a matrix needs at least two data files")
+	}
+
+	sources := make([]*source, len(paths))
+	seen := make(map[string]string)
+	for i, path := range paths {
+		s, err := readSource(path)
+		if err != nil {
+			return "", err
+		}
+		if other, dup := seen[s.name]; dup {
+			return "", fmt.Errorf("data files %s and %s have the same name %s", other, path, s.name)
+		}
+		if len(s.rows) == 0 {
+			return "", fmt.Errorf("data file %s has no rows", path)
+		}
+		seen[s.name] = path
+		sources[i] = s
+	}
+
+	var items []json.RawMessage
+	picks := make([]int, len(sources))
+	for {
+		item, err := combine(sources, picks)
+		if err != nil {
+			return "", err
+		}
+		items = append(items, item)
+
+		// Advance the last file first, like an odometer
+		i := len(picks) - 1
+		for ; i >= 0; i-- {
+			picks[i]++
+			if picks[i] < len(sources[i].rows) {
+				break
+			}
+			picks[i] = 0
+		}
+		if i < 0 {
+			break
+		}
+	}
+	return writeJSON("plaintest_matrix_*.json", items)
+}
+
+// combine builds the row joining row picks[i] of every source.
+func combine(sources []*source, picks []int) (json.RawMessage, error) {
+	var ids, names []string
+	for i, s := range sources {
+		row := s.rows[picks[i]]
+		id := fieldText(row[testIDColumn])
+		if id == "" {
+			id = fmt.Sprintf("%s%d", s.name, picks[i]+1)
+		}
+		ids = append(ids, id)
+		if name := fieldText(row[testNameColumn]); name != "" {
+			names = append(names, name)
+		} else {
+			names = append(names, id)
+		}
+	}
+
+	var fields []string
+	values := make(map[string]json.RawMessage)
+	setField := func(name string, value json.RawMessage) {
+		if _, ok := values[name]; !ok {
+			fields = append(fields, name)
+		}
+		values[name] = value
+	}
+	setField(testIDColumn, jsonString(strings.Join(ids, "-")))
+	setField(testNameColumn, jsonString(strings.Join(names, " / ")))
+
+	// The source rows that set each expected column, for conflicts
+	setBy := make(map[string]string)
+	for i, s := range sources {
+		row := s.rows[picks[i]]
+		for _, column := range s.columns {
+			value, ok := row[column]
+			if !ok {
+				value = jsonString("")
+			}
+			if len(expectations.Columns([]string{column})) == 0 {
+				setField(s.name+"."+column, value)
+				continue
+			}
+
+			// An empty cell expects nothing, so another file may set it
+			here := fmt.Sprintf("%s row %d", s.name, picks[i]+1)
+			switch previous, set := values[column]; {
+			case !set:
+				setField(column, value)
+			case fieldText(value) == "":
+				continue
+			case setBy[column] != "" && fieldText(previous) != fieldText(value):
+				return nil, fmt.Errorf("%s and %s expect different %s: %s and %s",
+					setBy[column], here, column, fieldText(previous), fieldText(value))
+			default:
+				setField(column, value)
+			}
+			if fieldText(value) != "" {
+				setBy[column] = here
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, name := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(values[name])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// readSource reads the rows of a data file of any format.
+func readSource(path string) (*source, error) {
+	base := filepath.Base(path)
+	s := &source{name: strings.TrimSuffix(base, filepath.Ext(base))}
+
+	if FormatOf(path) == CSV {
+		header, records, err := csv.NewProcessor().Records(path)
+		if err != nil {
+			return nil, err
+		}
+		s.columns = header
+		for _, record := range records {
+			row := make(map[string]json.RawMessage, len(header))
+			for i, column := range header {
+				if i < len(record) {
+					row[column] = jsonString(record[i])
+				}
+			}
+			s.rows = append(s.rows, row)
+		}
+		return s, nil
+	}
+
+	r, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	s.columns = r.header
+	for _, item := range r.items {
+		var row map[string]json.RawMessage
+		if err := json.Unmarshal(item, &row); err != nil {
+			return nil, fmt.Errorf("invalid data file %s: %w", path, err)
+		}
+		s.rows = append(s.rows, row)
+	}
+	return s, nil
+}
+
+// fieldText returns a JSON value as a column filter sees it.
+func fieldText(raw json.RawMessage) string {
+	if raw == nil {
+		return ""
+	}
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return ""
+	}
+	return text(value)
+}
+
+func jsonString(s string) json.RawMessage {
+	data, _ := json.Marshal(s)
+	return data
+}
@@ -0,0 +1,212 @@
+// Package environment reads Postman environment files and layers them, so
+// that a base environment can be shared by per-stage overrides.
+package environment
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ssd532/plaintest/internal/tempdir"
+)
+
+// File is a Postman environment file.
+type File struct {
+	ID     string  `json:"id,omitempty"`
+	Name   string  `json:"name,omitempty"`
+	Values []Value `json:"values"`
+	Scope  string  `json:"_postman_variable_scope,omitempty"`
+}
+
+// Value is one variable of an environment file. Value keeps its JSON form,
+// so numbers and booleans stay what they were.
+type Value struct {
+	Key     string          `json:"key"`
+	Value   json.RawMessage `json:"value"`
+	Type    string          `json:"type,omitempty"`
+	Enabled *bool           `json:"enabled,omitempty"`
+}
+
+// Load reads the environment file at path.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read environment: %w", err)
+	}
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid environment %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// Variable is a variable of a merged environment.
+type Variable struct {
+	Key   string
+	Value json.RawMessage
+	Type  string
+	// Source is the file the value came from.
+	Source string
+}
+
+// Text returns the value as Postman shows it: strings as they are, other
+// values as JSON.
+func (v Variable) Text() string {
+	var s string
+	if err := json.Unmarshal(v.Value, &s); err == nil {
+		return s
+	}
+	return string(v.Value)
+}
+
+// Merged is the result of layering environment files.
+type Merged struct {
+	// Name is the name of the last file.
+	Name      string
+	Files     []string
+	Variables []Variable
+}
+
+// Merge layers the environment files at paths in order: a variable of a later
+// file replaces the one of an earlier file, keeping its place. Disabled
+// variables are left out, as Newman ignores them.
+func Merge(paths []string) (*Merged, error) {
+	m := &Merged{Files: paths}
+	index := make(map[string]int)
+	for _, path := range paths {
+		f, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		if f.Name != "" {
+			m.Name = f.Name
+		}
+		for _, v := range f.Values {
+			if v.Enabled != nil && !*v.Enabled {
+				continue
+			}
+			value := v.Value
+			if value == nil {
+				value = json.RawMessage(`""`)
+			}
+			variable := Variable{Key: v.Key, Value: value, Type: v.Type, Source: path}
+			if i, ok := index[v.Key]; ok {
+				m.Variables[i] = variable
+				continue
+			}
+			index[v.Key] = len(m.Variables)
+			m.Variables = append(m.Variables, variable)
+		}
+	}
+	return m, nil
+}
+
+// Write saves the merged environment to a new temporary file and returns its
+// path.
+func (m *Merged) Write() (string, error) {
+	enabled := true
+	f := File{Name: m.Name, Scope: "environment", Values: make([]Value, len(m.Variables))}
+	for i, v := range m.Variables {
+		f.Values[i] = Value{Key: v.Key, Value: v.Value, Type: v.Type, Enabled: &enabled}
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	file, err := tempdir.CreateTemp("plaintest_env_*.json")
+	if err != nil {
+		return "", fmt.Errorf("failed to create environment file: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write environment file: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// ParseVariable splits a key=value assignment. The value may be empty or
+// hold more = signs; the key may not be empty.
+func ParseVariable(s string) ([2]string, error) {
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return [2]string{}, fmt.Errorf("invalid variable %q: use key=value", s)
+	}
+	return [2]string{key, value}, nil
+}
+
+// LoadVariables reads a file of key=value lines, as in a .env file. Blank
+// lines and lines starting with # are skipped, an export prefix is allowed,
+// and a value in matching single or double quotes is unquoted.
+func LoadVariables(path string) ([][2]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read variables: %w", err)
+	}
+
+	var vars [][2]string
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pair, err := ParseVariable(strings.TrimPrefix(line, "export "))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+		value := strings.TrimSpace(pair[1])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		pair[1] = value
+		vars = append(vars, pair)
+	}
+	return vars, nil
+}
+
+// Kinds of Change
+const (
+	Added   = "added"
+	Changed = "changed"
+	Removed = "removed"
+)
+
+// Change is a difference of one variable between two environments.
+type Change struct {
+	Kind   string
+	Key    string
+	Before string
+	After  string
+}
+
+// Diff returns the variables added, changed or removed from before to after:
+// added and changed ones in the order of after, then removed ones in the
+// order of before.
+func Diff(before, after *Merged) []Change {
+	old := make(map[string]string, len(before.Variables))
+	for _, v := range before.Variables {
+		old[v.Key] = v.Text()
+	}
+
+	var changes []Change
+	current := make(map[string]bool, len(after.Variables))
+	for _, v := range after.Variables {
+		current[v.Key] = true
+		value, ok := old[v.Key]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Kind: Added, Key: v.Key, After: v.Text()})
+		case value != v.Text():
+			changes = append(changes, Change{Kind: Changed, Key: v.Key, Before: value, After: v.Text()})
+		}
+	}
+	for _, v := range before.Variables {
+		if !current[v.Key] {
+			changes = append(changes, Change{Kind: Removed, Key: v.Key, Before: v.Text()})
+		}
+	}
+	return changes
+}
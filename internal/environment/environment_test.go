@@ -0,0 +1,125 @@
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMerge(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "base.json", `{"name": "base", "values": [
+		{"key": "base_url", "value": "http://localhost", "enabled": true},
+		{"key": "timeout", "value": 5000},
+		{"key": "debug", "value": "true"}
+	]}`)
+	staging := writeFile(t, dir, "staging.json", `{"name": "staging", "values": [
+		{"key": "base_url", "value": "https://staging.example.com"},
+		{"key": "token", "value": "${STAGING_TOKEN}", "type": "secret"},
+		{"key": "debug", "value": "false", "enabled": false}
+	]}`)
+
+	m, err := Merge([]string{base, staging})
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+
+	// Later files win, variables keep their first place, disabled ones are ignored
+	type row struct{ key, value, source string }
+	var got []row
+	for _, v := range m.Variables {
+		got = append(got, row{v.Key, v.Text(), filepath.Base(v.Source)})
+	}
+	want := []row{
+		{"base_url", "https://staging.example.com", "staging.json"},
+		{"timeout", "5000", "base.json"},
+		{"debug", "true", "base.json"},
+		{"token", "${STAGING_TOKEN}", "staging.json"},
+	}
+	if m.Name != "staging" || !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge() = %s %v, want %v", m.Name, got, want)
+	}
+
+	path, err := m.Write()
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	defer os.Remove(path)
+	written, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written.Values) != 4 || string(written.Values[1].Value) != "5000" || written.Values[3].Type != "secret" {
+		t.Errorf("Write() = %+v", written)
+	}
+
+	if _, err := Merge([]string{base, filepath.Join(dir, "missing.json")}); err == nil {
+		t.Error("Merge() should fail for a missing file")
+	}
+}
+
+func TestLoadVariables(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "vars.env", `# Pinned for the demo
+base_url=https://demo.example.com
+export TOKEN="abc=def"
+empty=
+quoted = 'two words'
+`)
+	vars, err := LoadVariables(path)
+	want := [][2]string{{"base_url", "https://demo.example.com"}, {"TOKEN", "abc=def"}, {"empty", ""}, {"quoted", "two words"}}
+	if err != nil || !reflect.DeepEqual(vars, want) {
+		t.Errorf("LoadVariables() = %q, %v; want %q", vars, err, want)
+	}
+
+	if _, err := LoadVariables(writeFile(t, t.TempDir(), "bad.env", "ok=1\nnovalue\n")); err == nil || !strings.Contains(err.Error(), "bad.env:2") {
+		t.Errorf("LoadVariables(bad) error = %v", err)
+	}
+	if _, err := ParseVariable("=x"); err == nil {
+		t.Error("ParseVariable() should reject an empty key")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	before := writeFile(t, dir, "env_1_auth.json", `{"values": [
+		{"key": "base_url", "value": "http://localhost"},
+		{"key": "auth_token", "value": ""},
+		{"key": "user_id", "value": 7}
+	]}`)
+	after := writeFile(t, dir, "env_2_users.json", `{"values": [
+		{"key": "base_url", "value": "http://localhost"},
+		{"key": "auth_token", "value": "abc"},
+		{"key": "order_id", "value": "o-1"}
+	]}`)
+
+	b, err := Merge([]string{before})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := Merge([]string{after})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{Kind: Changed, Key: "auth_token", Before: "", After: "abc"},
+		{Kind: Added, Key: "order_id", After: "o-1"},
+		{Kind: Removed, Key: "user_id", Before: "7"},
+	}
+	if got := Diff(b, a); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %+v, want %+v", got, want)
+	}
+	if got := Diff(a, a); len(got) != 0 {
+		t.Errorf("Diff(same) = %+v", got)
+	}
+}
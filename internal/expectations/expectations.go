@@ -0,0 +1,263 @@
+// Package expectations turns the expected_* columns of a data file into test
+// assertions, so a data-driven test needs only a data row and a request.
+//
+// The assertions are a test script added to the last request a link runs, in
+// a copy of the collection. Newman and the native engine both run it once per
+// iteration, after the requests before it; empty cells assert nothing.
+package expectations
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ssd532/plaintest/internal/collection"
+	"github.com/ssd532/plaintest/internal/tempdir"
+)
+
+// Column names and prefixes plaintest asserts on.
+const (
+	// StatusColumn holds the response status code, such as 201 or 4xx.
+	StatusColumn = "expected_status"
+	// MessageColumn holds text the response body must contain.
+	MessageColumn = "expected_message"
+	// BodyPrefix is followed by a path into the JSON response, such as
+	// expected_body.user.name or expected_body.items[0].id.
+	BodyPrefix = "expected_body."
+	// HeaderPrefix is followed by a response header name.
+	HeaderPrefix = "expected_header."
+)
+
+// Columns returns the columns of header that plaintest asserts on, in order.
+func Columns(header []string) []string {
+	var columns []string
+	for _, name := range header {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == StatusColumn, name == MessageColumn:
+		case strings.HasPrefix(name, BodyPrefix) && len(name) > len(BodyPrefix):
+		case strings.HasPrefix(name, HeaderPrefix) && len(name) > len(HeaderPrefix):
+		default:
+			continue
+		}
+		columns = append(columns, name)
+	}
+	return columns
+}
+
+// ReadColumns returns the assertion columns of a CSV or JSON data file.
+func ReadColumns(dataPath string) ([]string, error) {
+	file, err := os.Open(dataPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open data file: %w", err)
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(dataPath), ".json") {
+		var rows []map[string]any
+		if err := json.NewDecoder(file).Decode(&rows); err != nil {
+			return nil, fmt.Errorf("invalid JSON data file: %w", err)
+		}
+		seen := make(map[string]bool)
+		var header []string
+		for _, row := range rows {
+			for key := range row {
+				if !seen[key] {
+					seen[key] = true
+					header = append(header, key)
+				}
+			}
+		}
+		sort.Strings(header)
+		return Columns(header), nil
+	}
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV data file: %w", err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	return Columns(header), nil
+}
+
+// Inject writes a copy of the collection at collectionPath with a test script
+// asserting the expected_* columns of the data file on the last request that
+// runs; folders are the link's --folder selections. Columns that one of the
+// collection's own scripts already reads are left to that script.
+//
+// It returns the path of the copy and the columns it asserts, or an empty
+// path when there is nothing to assert. The caller removes the copy.
+func Inject(collectionPath, dataPath string, folders []string) (string, []string, error) {
+	columns, err := ReadColumns(dataPath)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(columns) == 0 {
+		return "", nil, nil
+	}
+
+	coll, err := collection.Load(collectionPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	columns = unhandled(coll, columns)
+	target := lastRequest(coll, folders)
+	if len(columns) == 0 || target == nil {
+		return "", nil, nil
+	}
+
+	target.Events = append(target.Events, collection.Event{
+		Listen: "test",
+		Script: &collection.Script{Type: "text/javascript", Exec: strings.Split(Script(columns), "\n")},
+	})
+
+	file, err := tempdir.CreateTemp("plaintest_collection_*" + collection.FileSuffix)
+	if err != nil {
+		return "", nil, fmt.Errorf("creating collection copy: %w", err)
+	}
+	path := file.Name()
+	file.Close()
+
+	if err := coll.Save(path); err != nil {
+		os.Remove(path)
+		return "", nil, err
+	}
+	return path, columns, nil
+}
+
+// lastRequest returns the last request of coll that runs with folders, as
+// Newman's --folder selects them: a named folder runs everything in it.
+func lastRequest(coll *collection.Collection, folders []string) *collection.Item {
+	var last *collection.Item
+	_ = coll.Walk(func(path []string, item *collection.Item) error {
+		if item.IsFolder() || item.Request == nil {
+			return nil
+		}
+		if len(folders) == 0 || selected(path, folders) {
+			last = item
+		}
+		return nil
+	})
+	return last
+}
+
+// selected reports whether an item or one of its folders is named in folders.
+func selected(path, folders []string) bool {
+	for _, name := range path {
+		for _, folder := range folders {
+			if name == folder {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unhandled drops the columns a collection script already reads with a
+// pm.*.get('<column>') call.
+func unhandled(coll *collection.Collection, columns []string) []string {
+	var sources []string
+	collect := func(events []collection.Event) {
+		for _, evt := range events {
+			sources = append(sources, evt.Script.Source())
+		}
+	}
+	collect(coll.Events)
+	_ = coll.Walk(func(_ []string, item *collection.Item) error {
+		collect(item.Events)
+		return nil
+	})
+
+	var result []string
+	for _, column := range columns {
+		read := regexp.MustCompile(`pm\.\w+\.get\(\s*['"` + "`" + `]` + regexp.QuoteMeta(column) + `['"` + "`" + `]\s*\)`)
+		used := false
+		for _, source := range sources {
+			if read.MatchString(source) {
+				used = true
+				break
+			}
+		}
+		if !used {
+			result = append(result, column)
+		}
+	}
+	return result
+}
+
+// Script returns the JavaScript test script asserting columns.
+func Script(columns []string) string {
+	data, _ := json.Marshal(columns)
+	return fmt.Sprintf(scriptTemplate, data)
+}
+
+const scriptTemplate = `// Added by plaintest: assertions for the expected_* data columns
+(function () {
+    var columns = %s;
+
+    function lookup(value, path) {
+        var parts = path.replace(/\[(\d+)\]/g, '.$1').split('.');
+        for (var i = 0; i < parts.length; i++) {
+            if (value === null || typeof value !== 'object' || !(parts[i] in value)) {
+                throw new Error('response body has no ' + path);
+            }
+            value = value[parts[i]];
+        }
+        return value;
+    }
+
+    columns.forEach(function (column) {
+        var expected = pm.iterationData.get(column);
+        if (expected === undefined || expected === null || String(expected) === '') {
+            return;
+        }
+        expected = pm.variables.replaceIn(String(expected));
+
+        if (column === 'expected_status') {
+            pm.test('Status code is ' + expected, function () {
+                if (/^[1-5]xx$/i.test(expected)) {
+                    pm.expect(Math.floor(pm.response.code / 100), 'status class').to.equal(Number(expected[0]));
+                } else {
+                    pm.response.to.have.status(parseInt(expected, 10));
+                }
+            });
+        } else if (column === 'expected_message') {
+            pm.test('Response contains "' + expected + '"', function () {
+                pm.expect(pm.response.text()).to.include(expected);
+            });
+        } else if (column.indexOf('expected_header.') === 0) {
+            var name = column.slice('expected_header.'.length);
+            pm.test('Header ' + name + ' is ' + expected, function () {
+                pm.response.to.have.header(name, expected);
+            });
+        } else if (column.indexOf('expected_body.') === 0) {
+            var path = column.slice('expected_body.'.length);
+            pm.test('Body ' + path + ' is ' + expected, function () {
+                var actual = lookup(pm.response.json(), path);
+                if (typeof actual === 'string') {
+                    pm.expect(actual).to.equal(expected);
+                    return;
+                }
+                var parsed = expected;
+                try {
+                    parsed = JSON.parse(expected);
+                } catch (e) {}
+                pm.expect(actual).to.eql(parsed);
+            });
+        }
+    });
+})();`
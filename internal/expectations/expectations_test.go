@@ -0,0 +1,201 @@
+package expectations
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ssd532/plaintest/internal/collection"
+	"github.com/ssd532/plaintest/internal/native"
+	"github.com/ssd532/plaintest/internal/sandbox"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestColumns(t *testing.T) {
+	header := []string{"test_id", "expected_status", "expected_body.user.name", "expected_body.", "expected_header.Content-Type", "expected_message", "expected_total"}
+	want := []string{"expected_status", "expected_body.user.name", "expected_header.Content-Type", "expected_message"}
+	if got := Columns(header); !reflect.DeepEqual(got, want) {
+		t.Errorf("Columns() = %v, want %v", got, want)
+	}
+}
+
+func TestReadColumns(t *testing.T) {
+	dir := t.TempDir()
+
+	csvPath := writeFile(t, dir, "users.csv", "\ufefftest_id,expected_status,expected_body.id\nTC_001,200,1\n")
+	got, err := ReadColumns(csvPath)
+	if err != nil || !reflect.DeepEqual(got, []string{"expected_status", "expected_body.id"}) {
+		t.Errorf("ReadColumns(csv) = %v, %v", got, err)
+	}
+
+	jsonPath := writeFile(t, dir, "users.json", `[{"test_id": "TC_001", "expected_status": 200}, {"expected_message": "created"}]`)
+	got, err = ReadColumns(jsonPath)
+	if err != nil || !reflect.DeepEqual(got, []string{"expected_message", "expected_status"}) {
+		t.Errorf("ReadColumns(json) = %v, %v", got, err)
+	}
+}
+
+func TestInject(t *testing.T) {
+	dir := t.TempDir()
+	collectionPath := writeFile(t, dir, "users.postman_collection.json", `{
+	"info": {"name": "Users"},
+	"item": [{
+		"name": "Users",
+		"item": [{
+			"name": "Create User",
+			"request": {"method": "POST", "url": "https://example.com/users"},
+			"event": [{"listen": "test", "script": {"exec": [
+				"// expected_header.Location is checked by the API gateway",
+				"pm.expect(pm.response.code).to.equal(+pm.iterationData.get('expected_status'));",
+				"pm.expect(pm.response.json().identifier).to.equal(pm.iterationData.get(\"expected_body.identifier\"));"
+			]}}]
+		}, {
+			"name": "Get User",
+			"request": "https://example.com/users/1"
+		}]
+	}, {
+		"name": "Health",
+		"request": "https://example.com/health"
+	}]
+}`)
+
+	// Columns the collection's scripts already read are left alone
+	dataPath := writeFile(t, dir, "handled.csv", "test_id,expected_status\nTC_001,200\n")
+	path, columns, err := Inject(collectionPath, dataPath, nil)
+	if err != nil || path != "" || columns != nil {
+		t.Errorf("Inject() = %q, %v, %v; want nothing to inject", path, columns, err)
+	}
+
+	// A column only mentioned in a comment, or a prefix of a column read, is asserted
+	dataPath = writeFile(t, dir, "users.csv", "test_id,expected_status,expected_body.id,expected_header.Location\nTC_001,201,7,/users/7\n")
+	path, columns, err = Inject(collectionPath, dataPath, []string{"Users"})
+	if err != nil {
+		t.Fatalf("Inject() error = %v", err)
+	}
+	defer os.Remove(path)
+	if !reflect.DeepEqual(columns, []string{"expected_body.id", "expected_header.Location"}) {
+		t.Errorf("Inject() columns = %v", columns)
+	}
+
+	// The script goes on the last request of the selected folder only
+	coll, err := collection.Load(path)
+	if err != nil {
+		t.Fatalf("injected collection does not load: %v", err)
+	}
+	target := coll.Find("Get User")
+	if len(coll.Events) != 0 || len(coll.Find("Create User").Events) != 1 || len(coll.Find("Health").Events) != 0 {
+		t.Errorf("Inject() added scripts outside the last request")
+	}
+	if len(target.Events) != 1 || target.Events[0].Listen != "test" || target.Events[0].Script.Source() != Script(columns) {
+		t.Errorf("Get User events = %+v", target.Events)
+	}
+	if original, _ := os.ReadFile(collectionPath); strings.Contains(string(original), "plaintest") {
+		t.Error("the original collection must not change")
+	}
+
+	// Without folders the last request of the collection is asserted
+	path, _, err = Inject(collectionPath, dataPath, nil)
+	if err != nil {
+		t.Fatalf("Inject() error = %v", err)
+	}
+	defer os.Remove(path)
+	if coll, err = collection.Load(path); err != nil || len(coll.Find("Health").Events) != 1 || len(coll.Find("Get User").Events) != 0 {
+		t.Errorf("Inject(no folders) did not assert on the last request: %v", err)
+	}
+}
+
+func TestScript(t *testing.T) {
+	columns := []string{"expected_status", "expected_body.user.name", "expected_body.items[1]", "expected_body.active", "expected_header.Content-Type", "expected_message"}
+	response := &native.Response{
+		Code:   201,
+		Status: "Created",
+		Header: http.Header{"Content-Type": {"application/json"}},
+		Body:   []byte(`{"user": {"name": "Emily"}, "items": [1, {"id": 2}], "active": true, "message": "user created"}`),
+	}
+
+	tests := []struct {
+		name    string
+		columns []string
+		row     map[string]string
+		want    map[string]bool
+	}{
+		{
+			name:    "all columns match",
+			columns: columns,
+			row: map[string]string{
+				"expected_status":              "201",
+				"expected_body.user.name":      "{{name}}",
+				"expected_body.items[1]":       `{"id": 2}`,
+				"expected_body.active":         "true",
+				"expected_header.Content-Type": "application/json",
+				"expected_message":             "created",
+			},
+			want: map[string]bool{
+				"Status code is 201":                      true,
+				"Body user.name is Emily":                 true,
+				`Body items[1] is {"id": 2}`:              true,
+				"Body active is true":                     true,
+				"Header Content-Type is application/json": true,
+				`Response contains "created"`:             true,
+			},
+		},
+		{
+			name:    "mismatches fail and empty cells are skipped",
+			columns: columns,
+			row: map[string]string{
+				"expected_status":         "4xx",
+				"expected_body.user.name": "Bob",
+				"expected_body.active":    "",
+			},
+			want: map[string]bool{
+				"Status code is 4xx":    false,
+				"Body user.name is Bob": false,
+			},
+		},
+		{
+			name:    "missing body path fails",
+			columns: []string{"expected_status", "expected_body.user.email"},
+			row:     map[string]string{"expected_status": "2xx", "expected_body.user.email": "x"},
+			want:    map[string]bool{"Status code is 2xx": true, "Body user.email is x": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &native.ScriptContext{
+				Listen:    "test",
+				Request:   &collection.Request{Method: "GET", URL: &collection.URL{Raw: "https://example.com"}},
+				Response:  response,
+				Variables: native.NewVariables(),
+			}
+			ctx.Variables.Environment.Set("name", "Emily")
+			for key, value := range tt.row {
+				ctx.Variables.Data.Set(key, value)
+			}
+
+			results, err := sandbox.New().Run(Script(tt.columns), ctx)
+			if err != nil {
+				t.Fatalf("script error: %v", err)
+			}
+
+			got := make(map[string]bool)
+			for _, r := range results {
+				got[r.Name] = r.Passed
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("results = %+v, want %v", results, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+// Package generate expands the generator expressions of data files, such as
+// {{$fake.email}}, {{$seq}}, {{$uuid}} and {{$now+1d}}, so that every run can
+// send values no earlier run used. A seed makes the output reproducible.
+//
+// Other {{$name}} placeholders, like Postman's {{$guid}}, are left for the
+// engine to resolve.
+package generate
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// expression matches a generator expression; group 1 is its name.
+var expression = regexp.MustCompile(`\{\{\s*\$(fake\.[A-Za-z]*|seq|uuid|now(?:[+-][^{}\s]*)?)\s*\}\}`)
+
+// offsetPattern matches the offset of {{$now+1d}}.
+var offsetPattern = regexp.MustCompile(`^([+-])(\d+)([smhdw])$`)
+
+var (
+	firstNames = []string{"Ann", "Ravi", "Maria", "Chen", "Fatima", "John", "Priya", "Lucas", "Aiko", "Omar", "Sara", "David"}
+	lastNames  = []string{"Smith", "Patel", "Garcia", "Wang", "Khan", "Brown", "Sharma", "Silva", "Tanaka", "Hassan", "Jones", "Miller"}
+	companies  = []string{"Acme", "Globex", "Initech", "Umbrella", "Stark", "Wayne", "Hooli", "Vandelay"}
+	suffixes   = []string{"Inc", "Ltd", "Labs", "Systems", "Group"}
+	streets    = []string{"Oak", "Maple", "Park", "Lake", "Hill", "Station", "Church", "Mill"}
+	cities     = []string{"Pune", "Berlin", "Austin", "Lyon", "Osaka", "Toronto", "Lisbon", "Nairobi"}
+	countries  = []string{"India", "Germany", "United States", "France", "Japan", "Canada", "Portugal", "Kenya"}
+	words      = []string{"alpha", "bravo", "delta", "echo", "lima", "nova", "orbit", "pixel", "quartz", "sierra"}
+)
+
+// fakes are the {{$fake.<name>}} generators. Their values never hold commas,
+// quotes, backslashes or line breaks, so they can be put into a CSV record or
+// a JSON string as they are.
+var fakes = map[string]func(g *Generator) string{
+	"firstName": func(g *Generator) string { return g.pick(firstNames) },
+	"lastName":  func(g *Generator) string { return g.pick(lastNames) },
+	"name":      func(g *Generator) string { return g.pick(firstNames) + " " + g.pick(lastNames) },
+	"email": func(g *Generator) string {
+		return fmt.Sprintf("%s.%s%s@example.com", strings.ToLower(g.pick(firstNames)), strings.ToLower(g.pick(lastNames)), g.digits(6))
+	},
+	"username": func(g *Generator) string { return strings.ToLower(g.pick(firstNames)) + "_" + g.digits(6) },
+	"phone":    func(g *Generator) string { return "+1555" + g.digits(7) },
+	"company":  func(g *Generator) string { return g.pick(companies) + " " + g.pick(suffixes) },
+	"street":   func(g *Generator) string { return fmt.Sprintf("%d %s Street", 1+g.rand.Intn(999), g.pick(streets)) },
+	"city":     func(g *Generator) string { return g.pick(cities) },
+	"country":  func(g *Generator) string { return g.pick(countries) },
+	"zip":      func(g *Generator) string { return g.digits(5) },
+	"word":     func(g *Generator) string { return g.pick(words) },
+	"number":   func(g *Generator) string { return strconv.Itoa(g.rand.Intn(10000)) },
+	"boolean":  func(g *Generator) string { return strconv.FormatBool(g.rand.Intn(2) == 1) },
+}
+
+// Generator expands generator expressions. Values come from a random source
+// seeded once, so the same seed and data give the same values, apart from
+// {{$now}}.
+type Generator struct {
+	seed int64
+	rand *rand.Rand
+	now  time.Time
+}
+
+// New returns a Generator for seed. {{$now}} is now.
+func New(seed int64, now time.Time) *Generator {
+	return &Generator{seed: seed, rand: rand.New(rand.NewSource(seed)), now: now}
+}
+
+// Seed returns the seed the Generator was created with.
+func (g *Generator) Seed() int64 {
+	return g.seed
+}
+
+// Contains reports whether text holds a generator expression.
+func Contains(text string) bool {
+	return expression.MatchString(text)
+}
+
+// Fakes returns the names of the {{$fake.<name>}} generators, sorted.
+func Fakes() []string {
+	names := make([]string, 0, len(fakes))
+	for name := range fakes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Expand replaces the generator expressions in text, the content of data row
+// row (one based):
+//
+//	{{$fake.email}}  a value from a fake generator, see Fakes
+//	{{$seq}}         the row number
+//	{{$uuid}}        a random version 4 UUID
+//	{{$now}}         the current time in RFC 3339, UTC
+//	{{$now+1d}}      the current time moved by s, m, h, d or w units
+func (g *Generator) Expand(row int, text string) (string, error) {
+	var err error
+	expanded := expression.ReplaceAllStringFunc(text, func(match string) string {
+		if err != nil {
+			return match
+		}
+		var value string
+		value, err = g.value(row, expression.FindStringSubmatch(match)[1])
+		return value
+	})
+	return expanded, err
+}
+
+func (g *Generator) value(row int, name string) (string, error) {
+	switch {
+	case name == "seq":
+		return strconv.Itoa(row), nil
+	case name == "uuid":
+		return g.uuid(), nil
+	case strings.HasPrefix(name, "now"):
+		return g.time(strings.TrimPrefix(name, "now"))
+	}
+
+	fake, ok := fakes[strings.TrimPrefix(name, "fake.")]
+	if !ok {
+		return "", fmt.Errorf("unknown generator {{$%s}}. Available fakes: %v", name, Fakes())
+	}
+	return fake(g), nil
+}
+
+// time returns now moved by offset, such as +1d or -2h.
+func (g *Generator) time(offset string) (string, error) {
+	t := g.now.UTC()
+	if offset != "" {
+		m := offsetPattern.FindStringSubmatch(offset)
+		if m == nil {
+			return "", fmt.Errorf("invalid offset in {{$now%s}}: use a sign, a number and s, m, h, d or w, as in {{$now+1d}}", offset)
+		}
+		n, err := strconv.Atoi(m[2])
+		if err != nil {
+			return "", fmt.Errorf("invalid offset in {{$now%s}}: %w", offset, err)
+		}
+		if m[1] == "-" {
+			n = -n
+		}
+		switch m[3] {
+		case "s":
+			t = t.Add(time.Duration(n) * time.Second)
+		case "m":
+			t = t.Add(time.Duration(n) * time.Minute)
+		case "h":
+			t = t.Add(time.Duration(n) * time.Hour)
+		case "d":
+			t = t.AddDate(0, 0, n)
+		case "w":
+			t = t.AddDate(0, 0, 7*n)
+		}
+	}
+	return t.Format(time.RFC3339), nil
+}
+
+func (g *Generator) uuid() string {
+	b := make([]byte, 16)
+	g.rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func (g *Generator) pick(values []string) string {
+	return values[g.rand.Intn(len(values))]
+}
+
+func (g *Generator) digits(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte('0' + g.rand.Intn(10))
+	}
+	return string(b)
+}
@@ -0,0 +1,87 @@
+package generate
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func TestExpand(t *testing.T) {
+	g := New(42, now)
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"user{{$seq}},{{ $now }}", "user3,2024-05-01T10:00:00Z"},
+		{"{{$now+1d}} {{$now-2h}} {{$now+1w}}", "2024-05-02T10:00:00Z 2024-05-01T08:00:00Z 2024-05-08T10:00:00Z"},
+		// Postman dynamic variables and other placeholders are left alone
+		{"{{$guid}} {{base_url}} {{$randomEmail}}", "{{$guid}} {{base_url}} {{$randomEmail}}"},
+	}
+	for _, tt := range tests {
+		got, err := g.Expand(3, tt.text)
+		if err != nil || got != tt.want {
+			t.Errorf("Expand(%q) = %q, %v; want %q", tt.text, got, err, tt.want)
+		}
+	}
+
+	patterns := map[string]string{
+		"{{$uuid}}":           `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`,
+		"{{$fake.email}}":     `^[a-z]+\.[a-z]+\d{6}@example\.com$`,
+		"{{$fake.phone}}":     `^\+1555\d{7}$`,
+		"{{$fake.name}}":      `^[A-Z][a-z]+ [A-Z][a-z]+$`,
+		"{{$fake.number}}":    `^\d+$`,
+		"{{$fake.boolean}}":   `^(true|false)$`,
+		"{{$fake.street}}":    `^\d+ [A-Z][a-z]+ Street$`,
+		"{{$fake.username}}":  `^[a-z]+_\d{6}$`,
+		"{{$fake.firstName}}": `^[A-Z][a-z]+$`,
+	}
+	for text, pattern := range patterns {
+		got, err := g.Expand(1, text)
+		if err != nil || !regexp.MustCompile(pattern).MatchString(got) {
+			t.Errorf("Expand(%q) = %q, %v; want a match of %s", text, got, err, pattern)
+		}
+	}
+}
+
+func TestExpand_Seed(t *testing.T) {
+	text := "{{$fake.email}},{{$uuid}},{{$fake.company}}"
+	expand := func(seed int64) string {
+		g := New(seed, now)
+		var rows []string
+		for row := 1; row <= 3; row++ {
+			got, err := g.Expand(row, text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows = append(rows, got)
+		}
+		return strings.Join(rows, "\n")
+	}
+
+	if a, b := expand(7), expand(7); a != b {
+		t.Errorf("the same seed gave different values:\n%s\n%s", a, b)
+	}
+	if a, b := expand(7), expand(8); a == b {
+		t.Errorf("different seeds gave the same values:\n%s", a)
+	}
+}
+
+func TestExpand_Errors(t *testing.T) {
+	g := New(1, now)
+	for text, want := range map[string]string{
+		"{{$fake.colour}}": "unknown generator {{$fake.colour}}",
+		"{{$now+1y}}":      "invalid offset in {{$now+1y}}",
+		"{{$now+d}}":       "invalid offset",
+	} {
+		if _, err := g.Expand(1, text); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expand(%q) error = %v, want %q", text, err, want)
+		}
+	}
+
+	if !Contains("a,{{$seq}}") || Contains("a,{{$guid}},{{seq}}") {
+		t.Error("Contains() should only match generator expressions")
+	}
+}
@@ -0,0 +1,198 @@
+// Package manifest records what a plaintest run did, so that plaintest rerun
+// can repeat it, or only the rows and requests that failed.
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Path is where the manifest of the last run is kept, relative to the
+// project root.
+var Path = filepath.Join(".plaintest", "last_run.json")
+
+// Link statuses
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped" // Not run because an earlier link failed
+)
+
+// Manifest describes one plaintest run.
+type Manifest struct {
+	Started  time.Time `json:"started"`
+	Engine   string    `json:"engine"`
+	NoExpect bool      `json:"noExpect,omitempty"`
+	// Matrix is set when the data files in Flags were combined with
+	// --matrix; row numbers then refer to the combined rows.
+	Matrix bool `json:"matrix,omitempty"`
+	// Seed is the seed generator expressions in the data were expanded
+	// with, so a rerun sends the same values.
+	Seed int64 `json:"seed,omitempty"`
+	// Parallel, Shards and RetryFailed are the --parallel, --shards and
+	// --retry-failed options of the run.
+	Parallel    int `json:"parallel,omitempty"`
+	Shards      int `json:"shards,omitempty"`
+	RetryFailed int `json:"retryFailed,omitempty"`
+	// Flags are the engine flags of every link, after the suite, project
+	// defaults and default environment were applied.
+	Flags []string `json:"flags"`
+	// VarFiles are the --var-file files of the run and Vars the keys of its
+	// --var flags. Pinned values are not recorded, as they may be secrets.
+	VarFiles    []string `json:"varFiles,omitempty"`
+	Vars        []string `json:"vars,omitempty"`
+	Environment string   `json:"environment,omitempty"`
+	Data        string   `json:"data,omitempty"`
+	Links       []Link   `json:"links"`
+}
+
+// Link is the outcome of one link of a run.
+type Link struct {
+	Phase      string   `json:"phase"`
+	Collection string   `json:"collection"`
+	Items      []string `json:"items,omitempty"`
+	// Rows is the row selection the link ran with, "" for every row.
+	Rows   string `json:"rows,omitempty"`
+	Status string `json:"status"`
+	// Failures are the requests that failed, with the row they failed on.
+	Failures []Failure `json:"failures,omitempty"`
+}
+
+// Failure is a request that failed on a data row.
+type Failure struct {
+	// Row is the one based row of the data file, 0 when the link had no data.
+	Row int `json:"row,omitempty"`
+	// Item is the name of the request, "" for a failure outside a request.
+	Item string `json:"item,omitempty"`
+}
+
+// Save writes m to path, creating its directory.
+func Save(path string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create manifest directory: %w", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Load reads the manifest at path.
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no previous run recorded at %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// Failed returns the links that repeat the failures of the run. Setup links
+// always run again, as test links depend on what they export. Failed test
+// links are narrowed to the requests that failed on the rows they failed on,
+// and links that were skipped run in full. Passed test links are left out.
+func (m *Manifest) Failed() ([]Link, error) {
+	var links, failures []Link
+	for _, link := range m.Links {
+		switch {
+		case link.Phase == "setup":
+			links = append(links, link)
+			if link.Status != StatusPassed {
+				failures = append(failures, link)
+			}
+		case link.Status == StatusFailed:
+			narrowed := link.narrow()
+			links = append(links, narrowed...)
+			failures = append(failures, narrowed...)
+		case link.Status == StatusSkipped:
+			links = append(links, link)
+			failures = append(failures, link)
+		}
+	}
+	if len(failures) == 0 {
+		return nil, errors.New("the last run had no failures")
+	}
+	return links, nil
+}
+
+// narrow returns links repeating the failures of l: one per set of requests
+// that failed on the same rows, so no request reruns a row it passed. A link
+// without failure details runs as it did.
+func (l Link) narrow() []Link {
+	if len(l.Failures) == 0 {
+		return []Link{l}
+	}
+
+	// The rows each request failed on; a failure without a row covers every
+	// row, and a failure outside a request covers every request of its row
+	var items []string
+	rows := make(map[string]map[int]bool)
+	for _, f := range l.Failures {
+		if rows[f.Item] == nil {
+			items = append(items, f.Item)
+			rows[f.Item] = make(map[int]bool)
+		}
+		rows[f.Item][f.Row] = true
+	}
+	whole := rows[""]
+	if whole[0] {
+		return []Link{l}
+	}
+
+	var links []Link
+	groups := make(map[string]int)
+	for _, item := range items {
+		var failed []int
+		for row := range rows[item] {
+			if item == "" || !whole[row] {
+				failed = append(failed, row)
+			}
+		}
+		if len(failed) == 0 {
+			continue
+		}
+		sort.Ints(failed)
+		selection := joinRows(failed)
+		if rows[item][0] {
+			selection = l.Rows
+		}
+
+		if i, ok := groups[selection]; ok && item != "" {
+			links[i].Items = append(links[i].Items, item)
+			continue
+		}
+		link := l
+		link.Rows = selection
+		link.Items = []string{item}
+		if item == "" {
+			link.Items = l.Items
+		} else {
+			groups[selection] = len(links)
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+func joinRows(rows []int) string {
+	parts := make([]string, len(rows))
+	for i, row := range rows {
+		parts[i] = strconv.Itoa(row)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,76 @@
+package manifest
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), Path)
+	want := &Manifest{
+		Started:     time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Engine:      "native",
+		Parallel:    2,
+		Shards:      4,
+		RetryFailed: 1,
+		Flags:       []string{"-e", "environments/dev.json", "-d", "data/users.csv"},
+		Links: []Link{
+			{Phase: "test", Collection: "users", Status: StatusFailed, Failures: []Failure{{Row: 2, Item: "Create User"}}},
+		},
+	}
+	if err := Save(path, want); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	got, err := Load(path)
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() = %+v, %v; want %+v", got, err, want)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), Path)); err == nil || !strings.Contains(err.Error(), "no previous run") {
+		t.Errorf("Load() of a missing manifest error = %v", err)
+	}
+}
+
+func TestFailed(t *testing.T) {
+	m := &Manifest{Links: []Link{
+		{Phase: "setup", Collection: "auth", Status: StatusPassed},
+		{Phase: "test", Collection: "accounts", Rows: "1-9", Status: StatusFailed, Failures: []Failure{
+			{Row: 2, Item: "Get Account"}, {Row: 5, Item: "Get Account"}, {Row: 5, Item: "Delete Account"},
+			{Row: 2, Item: "Update Account"}, {Row: 5, Item: "Update Account"},
+			{Row: 7, Item: "Get Account"}, {Row: 7}, {Row: 8, Item: "Delete Account"},
+		}},
+		{Phase: "test", Collection: "health", Items: []string{"Ping"}, Status: StatusFailed, Failures: []Failure{{Item: "Ping"}}},
+		{Phase: "test", Collection: "orders", Status: StatusPassed},
+		{Phase: "test", Collection: "smoke", Items: []string{"Health"}, Status: StatusFailed},
+		{Phase: "test", Collection: "admin", Status: StatusSkipped},
+	}}
+
+	links, err := m.Failed()
+	if err != nil {
+		t.Fatalf("Failed() error = %v", err)
+	}
+	want := []Link{
+		{Phase: "setup", Collection: "auth", Status: StatusPassed},
+		// Each request reruns only the rows it failed on; a failure outside a
+		// request reruns the whole row
+		{Phase: "test", Collection: "accounts", Items: []string{"Get Account", "Update Account"}, Rows: "2,5", Status: StatusFailed, Failures: m.Links[1].Failures},
+		{Phase: "test", Collection: "accounts", Items: []string{"Delete Account"}, Rows: "5,8", Status: StatusFailed, Failures: m.Links[1].Failures},
+		{Phase: "test", Collection: "accounts", Rows: "7", Status: StatusFailed, Failures: m.Links[1].Failures},
+		// Without data the failed requests run as before
+		{Phase: "test", Collection: "health", Items: []string{"Ping"}, Status: StatusFailed, Failures: m.Links[2].Failures},
+		// Without row or request details the link runs as before
+		{Phase: "test", Collection: "smoke", Items: []string{"Health"}, Status: StatusFailed},
+		{Phase: "test", Collection: "admin", Status: StatusSkipped},
+	}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("Failed() = %+v\nwant %+v", links, want)
+	}
+
+	passed := &Manifest{Links: []Link{{Phase: "setup", Collection: "auth", Status: StatusPassed}}}
+	if _, err := passed.Failed(); err == nil {
+		t.Error("Failed() should fail when nothing failed")
+	}
+}
@@ -0,0 +1,51 @@
+package native
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loadIterationData reads a CSV or JSON data file into one map per
+// iteration, the way Newman's --iteration-data does.
+func loadIterationData(path string) ([]map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading iteration data: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var rows []map[string]any
+		if err := decodeJSON(data, &rows); err != nil {
+			return nil, fmt.Errorf("parsing iteration data %s: %w", path, err)
+		}
+		return rows, nil
+	}
+
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(string(data), "\ufeff")))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parsing iteration data %s: %w", path, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	rows := make([]map[string]any, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]any, len(header))
+		for i, column := range header {
+			if i < len(record) {
+				row[column] = record[i]
+			} else {
+				row[column] = ""
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
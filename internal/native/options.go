@@ -0,0 +1,149 @@
+package native
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// options are the Newman flags the native engine understands.
+type options struct {
+	environment       string
+	globals           string
+	data              string
+	iterationCount    int
+	folders           []string
+	exportEnvironment string
+	exportGlobals     string
+	envVars           [][2]string
+	globalVars        [][2]string
+	bail              bool
+	insecure          bool
+	verbose           bool
+	requestTimeout    time.Duration
+	delay             time.Duration
+	reporters         []string
+	jsonExport        string
+	// ignored lists flags that were accepted but have no native equivalent.
+	ignored []string
+}
+
+// parseFlags reads Newman command line flags. Flags the native engine does
+// not know are recorded in ignored, together with their value if the next
+// argument does not look like a flag, mirroring how plaintest forwards them.
+func parseFlags(flags []string) (options, error) {
+	var opts options
+
+	for i := 0; i < len(flags); i++ {
+		flag := flags[i]
+		value := func() (string, error) {
+			if i+1 >= len(flags) {
+				return "", fmt.Errorf("flag %s requires a value", flag)
+			}
+			i++
+			return flags[i], nil
+		}
+
+		var err error
+		switch flag {
+		case "-e", "--environment":
+			opts.environment, err = value()
+		case "-g", "--globals":
+			opts.globals, err = value()
+		case "-d", "--iteration-data":
+			opts.data, err = value()
+		case "-n", "--iteration-count":
+			var v string
+			if v, err = value(); err == nil {
+				opts.iterationCount, err = positiveInt(flag, v)
+			}
+		case "--folder":
+			var v string
+			if v, err = value(); err == nil {
+				opts.folders = append(opts.folders, v)
+			}
+		case "--export-environment":
+			opts.exportEnvironment, err = value()
+		case "--export-globals":
+			opts.exportGlobals, err = value()
+		case "--env-var", "--global-var":
+			var v string
+			if v, err = value(); err == nil {
+				pair, perr := keyValue(flag, v)
+				if perr != nil {
+					return opts, perr
+				}
+				if flag == "--env-var" {
+					opts.envVars = append(opts.envVars, pair)
+				} else {
+					opts.globalVars = append(opts.globalVars, pair)
+				}
+			}
+		case "--bail":
+			opts.bail = true
+			// Newman accepts an optional modifier such as "folder" or "failure"
+			if i+1 < len(flags) && !strings.HasPrefix(flags[i+1], "-") {
+				i++
+			}
+		case "-k", "--insecure":
+			opts.insecure = true
+		case "--verbose":
+			opts.verbose = true
+		case "--timeout-request":
+			var v string
+			if v, err = value(); err == nil {
+				opts.requestTimeout, err = milliseconds(flag, v)
+			}
+		case "--delay-request":
+			var v string
+			if v, err = value(); err == nil {
+				opts.delay, err = milliseconds(flag, v)
+			}
+		case "-r", "--reporters":
+			var v string
+			if v, err = value(); err == nil {
+				opts.reporters = strings.Split(v, ",")
+			}
+		case "--reporter-json-export":
+			opts.jsonExport, err = value()
+		default:
+			if !strings.HasPrefix(flag, "-") {
+				return opts, fmt.Errorf("unexpected argument %q", flag)
+			}
+			opts.ignored = append(opts.ignored, flag)
+			if i+1 < len(flags) && !strings.HasPrefix(flags[i+1], "-") {
+				i++
+			}
+		}
+		if err != nil {
+			return opts, err
+		}
+	}
+
+	return opts, nil
+}
+
+func positiveInt(flag, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("flag %s expects a positive number, got %q", flag, value)
+	}
+	return n, nil
+}
+
+func milliseconds(flag, value string) (time.Duration, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("flag %s expects milliseconds, got %q", flag, value)
+	}
+	return time.Duration(n) * time.Millisecond, nil
+}
+
+func keyValue(flag, value string) ([2]string, error) {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return [2]string{}, fmt.Errorf("flag %s expects key=value, got %q", flag, value)
+	}
+	return [2]string{key, val}, nil
+}
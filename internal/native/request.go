@@ -0,0 +1,317 @@
+package native
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ssd532/plaintest/internal/collection"
+)
+
+// Response is the HTTP response a request received.
+type Response struct {
+	Code   int
+	Status string
+	Header http.Header
+	Body   []byte
+	Time   time.Duration
+}
+
+// buildRequest turns a collection request into an HTTP request, resolving
+// variables and applying auth. auth is the auth inherited from the enclosing
+// folders, used when the request has none of its own.
+func buildRequest(req *collection.Request, auth *collection.Auth, vars *Variables) (*http.Request, error) {
+	target, err := requestURL(req.URL, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	body, contentType, err := requestBody(req.Body, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequest(method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range req.Header {
+		if h.Disabled || h.Key == "" {
+			continue
+		}
+		key, value := vars.Replace(h.Key), vars.Replace(h.Value)
+		if strings.EqualFold(key, "Host") {
+			httpReq.Host = value
+			continue
+		}
+		httpReq.Header.Add(key, value)
+	}
+	if contentType != "" && httpReq.Header.Get("Content-Type") == "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	if httpReq.Header.Get("User-Agent") == "" {
+		httpReq.Header.Set("User-Agent", "PlainTest")
+	}
+
+	if req.Auth != nil {
+		auth = req.Auth
+	}
+	applyAuth(httpReq, auth, vars)
+
+	return httpReq, nil
+}
+
+// requestURL resolves a collection URL. Postman keeps the URL as typed in
+// Raw; the parsed members are only used when Raw is missing.
+func requestURL(u *collection.URL, vars *Variables) (*url.URL, error) {
+	if u == nil {
+		return nil, fmt.Errorf("request has no URL")
+	}
+
+	raw := strings.TrimSpace(vars.Replace(u.String()))
+	if raw == "" {
+		return nil, fmt.Errorf("request has no URL")
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+
+	target, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", raw, err)
+	}
+
+	// Path variables such as :id are filled from the URL's variable list
+	if len(u.Variables) > 0 {
+		segments := strings.Split(target.Path, "/")
+		for i, segment := range segments {
+			if !strings.HasPrefix(segment, ":") {
+				continue
+			}
+			for _, v := range u.Variables {
+				if !v.Disabled && v.Key == segment[1:] {
+					segments[i] = vars.Replace(v.String())
+				}
+			}
+		}
+		target.Path = strings.Join(segments, "/")
+		target.RawPath = ""
+	}
+
+	return target, nil
+}
+
+// requestBody encodes a collection body and returns the content type Postman
+// would send with it.
+func requestBody(body *collection.Body, vars *Variables) (io.Reader, string, error) {
+	if body == nil || body.Disabled {
+		return nil, "", nil
+	}
+
+	switch body.Mode {
+	case "raw":
+		if body.Raw == "" {
+			return nil, "", nil
+		}
+		return strings.NewReader(vars.Replace(body.Raw)), rawContentType(body), nil
+
+	case "urlencoded":
+		form := url.Values{}
+		for _, p := range body.URLEncoded {
+			if !p.Disabled {
+				form.Add(vars.Replace(p.Key), vars.Replace(p.Value))
+			}
+		}
+		return strings.NewReader(form.Encode()), "application/x-www-form-urlencoded", nil
+
+	case "formdata":
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		for _, p := range body.FormData {
+			if p.Disabled {
+				continue
+			}
+			if err := writeFormField(w, p, vars); err != nil {
+				return nil, "", err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return nil, "", err
+		}
+		return &buf, w.FormDataContentType(), nil
+
+	case "file":
+		var file struct {
+			Src string `json:"src"`
+		}
+		if raw, ok := body.Extra["file"]; ok {
+			if err := json.Unmarshal(raw, &file); err != nil {
+				return nil, "", fmt.Errorf("invalid file body: %w", err)
+			}
+		}
+		if file.Src == "" {
+			return nil, "", nil
+		}
+		data, err := os.ReadFile(file.Src)
+		if err != nil {
+			return nil, "", fmt.Errorf("reading body file: %w", err)
+		}
+		return bytes.NewReader(data), "", nil
+
+	case "graphql":
+		var graphql struct {
+			Query     string `json:"query"`
+			Variables string `json:"variables"`
+		}
+		if raw, ok := body.Extra["graphql"]; ok {
+			if err := json.Unmarshal(raw, &graphql); err != nil {
+				return nil, "", fmt.Errorf("invalid graphql body: %w", err)
+			}
+		}
+		payload := map[string]any{"query": vars.Replace(graphql.Query)}
+		if variables := strings.TrimSpace(vars.Replace(graphql.Variables)); variables != "" {
+			payload["variables"] = json.RawMessage(variables)
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid graphql variables: %w", err)
+		}
+		return bytes.NewReader(data), "application/json", nil
+	}
+
+	return nil, "", nil
+}
+
+// rawContentType maps the raw body language Postman stores in body.options
+// to the Content-Type header it sends.
+func rawContentType(body *collection.Body) string {
+	var opts struct {
+		Raw struct {
+			Language string `json:"language"`
+		} `json:"raw"`
+	}
+	if raw, ok := body.Extra["options"]; ok {
+		_ = json.Unmarshal(raw, &opts)
+	}
+	switch opts.Raw.Language {
+	case "json":
+		return "application/json"
+	case "xml":
+		return "application/xml"
+	case "html":
+		return "text/html"
+	case "javascript":
+		return "application/javascript"
+	default:
+		return "text/plain"
+	}
+}
+
+func writeFormField(w *multipart.Writer, p collection.Param, vars *Variables) error {
+	key := vars.Replace(p.Key)
+	if p.Type != "file" {
+		return w.WriteField(key, vars.Replace(p.Value))
+	}
+
+	var src any
+	if raw, ok := p.Extra["src"]; ok {
+		if err := json.Unmarshal(raw, &src); err != nil {
+			return fmt.Errorf("invalid form file %q: %w", key, err)
+		}
+	}
+	var paths []string
+	switch v := src.(type) {
+	case string:
+		paths = []string{v}
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				paths = append(paths, s)
+			}
+		}
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading form file %q: %w", key, err)
+		}
+		part, err := w.CreateFormFile(key, filepath.Base(path))
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyAuth adds the credentials of auth to req. Auth types the native engine
+// does not implement are left to the request's own headers.
+func applyAuth(req *http.Request, auth *collection.Auth, vars *Variables) {
+	if auth == nil {
+		return
+	}
+	param := func(key string) string { return vars.Replace(auth.Param(key)) }
+
+	switch auth.Type {
+	case "bearer":
+		if token := param("token"); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	case "basic":
+		credentials := param("username") + ":" + param("password")
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	case "apikey":
+		key, value := param("key"), param("value")
+		if key == "" {
+			return
+		}
+		if param("in") == "query" {
+			query := req.URL.Query()
+			query.Set(key, value)
+			req.URL.RawQuery = query.Encode()
+			return
+		}
+		req.Header.Set(key, value)
+	}
+}
+
+// send performs req and reads the whole response.
+func send(client *http.Client, req *http.Request) (*Response, error) {
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{
+		Code:   resp.StatusCode,
+		Status: strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode))),
+		Header: resp.Header,
+		Body:   body,
+		Time:   time.Since(start),
+	}, nil
+}
@@ -0,0 +1,75 @@
+package native
+
+import (
+	"io"
+	"strings"
+
+	"github.com/ssd532/plaintest/internal/collection"
+)
+
+// ScriptEngine runs the prerequest and test scripts of a collection. The
+// native engine runs collections without one, skipping their scripts.
+type ScriptEngine interface {
+	Run(source string, ctx *ScriptContext) ([]TestResult, error)
+}
+
+// ScriptContext is the state a script can read and change.
+type ScriptContext struct {
+	// Listen is the event being run, "prerequest" or "test".
+	Listen string
+	// CollectionName and ItemName identify the request being run.
+	CollectionName string
+	ItemName       string
+	// ItemPath holds the names of the enclosing folders and the request.
+	ItemPath []string
+	// Request is a copy of the collection request. Prerequest scripts may
+	// change it before it is sent.
+	Request *collection.Request
+	// Response is nil for prerequest scripts.
+	Response  *Response
+	Variables *Variables
+	// Iteration is zero based.
+	Iteration      int
+	IterationCount int
+	// EnvironmentName is the name of the environment file, if any.
+	EnvironmentName string
+	// Console receives console.log output, one line per call.
+	Console io.Writer
+}
+
+// TestResult is the outcome of one pm.test call.
+type TestResult struct {
+	Name    string
+	Passed  bool
+	Skipped bool
+	Error   string
+}
+
+// scriptsFor returns the source of the enabled scripts in events for listen.
+func scriptsFor(events []collection.Event, listen string) []string {
+	var scripts []string
+	for _, evt := range events {
+		if evt.Disabled || evt.Listen != listen || evt.Script == nil {
+			continue
+		}
+		if source := evt.Script.Source(); strings.TrimSpace(source) != "" {
+			scripts = append(scripts, source)
+		}
+	}
+	return scripts
+}
+
+// consoleWriter indents script console output under the request it belongs
+// to, the way Newman's CLI reporter does.
+type consoleWriter struct {
+	out io.Writer
+}
+
+func (w consoleWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		if _, err := io.WriteString(w.out, "  │ "+line+"\n"); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
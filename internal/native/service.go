@@ -0,0 +1,555 @@
+// Package native runs Postman collections in-process, without Newman.
+//
+// The service accepts the same flags plaintest forwards to Newman, so the run
+// command can switch engines without changing how links are chained.
+package native
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ssd532/plaintest/internal/collection"
+	"github.com/ssd532/plaintest/internal/newman"
+	"github.com/ssd532/plaintest/internal/report"
+)
+
+// Service runs collections with Go's HTTP client.
+type Service struct {
+	scripts ScriptEngine
+	debug   bool
+}
+
+// NewService returns a service without a script engine.
+func NewService() *Service {
+	return &Service{}
+}
+
+// SetDebug prints the parsed flags before each run.
+func (s *Service) SetDebug(debug bool) {
+	s.debug = debug
+}
+
+// SetScriptEngine sets the engine used for prerequest and test scripts.
+func (s *Service) SetScriptEngine(engine ScriptEngine) {
+	s.scripts = engine
+}
+
+// IsInstalled always reports true; the native engine needs nothing besides
+// the plaintest binary.
+func (s *Service) IsInstalled() bool {
+	return true
+}
+
+// RunWithFlags runs a collection with Newman style flags. When ctx ends, the
+// run stops before the next request, aborting the one in flight, and still
+// exports the environment and writes the JSON report of what ran. The error
+// is then the cause of ctx.
+func (s *Service) RunWithFlags(ctx context.Context, collectionPath string, flags []string) (*newman.Result, error) {
+	if collectionPath == "" {
+		return nil, errors.New("collection path is required")
+	}
+
+	opts, err := parseFlags(flags)
+	if err != nil {
+		return failed(err.Error()), err
+	}
+	if s.debug {
+		fmt.Printf("[debug] native run %s %s\n", collectionPath, strings.Join(flags, " "))
+	}
+
+	var out bytes.Buffer
+	r, err := newRun(ctx, collectionPath, opts, s.scripts, &out)
+	if err != nil {
+		out.WriteString(err.Error() + "\n")
+		return failed(out.String()), err
+	}
+
+	r.execute()
+	stopped := context.Cause(ctx)
+	if stopped != nil {
+		fmt.Fprintf(&out, "\nRun stopped: %v\n", stopped)
+	}
+	if err := r.export(); err != nil {
+		out.WriteString(err.Error() + "\n")
+		return failed(out.String()), err
+	}
+	r.printSummary()
+	if r.opts.jsonExport != "" {
+		if err := r.report().Save(r.opts.jsonExport); err != nil {
+			out.WriteString(err.Error() + "\n")
+			return failed(out.String()), fmt.Errorf("writing JSON report: %w", err)
+		}
+	}
+
+	result := &newman.Result{Success: r.stats.failures() == 0 && stopped == nil, Output: out.String()}
+	if !result.Success {
+		result.ExitCode = 1
+	}
+	return result, stopped
+}
+
+// RunWithEnvironmentExport runs a collection and writes the final environment
+// to exportEnvPath.
+func (s *Service) RunWithEnvironmentExport(ctx context.Context, collectionPath string, flags []string, exportEnvPath string) (*newman.Result, error) {
+	args := append(append([]string{}, flags...), "--export-environment", exportEnvPath)
+	return s.RunWithFlags(ctx, collectionPath, args)
+}
+
+func failed(output string) *newman.Result {
+	return &newman.Result{Success: false, ExitCode: 1, Output: output}
+}
+
+// runItem is a request selected for a run, together with what it inherits
+// from its folders.
+type runItem struct {
+	item *collection.Item
+	path []string
+	auth *collection.Auth
+	// groups are the collection and folders enclosing the request, outermost
+	// first. Their scripts run before the request's own.
+	groups []*collection.ItemGroup
+}
+
+type stats struct {
+	iterations, requests, requestsFailed int
+	assertions, assertionsFailed         int
+	prerequestScripts, testScripts       report.Count
+	skippedScripts                       int
+	failed                               []string
+}
+
+func (s *stats) failures() int {
+	return len(s.failed)
+}
+
+// run is a single collection run.
+type run struct {
+	ctx        context.Context
+	opts       options
+	coll       *collection.Collection
+	env        *Environment
+	globals    *Environment
+	vars       *Variables
+	items      []runItem
+	data       []map[string]any
+	client     *http.Client
+	scripts    ScriptEngine
+	out        io.Writer
+	stats      stats
+	started    time.Time
+	completed  time.Time
+	iterations int
+	// executions and failures are recorded for the JSON report.
+	executions []report.Execution
+	failures   []report.Failure
+	cursor     report.Cursor
+}
+
+func newRun(ctx context.Context, collectionPath string, opts options, scripts ScriptEngine, out io.Writer) (*run, error) {
+	coll, err := collection.Load(collectionPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load collection: %w", err)
+	}
+
+	r := &run{
+		ctx:     ctx,
+		opts:    opts,
+		coll:    coll,
+		env:     &Environment{},
+		globals: &Environment{VariableScope: "globals"},
+		vars:    NewVariables(),
+		scripts: scripts,
+		out:     out,
+	}
+
+	if opts.environment != "" {
+		if r.env, err = LoadEnvironment(opts.environment); err != nil {
+			return nil, err
+		}
+		r.vars.Environment = r.env.Scope()
+	}
+	if opts.globals != "" {
+		if r.globals, err = LoadEnvironment(opts.globals); err != nil {
+			return nil, err
+		}
+		r.vars.Globals = r.globals.Scope()
+	}
+	for _, pair := range opts.envVars {
+		r.vars.Environment.Set(pair[0], pair[1])
+	}
+	for _, pair := range opts.globalVars {
+		r.vars.Globals.Set(pair[0], pair[1])
+	}
+	for _, v := range coll.Variables {
+		if !v.Disabled {
+			r.vars.Collection.Set(v.Key, v.Value)
+		}
+	}
+
+	if opts.data != "" {
+		if r.data, err = loadIterationData(opts.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.items, err = selectItems(coll, opts.folders); err != nil {
+		return nil, err
+	}
+
+	r.iterations = 1
+	if len(r.data) > 0 {
+		r.iterations = len(r.data)
+	}
+	if opts.iterationCount > 0 {
+		r.iterations = opts.iterationCount
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	r.client = &http.Client{Transport: transport, Timeout: opts.requestTimeout}
+
+	return r, nil
+}
+
+// selectItems lists the requests of coll in run order. With folders set,
+// only the named folders and requests run, as with Newman's --folder.
+func selectItems(coll *collection.Collection, folders []string) ([]runItem, error) {
+	var items []runItem
+	found := make(map[string]bool)
+
+	var visit func(group *collection.ItemGroup, groups []*collection.ItemGroup, path []string, auth *collection.Auth, selected bool)
+	visit = func(group *collection.ItemGroup, groups []*collection.ItemGroup, path []string, auth *collection.Auth, selected bool) {
+		if group.Auth != nil {
+			auth = group.Auth
+		}
+		groups = append(groups[:len(groups):len(groups)], group)
+
+		for _, item := range group.Items {
+			itemPath := append(path[:len(path):len(path)], item.Name)
+			itemSelected := selected || matchesFolder(item.Name, folders)
+			if !selected && itemSelected {
+				found[item.Name] = true
+			}
+			if item.IsFolder() {
+				visit(&item.ItemGroup, groups, itemPath, auth, itemSelected)
+				continue
+			}
+			if item.Request != nil && itemSelected {
+				items = append(items, runItem{item: item, path: itemPath, auth: auth, groups: groups})
+			}
+		}
+	}
+	visit(&coll.ItemGroup, nil, nil, nil, len(folders) == 0)
+
+	for _, folder := range folders {
+		if !found[folder] {
+			return nil, fmt.Errorf("unable to find a folder or request: %s", folder)
+		}
+	}
+	return items, nil
+}
+
+func matchesFolder(name string, folders []string) bool {
+	for _, folder := range folders {
+		if name == folder {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *run) execute() {
+	r.started = time.Now()
+	defer func() { r.completed = time.Now() }()
+	fmt.Fprintf(r.out, "%s\n", r.coll.Info.Name)
+
+	for iteration := 0; iteration < r.iterations; iteration++ {
+		if r.ctx.Err() != nil {
+			return
+		}
+		if r.iterations > 1 {
+			fmt.Fprintf(r.out, "\nIteration %d/%d\n", iteration+1, r.iterations)
+		}
+		r.setIterationData(iteration)
+		r.stats.iterations++
+
+		for position, ri := range r.items {
+			if r.ctx.Err() != nil {
+				return
+			}
+			r.cursor = report.Cursor{Iteration: iteration, Position: position, Length: len(r.items), Cycles: r.iterations}
+			if !r.runRequest(ri, iteration) && r.opts.bail {
+				return
+			}
+		}
+	}
+}
+
+// setIterationData loads the data row for iteration into the data scope.
+// Extra iterations beyond the data file reuse its last row, as Newman does.
+func (r *run) setIterationData(iteration int) {
+	r.vars.Data.Clear()
+	if len(r.data) == 0 {
+		return
+	}
+	row := r.data[len(r.data)-1]
+	if iteration < len(r.data) {
+		row = r.data[iteration]
+	}
+	keys := make([]string, 0, len(row))
+	for key := range row {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		r.vars.Data.Set(key, row[key])
+	}
+}
+
+// runRequest sends one request and runs its scripts. It reports whether the
+// request and all of its tests passed.
+func (r *run) runRequest(ri runItem, iteration int) bool {
+	r.vars.Local.Clear()
+	fmt.Fprintf(r.out, "\n→ %s\n", ri.item.Name)
+	r.executions = append(r.executions, report.Execution{Cursor: r.cursor, Item: itemOf(ri)})
+	exec := &r.executions[len(r.executions)-1]
+
+	ctx := &ScriptContext{
+		CollectionName:  r.coll.Info.Name,
+		ItemName:        ri.item.Name,
+		ItemPath:        ri.path,
+		Request:         cloneRequest(ri.item.Request),
+		Variables:       r.vars,
+		Iteration:       iteration,
+		IterationCount:  r.iterations,
+		EnvironmentName: r.env.Name,
+		Console:         consoleWriter{out: r.out},
+	}
+
+	passed := r.runScripts("prerequest", ri, ctx)
+
+	r.stats.requests++
+	httpReq, err := buildRequest(ctx.Request, ri.auth, r.vars)
+	if err == nil {
+		fmt.Fprintf(r.out, "  %s %s", httpReq.Method, httpReq.URL)
+		ctx.Response, err = send(r.client, httpReq.WithContext(r.ctx))
+	}
+	if err != nil {
+		fmt.Fprintf(r.out, " [errored]\n  ✗  %v\n", err)
+		r.stats.requestsFailed++
+		exec.RequestError = &report.Error{Name: "Error", Message: err.Error()}
+		r.fail(ri, "request", *exec.RequestError, fmt.Sprintf("request failed: %v", err))
+		return false
+	}
+
+	resp := ctx.Response
+	exec.Response = &report.Response{Code: resp.Code, Status: resp.Status, ResponseTime: resp.Time.Milliseconds(), ResponseSize: len(resp.Body)}
+	fmt.Fprintf(r.out, " [%d %s, %s, %dms]\n", resp.Code, resp.Status, formatSize(len(resp.Body)), resp.Time.Milliseconds())
+	if r.opts.verbose {
+		r.printExchange(httpReq, resp)
+	}
+
+	if !r.runScripts("test", ri, ctx) {
+		passed = false
+	}
+
+	if r.opts.delay > 0 {
+		select {
+		case <-time.After(r.opts.delay):
+		case <-r.ctx.Done():
+		}
+	}
+	return passed
+}
+
+// runScripts runs the scripts for listen from the collection down to the
+// request and reports whether they all passed.
+func (r *run) runScripts(listen string, ri runItem, ctx *ScriptContext) bool {
+	var sources []string
+	for _, group := range ri.groups {
+		sources = append(sources, scriptsFor(group.Events, listen)...)
+	}
+	sources = append(sources, scriptsFor(ri.item.Events, listen)...)
+	if len(sources) == 0 {
+		return true
+	}
+	if r.scripts == nil {
+		if r.stats.skippedScripts == 0 {
+			fmt.Fprintf(r.out, "  Warning: scripts are skipped, the native engine has no script runtime\n")
+		}
+		r.stats.skippedScripts += len(sources)
+		return true
+	}
+
+	counts := &r.stats.testScripts
+	if listen == "prerequest" {
+		counts = &r.stats.prerequestScripts
+	}
+
+	ctx.Listen = listen
+	passed := true
+	for _, source := range sources {
+		counts.Total++
+		results, err := r.scripts.Run(source, ctx)
+		for _, test := range results {
+			r.record(ri, listen, test)
+			if !test.Passed && !test.Skipped {
+				passed = false
+			}
+		}
+		if err != nil {
+			counts.Failed++
+			fmt.Fprintf(r.out, "  ✗  %s-script error: %v\n", listen, err)
+			name, message := splitError(err.Error())
+			r.fail(ri, listen+"-script", report.Error{Name: name, Message: message}, fmt.Sprintf("%s-script error: %v", listen, err))
+			passed = false
+		}
+	}
+	return passed
+}
+
+// record prints a test result and adds it to the current execution.
+func (r *run) record(ri runItem, listen string, test TestResult) {
+	exec := &r.executions[len(r.executions)-1]
+	assertion := report.Assertion{Assertion: test.Name, Skipped: test.Skipped}
+
+	switch {
+	case test.Skipped:
+		fmt.Fprintf(r.out, "  -  %s\n", test.Name)
+	case test.Passed:
+		r.stats.assertions++
+		fmt.Fprintf(r.out, "  ✓  %s\n", test.Name)
+	default:
+		r.stats.assertions++
+		r.stats.assertionsFailed++
+		fmt.Fprintf(r.out, "  ✗  %s\n", test.Name)
+		name, message := splitError(test.Error)
+		assertion.Error = &report.Error{Name: name, Message: message, Test: test.Name}
+		r.fail(ri, fmt.Sprintf("assertion:%d in %s-script", len(exec.Assertions), listen), *assertion.Error, fmt.Sprintf("%s: %s", test.Name, test.Error))
+	}
+	exec.Assertions = append(exec.Assertions, assertion)
+}
+
+func (r *run) fail(ri runItem, at string, e report.Error, detail string) {
+	r.stats.failed = append(r.stats.failed, fmt.Sprintf("%s: %s", strings.Join(ri.path, " / "), detail))
+	r.failures = append(r.failures, report.Failure{Error: e, At: at, Source: itemOf(ri), Cursor: r.cursor})
+}
+
+// report builds the Newman style JSON report of the run.
+func (r *run) report() *report.Report {
+	s := r.stats
+	rep := &report.Report{}
+	rep.Collection.Info.ID = r.coll.Info.PostmanID
+	rep.Collection.Info.Name = r.coll.Info.Name
+	rep.Run = report.Run{
+		Stats: report.Stats{
+			Iterations:        report.Count{Total: s.iterations},
+			Items:             report.Count{Total: len(r.executions)},
+			Requests:          report.Count{Total: s.requests, Failed: s.requestsFailed},
+			PrerequestScripts: s.prerequestScripts,
+			TestScripts:       s.testScripts,
+			Assertions:        report.Count{Total: s.assertions, Failed: s.assertionsFailed},
+		},
+		Timings:    report.Timings{Started: r.started.UnixMilli(), Completed: r.completed.UnixMilli()},
+		Executions: r.executions,
+		Failures:   r.failures,
+	}
+	if rep.Run.Executions == nil {
+		rep.Run.Executions = []report.Execution{}
+	}
+	if rep.Run.Failures == nil {
+		rep.Run.Failures = []report.Failure{}
+	}
+	return rep
+}
+
+func itemOf(ri runItem) report.Item {
+	return report.Item{ID: ri.item.ID, Name: ri.item.Name}
+}
+
+// splitError splits "AssertionError: expected 1 to equal 2" into the error
+// name and message. Messages without a name are reported as "Error".
+func splitError(text string) (string, string) {
+	name, message, ok := strings.Cut(text, ": ")
+	if !ok || strings.ContainsAny(name, " \t") || !strings.HasSuffix(name, "Error") {
+		return "Error", text
+	}
+	return name, message
+}
+
+func (r *run) printExchange(req *http.Request, resp *Response) {
+	for key, values := range req.Header {
+		fmt.Fprintf(r.out, "    > %s: %s\n", key, strings.Join(values, ", "))
+	}
+	for key, values := range resp.Header {
+		fmt.Fprintf(r.out, "    < %s: %s\n", key, strings.Join(values, ", "))
+	}
+	if len(resp.Body) > 0 {
+		fmt.Fprintf(r.out, "    %s\n", resp.Body)
+	}
+}
+
+// export writes the environment and globals when the flags ask for it.
+func (r *run) export() error {
+	if r.opts.exportEnvironment != "" {
+		r.env.Update(r.vars.Environment)
+		if err := r.env.Save(r.opts.exportEnvironment); err != nil {
+			return fmt.Errorf("exporting environment: %w", err)
+		}
+	}
+	if r.opts.exportGlobals != "" {
+		r.globals.Update(r.vars.Globals)
+		if err := r.globals.Save(r.opts.exportGlobals); err != nil {
+			return fmt.Errorf("exporting globals: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *run) printSummary() {
+	s := r.stats
+	fmt.Fprintf(r.out, "\niterations: %d executed\n", s.iterations)
+	fmt.Fprintf(r.out, "requests: %d executed, %d failed\n", s.requests, s.requestsFailed)
+	fmt.Fprintf(r.out, "assertions: %d executed, %d failed\n", s.assertions, s.assertionsFailed)
+	if s.skippedScripts > 0 {
+		fmt.Fprintf(r.out, "scripts skipped: %d\n", s.skippedScripts)
+	}
+	fmt.Fprintf(r.out, "total run duration: %s\n", time.Since(r.started).Round(time.Millisecond))
+
+	if len(s.failed) > 0 {
+		fmt.Fprintf(r.out, "\nFailures:\n")
+		for i, failure := range s.failed {
+			fmt.Fprintf(r.out, "  %d. %s\n", i+1, failure)
+		}
+	}
+}
+
+// cloneRequest returns a deep copy of req that scripts may change freely.
+func cloneRequest(req *collection.Request) *collection.Request {
+	data, err := req.MarshalJSON()
+	if err != nil {
+		return req
+	}
+	var clone collection.Request
+	if err := clone.UnmarshalJSON(data); err != nil {
+		return req
+	}
+	return &clone
+}
+
+func formatSize(n int) string {
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	return fmt.Sprintf("%.2fkB", float64(n)/1024)
+}
@@ -0,0 +1,422 @@
+package native
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ssd532/plaintest/internal/report"
+)
+
+// recorder is a test server that remembers the requests it received.
+type recorder struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+}
+
+func newRecorder(t *testing.T) (*recorder, *httptest.Server) {
+	t.Helper()
+	rec := &recorder{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		rec.mu.Lock()
+		rec.requests = append(rec.requests, r)
+		rec.bodies = append(rec.bodies, string(body))
+		rec.mu.Unlock()
+
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(server.Close)
+	return rec, server
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+const runCollection = `{
+	"info": {"name": "Native"},
+	"variable": [{"key": "resource", "value": "users"}, {"key": "token", "value": "collection-token"}],
+	"auth": {"type": "bearer", "bearer": [{"key": "token", "value": "{{token}}"}]},
+	"item": [
+		{
+			"name": "Users",
+			"item": [
+				{
+					"name": "Create User",
+					"request": {
+						"method": "POST",
+						"header": [
+							{"key": "X-Test-Id", "value": "{{test_id}}"},
+							{"key": "X-Disabled", "value": "no", "disabled": true}
+						],
+						"body": {"mode": "raw", "raw": "{\"name\": \"{{name}}\"}", "options": {"raw": {"language": "json"}}},
+						"url": {"raw": "{{base_url}}/{{resource}}?page=1"}
+					}
+				}
+			]
+		},
+		{
+			"name": "Public",
+			"auth": {"type": "noauth"},
+			"item": [
+				{"name": "Health", "request": "{{base_url}}/health"}
+			]
+		}
+	]
+}`
+
+const runEnvironment = `{
+	"id": "env-1",
+	"name": "Test",
+	"values": [
+		{"key": "base_url", "value": "BASE", "type": "default", "enabled": true},
+		{"key": "token", "value": "env-token", "type": "secret", "enabled": true},
+		{"key": "unused", "value": "off", "enabled": false}
+	]
+}`
+
+func setupRun(t *testing.T, baseURL string) (collectionPath, envPath, dataPath string) {
+	t.Helper()
+	dir := t.TempDir()
+	collectionPath = writeFile(t, dir, "native.postman_collection.json", runCollection)
+	envPath = writeFile(t, dir, "test.postman_environment.json", strings.Replace(runEnvironment, "BASE", baseURL, 1))
+	dataPath = writeFile(t, dir, "data.csv", "test_id,name\nTC_001,Alice\nTC_002,\"Bob, Jr\"\n")
+	return collectionPath, envPath, dataPath
+}
+
+func TestVariables_Replace(t *testing.T) {
+	vars := NewVariables()
+	vars.Globals.Set("host", "global-host")
+	vars.Collection.Set("host", "collection-host")
+	vars.Collection.Set("url", "https://{{host}}/{{version}}")
+	vars.Environment.Set("version", "v1")
+	vars.Data.Set("id", "42")
+	vars.Environment.Set("loop", "{{loop}}")
+
+	tests := []struct {
+		in, want string
+	}{
+		{"{{url}}/users/{{id}}", "https://collection-host/v1/users/42"},
+		{"{{unknown}}", "{{unknown}}"},
+		{"{{loop}}", "{{loop}}"},
+		{"plain", "plain"},
+	}
+	for _, tt := range tests {
+		if got := vars.Replace(tt.in); got != tt.want {
+			t.Errorf("Replace(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	vars.Local.Set("id", 7)
+	if got := vars.Replace("{{id}}"); got != "7" {
+		t.Errorf("local scope should take precedence, got %q", got)
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	opts, err := parseFlags([]string{
+		"-e", "env.json", "-d", "data.csv", "--folder", "A", "--folder", "B",
+		"--env-var", "token=abc=", "--bail", "--timeout-request", "1500",
+		"--reporters", "cli,json", "--reporter-json-export", "report.json", "--color", "off", "-k",
+	})
+	if err != nil {
+		t.Fatalf("parseFlags() error = %v", err)
+	}
+
+	if opts.environment != "env.json" || opts.data != "data.csv" {
+		t.Errorf("files = %q, %q", opts.environment, opts.data)
+	}
+	if !reflect.DeepEqual(opts.folders, []string{"A", "B"}) {
+		t.Errorf("folders = %v", opts.folders)
+	}
+	if !reflect.DeepEqual(opts.envVars, [][2]string{{"token", "abc="}}) {
+		t.Errorf("envVars = %v", opts.envVars)
+	}
+	if !opts.bail || !opts.insecure || opts.requestTimeout != 1500*time.Millisecond {
+		t.Errorf("opts = %+v", opts)
+	}
+	if !reflect.DeepEqual(opts.reporters, []string{"cli", "json"}) || opts.jsonExport != "report.json" {
+		t.Errorf("reporters = %v, json export = %q", opts.reporters, opts.jsonExport)
+	}
+	if !reflect.DeepEqual(opts.ignored, []string{"--color"}) {
+		t.Errorf("ignored = %v", opts.ignored)
+	}
+
+	for _, bad := range [][]string{{"-n", "zero"}, {"--env-var", "novalue"}, {"-e"}} {
+		if _, err := parseFlags(bad); err == nil {
+			t.Errorf("parseFlags(%v) should fail", bad)
+		}
+	}
+}
+
+func TestService_RunWithEnvironmentExport(t *testing.T) {
+	rec, server := newRecorder(t)
+	collectionPath, envPath, dataPath := setupRun(t, server.URL)
+	exportPath := filepath.Join(t.TempDir(), "exported.json")
+
+	service := NewService()
+	result, err := service.RunWithEnvironmentExport(context.Background(), collectionPath, []string{"-e", envPath, "-d", dataPath, "--env-var", "extra=1"}, exportPath)
+	if err != nil {
+		t.Fatalf("RunWithEnvironmentExport() error = %v\n%s", err, result.Output)
+	}
+	if !result.Success || result.ExitCode != 0 {
+		t.Fatalf("run failed:\n%s", result.Output)
+	}
+
+	// Two iterations of two requests
+	if len(rec.requests) != 4 {
+		t.Fatalf("server received %d requests, want 4", len(rec.requests))
+	}
+
+	create := rec.requests[0]
+	if create.Method != http.MethodPost || create.URL.Path != "/users" || create.URL.RawQuery != "page=1" {
+		t.Errorf("create request = %s %s", create.Method, create.URL)
+	}
+	if got := create.Header.Get("Authorization"); got != "Bearer env-token" {
+		t.Errorf("Authorization = %q, want environment value over collection value", got)
+	}
+	if got := create.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if create.Header.Get("X-Disabled") != "" {
+		t.Error("disabled headers should not be sent")
+	}
+	if got := create.Header.Get("X-Test-Id"); got != "TC_001" {
+		t.Errorf("X-Test-Id = %q", got)
+	}
+	if rec.bodies[0] != `{"name": "Alice"}` || rec.bodies[2] != `{"name": "Bob, Jr"}` {
+		t.Errorf("bodies = %q", rec.bodies)
+	}
+
+	health := rec.requests[1]
+	if health.URL.Path != "/health" || health.Header.Get("Authorization") != "" {
+		t.Errorf("noauth folder request = %s with Authorization %q", health.URL, health.Header.Get("Authorization"))
+	}
+
+	exported, err := LoadEnvironment(exportPath)
+	if err != nil {
+		t.Fatalf("LoadEnvironment() error = %v", err)
+	}
+	var keys []string
+	for _, v := range exported.Values {
+		keys = append(keys, v.Key)
+	}
+	if want := []string{"base_url", "token", "extra", "unused"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("exported keys = %v, want %v", keys, want)
+	}
+	if exported.ID != "env-1" || exported.Values[1].Type != "secret" {
+		t.Errorf("exported environment lost its metadata: %+v", exported)
+	}
+}
+
+func TestService_RunWithFlags_Folder(t *testing.T) {
+	rec, server := newRecorder(t)
+	collectionPath, envPath, _ := setupRun(t, server.URL)
+
+	service := NewService()
+	result, err := service.RunWithFlags(context.Background(), collectionPath, []string{"-e", envPath, "--folder", "Health"})
+	if err != nil || !result.Success {
+		t.Fatalf("RunWithFlags() error = %v\n%s", err, result.Output)
+	}
+	if len(rec.requests) != 1 || rec.requests[0].URL.Path != "/health" {
+		t.Errorf("--folder should select only Health, got %d requests", len(rec.requests))
+	}
+
+	result, err = service.RunWithFlags(context.Background(), collectionPath, []string{"-e", envPath, "--folder", "Nope"})
+	if err == nil || result.Success {
+		t.Error("unknown folder should fail the run")
+	}
+}
+
+func TestService_RunWithFlags_RequestError(t *testing.T) {
+	collectionPath, envPath, _ := setupRun(t, "http://127.0.0.1:1")
+
+	service := NewService()
+	result, err := service.RunWithFlags(context.Background(), collectionPath, []string{"-e", envPath, "--bail"})
+	if err != nil {
+		t.Fatalf("request failures should not be run errors: %v", err)
+	}
+	if result.Success || result.ExitCode != 1 {
+		t.Errorf("result = %+v, want failure", result)
+	}
+	if !strings.Contains(result.Output, "requests: 1 executed, 1 failed") {
+		t.Errorf("--bail should stop after the first failure:\n%s", result.Output)
+	}
+}
+
+func TestService_RunWithFlags_Stopped(t *testing.T) {
+	// The second request hangs until the run gives up on it
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	collectionPath := writeFile(t, dir, "slow.postman_collection.json", `{
+		"info": {"name": "Slow"},
+		"item": [
+			{"name": "Fast", "request": "`+server.URL+`/fast"},
+			{"name": "Slow", "request": "`+server.URL+`/slow"},
+			{"name": "Never", "request": "`+server.URL+`/never"}
+		]
+	}`)
+	reportPath := filepath.Join(dir, "report.json")
+	exportPath := filepath.Join(dir, "export.json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	result, err := NewService().RunWithEnvironmentExport(ctx, collectionPath, []string{"--reporter-json-export", reportPath}, exportPath)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RunWithEnvironmentExport() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if result.Success || !strings.Contains(result.Output, "Run stopped: context deadline exceeded") {
+		t.Errorf("result = %+v", result)
+	}
+
+	// What ran is still reported and exported
+	r, err := report.Load(reportPath)
+	if err != nil {
+		t.Fatalf("partial report: %v", err)
+	}
+	if len(r.Run.Executions) != 2 || r.Run.Executions[0].Response == nil {
+		t.Errorf("partial report executions = %+v", r.Run.Executions)
+	}
+	if _, err := os.Stat(exportPath); err != nil {
+		t.Errorf("environment not exported: %v", err)
+	}
+}
+
+// fakeEngine records the scripts it is asked to run.
+type fakeEngine struct {
+	calls []string
+}
+
+func (f *fakeEngine) Run(source string, ctx *ScriptContext) ([]TestResult, error) {
+	f.calls = append(f.calls, ctx.Listen+":"+source)
+	if ctx.Listen == "prerequest" {
+		ctx.Request.Method = "DELETE"
+		ctx.Variables.Environment.Set("seen", ctx.ItemName)
+		return nil, nil
+	}
+	return []TestResult{
+		{Name: "status is 200", Passed: ctx.Response.Code == http.StatusOK, Error: "unexpected status"},
+	}, nil
+}
+
+func TestService_RunWithFlags_Scripts(t *testing.T) {
+	rec, server := newRecorder(t)
+	dir := t.TempDir()
+	collectionPath := writeFile(t, dir, "scripts.postman_collection.json", `{
+		"info": {"name": "Scripts"},
+		"event": [{"listen": "prerequest", "script": {"exec": ["root();"]}}],
+		"item": [
+			{"name": "Ok", "request": "`+server.URL+`/ok", "event": [{"listen": "test", "script": {"exec": ["check();"]}}]},
+			{"name": "Missing", "request": "`+server.URL+`/missing", "event": [{"listen": "test", "script": {"exec": ["check();"]}}]}
+		]
+	}`)
+	exportPath := filepath.Join(dir, "env.json")
+
+	// Without an engine the scripts are skipped and the requests still run
+	result, err := NewService().RunWithFlags(context.Background(), collectionPath, nil)
+	if err != nil || !result.Success {
+		t.Fatalf("RunWithFlags() error = %v\n%s", err, result.Output)
+	}
+	if !strings.Contains(result.Output, "scripts skipped: 4") {
+		t.Errorf("skipped scripts should be reported:\n%s", result.Output)
+	}
+
+	engine := &fakeEngine{}
+	service := NewService()
+	service.SetScriptEngine(engine)
+	result, err = service.RunWithEnvironmentExport(context.Background(), collectionPath, nil, exportPath)
+	if err != nil {
+		t.Fatalf("RunWithEnvironmentExport() error = %v", err)
+	}
+	if result.Success {
+		t.Errorf("failing test should fail the run:\n%s", result.Output)
+	}
+
+	want := []string{"prerequest:root();", "test:check();", "prerequest:root();", "test:check();"}
+	if !reflect.DeepEqual(engine.calls, want) {
+		t.Errorf("script calls = %v, want %v", engine.calls, want)
+	}
+	if got := rec.requests[len(rec.requests)-1].Method; got != http.MethodDelete {
+		t.Errorf("prerequest changes to the request should be sent, got %s", got)
+	}
+
+	data, _ := os.ReadFile(exportPath)
+	var env Environment
+	if err := json.Unmarshal(data, &env); err != nil || len(env.Values) != 1 || env.Values[0].Value != "Missing" {
+		t.Errorf("script variables should be exported, got %s", data)
+	}
+}
+
+func TestService_RunWithFlags_JSONReport(t *testing.T) {
+	_, server := newRecorder(t)
+	dir := t.TempDir()
+	collectionPath := writeFile(t, dir, "report.postman_collection.json", `{
+		"info": {"name": "Report"},
+		"item": [
+			{"name": "Ok", "request": "`+server.URL+`/ok", "event": [{"listen": "test", "script": {"exec": ["check();"]}}]},
+			{"name": "Missing", "request": "`+server.URL+`/missing", "event": [{"listen": "test", "script": {"exec": ["check();"]}}]}
+		]
+	}`)
+	reportPath := filepath.Join(dir, "report.json")
+
+	service := NewService()
+	service.SetScriptEngine(&fakeEngine{})
+	result, err := service.RunWithFlags(context.Background(), collectionPath, []string{"-n", "2", "--reporters", "cli,json", "--reporter-json-export", reportPath})
+	if err != nil || result.Success {
+		t.Fatalf("RunWithFlags() = %+v, %v; want a failed run", result, err)
+	}
+
+	r, err := report.Load(reportPath)
+	if err != nil {
+		t.Fatalf("report not written: %v", err)
+	}
+	stats := r.Run.Stats
+	if r.Collection.Info.Name != "Report" || stats.Iterations.Total != 2 || stats.Requests.Total != 4 ||
+		stats.Assertions.Total != 4 || stats.Assertions.Failed != 2 || stats.TestScripts.Total != 4 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if len(r.Run.Executions) != 4 || r.Run.Executions[1].Response.Code != http.StatusNotFound {
+		t.Errorf("executions = %+v", r.Run.Executions)
+	}
+
+	want := report.Failure{
+		Error:  report.Error{Name: "Error", Message: "unexpected status", Test: "status is 200"},
+		At:     "assertion:0 in test-script",
+		Source: report.Item{Name: "Missing"},
+		Cursor: report.Cursor{Iteration: 1, Position: 1, Length: 2, Cycles: 2},
+	}
+	if len(r.Run.Failures) != 2 || !reflect.DeepEqual(r.Run.Failures[1], want) {
+		t.Errorf("failures = %+v, want last %+v", r.Run.Failures, want)
+	}
+}
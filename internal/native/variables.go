@@ -0,0 +1,267 @@
+package native
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Scope is an ordered set of variables such as an environment or the
+// collection variables. Values keep the JSON type they were set with.
+type Scope struct {
+	keys   []string
+	values map[string]any
+}
+
+// NewScope returns an empty scope.
+func NewScope() *Scope {
+	return &Scope{values: make(map[string]any)}
+}
+
+// Get returns the value of key and whether it is set.
+func (s *Scope) Get(key string) (any, bool) {
+	value, ok := s.values[key]
+	return value, ok
+}
+
+// Has reports whether key is set.
+func (s *Scope) Has(key string) bool {
+	_, ok := s.values[key]
+	return ok
+}
+
+// Set sets key to value. New keys are appended after existing ones.
+func (s *Scope) Set(key string, value any) {
+	if _, ok := s.values[key]; !ok {
+		s.keys = append(s.keys, key)
+	}
+	s.values[key] = value
+}
+
+// Unset removes key.
+func (s *Scope) Unset(key string) {
+	if _, ok := s.values[key]; !ok {
+		return
+	}
+	delete(s.values, key)
+	for i, k := range s.keys {
+		if k == key {
+			s.keys = append(s.keys[:i], s.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Clear removes all variables.
+func (s *Scope) Clear() {
+	s.keys = nil
+	s.values = make(map[string]any)
+}
+
+// Keys returns the variable names in insertion order.
+func (s *Scope) Keys() []string {
+	return append([]string(nil), s.keys...)
+}
+
+// ToMap returns a copy of the variables.
+func (s *Scope) ToMap() map[string]any {
+	m := make(map[string]any, len(s.values))
+	for k, v := range s.values {
+		m[k] = v
+	}
+	return m
+}
+
+// Variables holds the scopes a request resolves {{placeholders}} against.
+type Variables struct {
+	Globals     *Scope
+	Collection  *Scope
+	Environment *Scope
+	Data        *Scope
+	// Local holds pm.variables.set values for the current request.
+	Local *Scope
+}
+
+// NewVariables returns a set of empty scopes.
+func NewVariables() *Variables {
+	return &Variables{
+		Globals:     NewScope(),
+		Collection:  NewScope(),
+		Environment: NewScope(),
+		Data:        NewScope(),
+		Local:       NewScope(),
+	}
+}
+
+// Get resolves key using Postman's precedence: local, iteration data,
+// environment, collection, then globals.
+func (v *Variables) Get(key string) (any, bool) {
+	for _, scope := range []*Scope{v.Local, v.Data, v.Environment, v.Collection, v.Globals} {
+		if value, ok := scope.Get(key); ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// maxReplaceDepth bounds nested substitution the way Postman does, so a
+// variable that refers to itself cannot loop forever.
+const maxReplaceDepth = 19
+
+var placeholder = regexp.MustCompile(`\{\{([^{}]+)\}\}`)
+
+// Replace substitutes {{name}} placeholders in s. Unknown names are left as
+// they are; values may themselves contain placeholders.
+func (v *Variables) Replace(s string) string {
+	for depth := 0; depth < maxReplaceDepth; depth++ {
+		replaced := placeholder.ReplaceAllStringFunc(s, func(match string) string {
+			name := match[2 : len(match)-2]
+			if value, ok := v.Get(name); ok {
+				return stringValue(value)
+			}
+			if value, ok := dynamicVariable(name); ok {
+				return value
+			}
+			return match
+		})
+		if replaced == s {
+			break
+		}
+		s = replaced
+	}
+	return s
+}
+
+// dynamicVariable returns a value for the Postman dynamic variables the
+// native engine supports.
+func dynamicVariable(name string) (string, bool) {
+	switch name {
+	case "$guid", "$randomUUID":
+		return newUUID(), true
+	case "$timestamp":
+		return strconv.FormatInt(time.Now().Unix(), 10), true
+	case "$isoTimestamp":
+		return time.Now().UTC().Format("2006-01-02T15:04:05.000Z"), true
+	case "$randomInt":
+		n, _ := rand.Int(rand.Reader, big.NewInt(1001))
+		return n.String(), true
+	}
+	return "", false
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Environment is a Postman environment or globals file.
+type Environment struct {
+	ID            string             `json:"id,omitempty"`
+	Name          string             `json:"name,omitempty"`
+	Values        []EnvironmentValue `json:"values"`
+	VariableScope string             `json:"_postman_variable_scope,omitempty"`
+}
+
+// EnvironmentValue is one variable of an environment file.
+type EnvironmentValue struct {
+	Key     string `json:"key"`
+	Value   any    `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Enabled *bool  `json:"enabled,omitempty"`
+}
+
+// LoadEnvironment reads an environment file.
+func LoadEnvironment(path string) (*Environment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var env Environment
+	if err := decodeJSON(data, &env); err != nil {
+		return nil, fmt.Errorf("parsing environment %s: %w", path, err)
+	}
+	return &env, nil
+}
+
+// Scope returns the enabled variables of the environment.
+func (e *Environment) Scope() *Scope {
+	scope := NewScope()
+	for _, v := range e.Values {
+		if v.Enabled != nil && !*v.Enabled {
+			continue
+		}
+		scope.Set(v.Key, v.Value)
+	}
+	return scope
+}
+
+// Update replaces the values of the environment with those of scope, keeping
+// the type of variables that already existed. Disabled variables are kept.
+func (e *Environment) Update(scope *Scope) {
+	types := make(map[string]string, len(e.Values))
+	var disabled []EnvironmentValue
+	for _, v := range e.Values {
+		types[v.Key] = v.Type
+		if v.Enabled != nil && !*v.Enabled && !scope.Has(v.Key) {
+			disabled = append(disabled, v)
+		}
+	}
+
+	enabled := true
+	values := make([]EnvironmentValue, 0, len(scope.keys)+len(disabled))
+	for _, key := range scope.keys {
+		valueType := types[key]
+		if valueType == "" {
+			valueType = "any"
+		}
+		values = append(values, EnvironmentValue{Key: key, Value: scope.values[key], Type: valueType, Enabled: &enabled})
+	}
+	e.Values = append(values, disabled...)
+}
+
+// Save writes the environment to path.
+func (e *Environment) Save(path string) error {
+	if e.VariableScope == "" {
+		e.VariableScope = "environment"
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(e); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// decodeJSON decodes data into v, keeping numbers as json.Number so large
+// IDs survive a round trip unchanged.
+func decodeJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func stringValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(data)
+	}
+}
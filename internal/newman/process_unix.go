@@ -0,0 +1,36 @@
+//go:build !windows
+
+package newman
+
+import (
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// stopProcessGroup starts cmd in a process group of its own. Cancelling cmd
+// sends the group SIGTERM and SIGKILL to whatever is left after grace. Newman
+// has no SIGTERM handler, so it exits at once without writing its reports.
+// The returned function is called once cmd has exited; if cmd was cancelled,
+// it kills the rest of the group at once.
+func stopProcessGroup(cmd *exec.Cmd, grace time.Duration) func() {
+	var mu sync.Mutex
+	var timer *time.Timer
+	kill := func() { _ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		timer = time.AfterFunc(grace, kill)
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer != nil && timer.Stop() {
+			kill()
+		}
+	}
+}
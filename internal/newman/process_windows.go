@@ -0,0 +1,14 @@
+//go:build windows
+
+package newman
+
+import (
+	"os/exec"
+	"time"
+)
+
+// stopProcessGroup leaves cmd as it is: Windows has no signal to ask Newman
+// to stop, so cancelling cmd kills the Newman process at once.
+func stopProcessGroup(cmd *exec.Cmd, grace time.Duration) func() {
+	return func() {}
+}
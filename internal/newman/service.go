@@ -1,12 +1,18 @@
 package newman
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
+	"time"
 )
 
+// waitDelay is how long a stopped Newman and its children have to exit
+// before they are killed
+var waitDelay = 5 * time.Second
+
 type Service struct {
 	executable string
 	workingDir string
@@ -40,7 +46,9 @@ func (s *Service) SetDebug(debug bool) {
 	s.debug = debug
 }
 
-func (s *Service) Run(collection string, options Options) (*Result, error) {
+// Run runs Newman with options. When ctx ends first, Newman and the processes
+// it started are stopped and the error is the cause of ctx.
+func (s *Service) Run(ctx context.Context, collection string, options Options) (*Result, error) {
 	if collection == "" {
 		return nil, errors.New("collection path is required")
 	}
@@ -62,24 +70,11 @@ func (s *Service) Run(collection string, options Options) (*Result, error) {
 		args = append(args, "--verbose", "--reporter-cli-show-timestamps")
 	}
 
-	if s.debug {
-		fmt.Printf("[debug] newman %s\n", strings.Join(args, " "))
-	}
-	cmd := exec.Command(s.executable, args...)
-	cmd.Dir = s.workingDir
-	output, err := cmd.CombinedOutput()
-
-	result := &Result{
-		Success:  err == nil,
-		ExitCode: cmd.ProcessState.ExitCode(),
-		Output:   string(output),
-	}
-
-	return result, err
+	return s.execute(ctx, args)
 }
 
 // RunWithFlags runs Newman with custom flags passed through
-func (s *Service) RunWithFlags(collection string, flags []string) (*Result, error) {
+func (s *Service) RunWithFlags(ctx context.Context, collection string, flags []string) (*Result, error) {
 	if collection == "" {
 		return nil, errors.New("collection path is required")
 	}
@@ -87,24 +82,11 @@ func (s *Service) RunWithFlags(collection string, flags []string) (*Result, erro
 	args := []string{"run", collection}
 	args = append(args, flags...)
 
-	if s.debug {
-		fmt.Printf("[debug] newman %s\n", strings.Join(args, " "))
-	}
-	cmd := exec.Command(s.executable, args...)
-	cmd.Dir = s.workingDir
-	output, err := cmd.CombinedOutput()
-
-	result := &Result{
-		Success:  err == nil,
-		ExitCode: cmd.ProcessState.ExitCode(),
-		Output:   string(output),
-	}
-
-	return result, err
+	return s.execute(ctx, args)
 }
 
 // RunWithEnvironmentExport runs Newman and exports the environment to a file
-func (s *Service) RunWithEnvironmentExport(collection string, flags []string, exportEnvPath string) (*Result, error) {
+func (s *Service) RunWithEnvironmentExport(ctx context.Context, collection string, flags []string, exportEnvPath string) (*Result, error) {
 	if collection == "" {
 		return nil, errors.New("collection path is required")
 	}
@@ -115,18 +97,31 @@ func (s *Service) RunWithEnvironmentExport(collection string, flags []string, ex
 	// Add environment export flag
 	args = append(args, "--export-environment", exportEnvPath)
 
+	return s.execute(ctx, args)
+}
+
+// execute runs Newman with args in its own process group, so that ending ctx
+// stops the Node processes it started as well
+func (s *Service) execute(ctx context.Context, args []string) (*Result, error) {
 	if s.debug {
 		fmt.Printf("[debug] newman %s\n", strings.Join(args, " "))
 	}
-	cmd := exec.Command(s.executable, args...)
+	cmd := exec.CommandContext(ctx, s.executable, args...)
 	cmd.Dir = s.workingDir
+	stopped := stopProcessGroup(cmd, waitDelay)
+	// Output pipes held open by stray children must not block the run
+	cmd.WaitDelay = waitDelay
 	output, err := cmd.CombinedOutput()
+	stopped()
 
 	result := &Result{
 		Success:  err == nil,
 		ExitCode: cmd.ProcessState.ExitCode(),
 		Output:   string(output),
 	}
+	if ctx.Err() != nil {
+		return result, context.Cause(ctx)
+	}
 
 	return result, err
 }
@@ -1,7 +1,13 @@
 package newman
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 )
 
 func TestNewmanService_Run(t *testing.T) {
@@ -32,7 +38,7 @@ func TestNewmanService_Run(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := service.Run(tt.collection, tt.options)
+			result, err := service.Run(context.Background(), tt.collection, tt.options)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewmanService.Run() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -97,7 +103,7 @@ func TestNewmanService_RunWithOptions(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := service.Run(tt.collection, tt.options)
+			result, err := service.Run(context.Background(), tt.collection, tt.options)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewmanService.Run() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -146,7 +152,7 @@ func TestNewmanService_RunWithFlags(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := service.RunWithFlags(tt.collection, tt.flags)
+			result, err := service.RunWithFlags(context.Background(), tt.collection, tt.flags)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewmanService.RunWithFlags() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -193,7 +199,7 @@ func TestNewmanService_RunWithEnvironmentExport(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := service.RunWithEnvironmentExport(tt.collection, tt.flags, tt.exportEnvPath)
+			result, err := service.RunWithEnvironmentExport(context.Background(), tt.collection, tt.flags, tt.exportEnvPath)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewmanService.RunWithEnvironmentExport() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -204,3 +210,79 @@ func TestNewmanService_RunWithEnvironmentExport(t *testing.T) {
 		})
 	}
 }
+
+func TestNewmanService_RunWithFlags_Timeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script standing in for Newman")
+	}
+
+	// A Newman that hangs in a child process, as Node does on a stuck request
+	dir := t.TempDir()
+	executable := filepath.Join(dir, "newman")
+	if err := os.WriteFile(executable, []byte("#!/bin/sh\necho started\nsleep 30 &\nwait\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	service := &Service{executable: executable, workingDir: dir}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result, err := service.RunWithFlags(ctx, "test.postman_collection.json", nil)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RunWithFlags() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("RunWithFlags() returned after %s; the process group was not killed", elapsed)
+	}
+	if result == nil || result.Success || result.Output != "started\n" {
+		t.Errorf("RunWithFlags() result = %+v", result)
+	}
+}
+
+func TestNewmanService_RunWithFlags_Stop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script standing in for Newman")
+	}
+	defer func(delay time.Duration) { waitDelay = delay }(waitDelay)
+	waitDelay = 300 * time.Millisecond
+
+	// A Newman that notes the SIGTERM it is sent, and one that ignores it
+	// in a child process
+	dir := t.TempDir()
+	termPath := filepath.Join(dir, "term")
+	graceful := filepath.Join(dir, "graceful")
+	stubborn := filepath.Join(dir, "stubborn")
+	if err := os.WriteFile(graceful, []byte("#!/bin/sh\ntrap 'echo TERM > "+termPath+"; exit 1' TERM\nsleep 30 &\nwait\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stubborn, []byte("#!/bin/sh\ntrap '' TERM\nsleep 30 &\nwait\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		executable string
+		minElapsed time.Duration
+	}{
+		{graceful, 0},
+		{stubborn, waitDelay},
+	} {
+		service := &Service{executable: tt.executable, workingDir: dir}
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		start := time.Now()
+		_, err := service.RunWithFlags(ctx, "test.postman_collection.json", nil)
+		elapsed := time.Since(start) - 200*time.Millisecond
+		cancel()
+
+		name := filepath.Base(tt.executable)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: RunWithFlags() error = %v, want %v", name, err, context.DeadlineExceeded)
+		}
+		if elapsed < tt.minElapsed || elapsed > 5*time.Second {
+			t.Errorf("%s: stopped %s after the deadline", name, elapsed)
+		}
+	}
+	if data, err := os.ReadFile(termPath); err != nil || string(data) != "TERM\n" {
+		t.Errorf("graceful Newman got %q, %v; it was not sent SIGTERM before SIGKILL", data, err)
+	}
+}
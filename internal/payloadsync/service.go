@@ -1,11 +1,15 @@
 package payloadsync
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
+
+	"github.com/ssd532/plaintest/internal/collection"
 )
 
 // Config drives the extract and build process.
@@ -36,7 +40,7 @@ func (s *Service) Extract(collectionName string) error {
 		return err
 	}
 
-	collectionPath := filepath.Join(s.cfg.CollectionsDir, collectionName+".postman_collection.json")
+	collectionPath := filepath.Join(s.cfg.CollectionsDir, collectionName+collection.FileSuffix)
 	if _, err := os.Stat(collectionPath); err != nil {
 		return fmt.Errorf("collection not found: %s", collectionPath)
 	}
@@ -50,7 +54,7 @@ func (s *Service) Build(collectionName string) error {
 		return err
 	}
 
-	collectionPath := filepath.Join(s.cfg.CollectionsDir, collectionName+".postman_collection.json")
+	collectionPath := filepath.Join(s.cfg.CollectionsDir, collectionName+collection.FileSuffix)
 	if _, err := os.Stat(collectionPath); err != nil {
 		return fmt.Errorf("collection not found: %s", collectionPath)
 	}
@@ -69,67 +73,26 @@ func (s *Service) ensureDirs() error {
 }
 
 func (s *Service) extractCollection(collectionPath, collectionName string) error {
-	data, err := os.ReadFile(collectionPath)
+	coll, err := collection.Load(collectionPath)
 	if err != nil {
-		return fmt.Errorf("failed to read collection: %w", err)
-	}
-
-	var collection map[string]any
-	if err := json.Unmarshal(data, &collection); err != nil {
-		return fmt.Errorf("failed to parse collection: %w", err)
-	}
-
-	items, ok := collection["item"].([]any)
-	if !ok {
-		return nil
+		return fmt.Errorf("failed to load collection: %w", err)
 	}
 
-	return s.extractItems(items, collectionName, []string{})
-}
-
-func (s *Service) extractItems(items []any, collectionName string, path []string) error {
-	for _, item := range items {
-		itemMap, ok := item.(map[string]any)
-		if !ok {
-			continue
-		}
-
-		name, _ := itemMap["name"].(string)
-		currentPath := append(path, name)
-
-		// Check if this is a folder with nested items
-		if nestedItems, ok := itemMap["item"].([]any); ok {
-			if err := s.extractItems(nestedItems, collectionName, currentPath); err != nil {
-				return err
-			}
-			continue
-		}
-
-		// Extract request body if present
-		if request, ok := itemMap["request"].(map[string]any); ok {
-			if err := s.extractRequestBody(request, collectionName, currentPath); err != nil {
-				return err
-			}
+	return coll.Walk(func(path []string, item *collection.Item) error {
+		if item.IsFolder() || item.Request == nil {
+			return nil
 		}
-	}
-	return nil
+		return s.extractRequestBody(item.Request, collectionName, path)
+	})
 }
 
-func (s *Service) extractRequestBody(request map[string]any, collectionName string, path []string) error {
-	body, ok := request["body"].(map[string]any)
-	if !ok {
-		return nil
-	}
-
-	rawBody, ok := body["raw"].(string)
-	if !ok || rawBody == "" {
+func (s *Service) extractRequestBody(request *collection.Request, collectionName string, path []string) error {
+	if request.Body == nil || request.Body.Raw == "" {
 		return nil
 	}
 
-	// Try to parse as JSON
-	var payloadData any
-	if err := json.Unmarshal([]byte(rawBody), &payloadData); err != nil {
-		// Not valid JSON, skip
+	// Skip bodies that are not JSON
+	if !json.Valid([]byte(request.Body.Raw)) {
 		return nil
 	}
 
@@ -140,13 +103,13 @@ func (s *Service) extractRequestBody(request map[string]any, collectionName stri
 		return fmt.Errorf("failed to create payload dir: %w", err)
 	}
 
-	// Pretty print JSON
-	prettyJSON, err := json.MarshalIndent(payloadData, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+	// Pretty print JSON, keeping the key order of the request body
+	var prettyJSON bytes.Buffer
+	if err := json.Indent(&prettyJSON, []byte(request.Body.Raw), "", "  "); err != nil {
+		return fmt.Errorf("failed to format payload: %w", err)
 	}
 
-	if err := os.WriteFile(payloadPath, prettyJSON, 0644); err != nil {
+	if err := os.WriteFile(payloadPath, prettyJSON.Bytes(), 0644); err != nil {
 		return fmt.Errorf("failed to write payload: %w", err)
 	}
 
@@ -154,67 +117,30 @@ func (s *Service) extractRequestBody(request map[string]any, collectionName stri
 }
 
 func (s *Service) buildCollection(collectionPath, collectionName string) error {
-	data, err := os.ReadFile(collectionPath)
+	coll, err := collection.Load(collectionPath)
 	if err != nil {
-		return fmt.Errorf("failed to read collection: %w", err)
-	}
-
-	var collection map[string]any
-	if err := json.Unmarshal(data, &collection); err != nil {
-		return fmt.Errorf("failed to parse collection: %w", err)
-	}
-
-	items, ok := collection["item"].([]any)
-	if !ok {
-		return nil
+		return fmt.Errorf("failed to load collection: %w", err)
 	}
 
-	if err := s.buildItems(items, collectionName, []string{}); err != nil {
+	err = coll.Walk(func(path []string, item *collection.Item) error {
+		if item.IsFolder() || item.Request == nil {
+			return nil
+		}
+		return s.buildRequestBody(item.Request, collectionName, path)
+	})
+	if err != nil {
 		return err
 	}
 
 	// Write updated collection
-	updatedData, err := json.MarshalIndent(collection, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal collection: %w", err)
-	}
-
-	if err := os.WriteFile(collectionPath, updatedData, 0644); err != nil {
+	if err := coll.Save(collectionPath); err != nil {
 		return fmt.Errorf("failed to write collection: %w", err)
 	}
 
 	return nil
 }
 
-func (s *Service) buildItems(items []any, collectionName string, path []string) error {
-	for _, item := range items {
-		itemMap, ok := item.(map[string]any)
-		if !ok {
-			continue
-		}
-
-		name, _ := itemMap["name"].(string)
-		currentPath := append(path, name)
-
-		// Check if this is a folder with nested items
-		if nestedItems, ok := itemMap["item"].([]any); ok {
-			if err := s.buildItems(nestedItems, collectionName, currentPath); err != nil {
-				return err
-			}
-			continue
-		}
-
-		// Update request body if payload file exists
-		if request, ok := itemMap["request"].(map[string]any); ok {
-			if err := s.buildRequestBody(request, collectionName, currentPath); err != nil {
-				return err
-			}
-		}
-	}
-	return nil
-}
-
-func (s *Service) buildRequestBody(request map[string]any, collectionName string, path []string) error {
+func (s *Service) buildRequestBody(request *collection.Request, collectionName string, path []string) error {
 	payloadPath := s.payloadPath(collectionName, path)
 
 	// Check if payload file exists
@@ -234,26 +160,36 @@ func (s *Service) buildRequestBody(request map[string]any, collectionName string
 		return fmt.Errorf("invalid JSON in payload file %s: %w", payloadPath, err)
 	}
 
-	// Convert to compact JSON string (what Postman expects)
-	compactJSON, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+	// Keep the existing body text when the payload did not change
+	if request.Body != nil && sameJSON(request.Body.Raw, payload) {
+		return nil
+	}
+
+	// Convert to compact JSON string (what Postman expects), keeping key order
+	var compactJSON bytes.Buffer
+	if err := json.Compact(&compactJSON, payloadData); err != nil {
+		return fmt.Errorf("failed to compact payload: %w", err)
 	}
 
 	// Update request body
-	body, ok := request["body"].(map[string]any)
-	if !ok {
-		body = map[string]any{
-			"mode": "raw",
-		}
-		request["body"] = body
+	if request.Body == nil {
+		request.Body = &collection.Body{Mode: "raw"}
 	}
 
-	body["raw"] = string(compactJSON)
+	request.Body.Raw = compactJSON.String()
 
 	return nil
 }
 
+// sameJSON reports whether raw holds the same JSON value as payload.
+func sameJSON(raw string, payload any) bool {
+	var current any
+	if err := json.Unmarshal([]byte(raw), &current); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(current, payload)
+}
+
 func (s *Service) payloadPath(collectionName string, path []string) string {
 	// Sanitize path components
 	sanitized := make([]string, len(path))
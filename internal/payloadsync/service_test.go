@@ -458,3 +458,53 @@ func TestSanitize_EmptyString(t *testing.T) {
 		t.Errorf("sanitize(\"\") = %v, want %v", result, expected)
 	}
 }
+
+func TestService_Build_PreservesLayout(t *testing.T) {
+	_, cleanup := setupTest(t)
+	defer cleanup()
+
+	original := "{\n\t\"info\": {\n\t\t\"name\": \"Test\"\n\t},\n\t\"item\": [\n\t\t{\n\t\t\t\"name\": \"Create User\",\n\t\t\t\"request\": {\n" +
+		"\t\t\t\t\"method\": \"POST\",\n\t\t\t\t\"body\": {\n\t\t\t\t\t\"mode\": \"raw\",\n" +
+		"\t\t\t\t\t\"raw\": \"{\\n    \\\"name\\\": \\\"<b>\\\",\\n    \\\"age\\\": 25\\n}\"\n" +
+		"\t\t\t\t},\n\t\t\t\t\"url\": \"{{base_url}}/users\"\n\t\t\t}\n\t\t}\n\t]\n}"
+
+	if err := os.MkdirAll("collections", 0755); err != nil {
+		t.Fatalf("Failed to create collections dir: %v", err)
+	}
+	if err := os.WriteFile("collections/test.postman_collection.json", []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write collection: %v", err)
+	}
+
+	service := NewService(Config{})
+	if err := service.Extract("test"); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	// Extracted payload keeps the request's key order and HTML characters
+	payload, _ := os.ReadFile("payloads/test/create-user.json")
+	if want := "{\n  \"name\": \"<b>\",\n  \"age\": 25\n}"; string(payload) != want {
+		t.Errorf("payload = %s, want %s", payload, want)
+	}
+
+	// A push without edits must leave the file byte for byte identical
+	if err := service.Build("test"); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	unchanged, _ := os.ReadFile("collections/test.postman_collection.json")
+	if string(unchanged) != original {
+		t.Errorf("Build without edits rewrote the collection:\n%s", unchanged)
+	}
+
+	// An edited payload is written compact, in the order of the payload file
+	if err := os.WriteFile("payloads/test/create-user.json", []byte("{\n  \"name\": \"<i>\",\n  \"age\": 30\n}"), 0644); err != nil {
+		t.Fatalf("Failed to edit payload: %v", err)
+	}
+	if err := service.Build("test"); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	updated, _ := os.ReadFile("collections/test.postman_collection.json")
+	want := strings.Replace(original, `"{\n    \"name\": \"<b>\",\n    \"age\": 25\n}"`, `"{\"name\":\"<i>\",\"age\":30}"`, 1)
+	if string(updated) != want {
+		t.Errorf("Build changed more than the edited body:\n%s", updated)
+	}
+}
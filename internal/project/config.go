@@ -0,0 +1,289 @@
+// Package project locates the project root and loads plaintest.yaml, the
+// optional project configuration.
+//
+// The file sets the project's directory layout, run defaults and named
+// suites. Without one, plaintest uses the layout plaintest init creates.
+package project
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the project configuration file.
+const FileName = "plaintest.yaml"
+
+// markerDir marks a project root that has no configuration file.
+const markerDir = "collections"
+
+// Config is the content of plaintest.yaml.
+type Config struct {
+	Paths    Paths            `yaml:"paths"`
+	Defaults Defaults         `yaml:"defaults"`
+	Suites   map[string]Suite `yaml:"suites"`
+	// Matrices name lists of data files whose rows plaintest run combines,
+	// so that -d name runs every combination. Members are data names or
+	// paths.
+	Matrices map[string][]string `yaml:"matrices"`
+	// Environments layer environment files onto others, by name.
+	Environments map[string]Environment `yaml:"environments"`
+
+	// Dir is the project root paths are relative to, or "" for the working
+	// directory when no project was found.
+	Dir string `yaml:"-"`
+	// File is the configuration file, or "" when none was found.
+	File string `yaml:"-"`
+}
+
+// Paths are the project directories, relative to the configuration file.
+type Paths struct {
+	Collections  string `yaml:"collections"`
+	Environments string `yaml:"environments"`
+	Data         string `yaml:"data"`
+	Scripts      string `yaml:"scripts"`
+	Payloads     string `yaml:"payloads"`
+	Reports      string `yaml:"reports"`
+}
+
+// Defaults apply to plaintest run when the command line does not say
+// otherwise.
+type Defaults struct {
+	// Environment is an environment name or path, used without -e.
+	Environment string `yaml:"environment"`
+	// Reporters is the Newman --reporters list, used without --reporters.
+	Reporters string `yaml:"reporters"`
+}
+
+// Environment is the configuration of a named environment.
+type Environment struct {
+	// Extends are the environments whose variables this one starts from,
+	// each overriding the one before.
+	Extends []string `yaml:"extends"`
+}
+
+// Suite is a named set of links and flags for plaintest run --suite.
+type Suite struct {
+	Description string   `yaml:"description"`
+	Setup       []string `yaml:"setup"`
+	Test        []string `yaml:"test"`
+	// Environment and Data are names or paths, used without -e and -d.
+	Environment string `yaml:"environment"`
+	Data        string `yaml:"data"`
+	Rows        string `yaml:"rows"`
+	// Flags are passed to the engine as if given on the command line.
+	Flags []string `yaml:"flags"`
+}
+
+// Default returns the configuration used when there is no plaintest.yaml.
+func Default() *Config {
+	c := &Config{}
+	c.setDefaults()
+	return c
+}
+
+func (c *Config) setDefaults() {
+	defaults := map[*string]string{
+		&c.Paths.Collections:  "collections",
+		&c.Paths.Environments: "environments",
+		&c.Paths.Data:         "data",
+		&c.Paths.Scripts:      "scripts",
+		&c.Paths.Payloads:     "payloads",
+		&c.Paths.Reports:      "reports",
+	}
+	for field, value := range defaults {
+		if strings.TrimSpace(*field) == "" {
+			*field = value
+		}
+	}
+}
+
+// Load reads the configuration file at path. Unknown keys are errors so that
+// typos do not go unnoticed.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var c Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	c.setDefaults()
+	c.File = path
+	c.Dir = filepath.Dir(path)
+
+	for name, suite := range c.Suites {
+		if len(suite.Setup) == 0 && len(suite.Test) == 0 {
+			return nil, fmt.Errorf("invalid %s: suite %q has no setup or test links", path, name)
+		}
+	}
+	for name := range c.Environments {
+		if _, err := c.EnvironmentLayers(name); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", path, err)
+		}
+	}
+	for name, members := range c.Matrices {
+		if len(members) < 2 {
+			return nil, fmt.Errorf("invalid %s: matrix %q needs at least two data files", path, name)
+		}
+	}
+	return &c, nil
+}
+
+// Root walks up from dir to the project root: the first directory holding
+// plaintest.yaml or a collections/ directory. It returns "" when there is
+// none up to the filesystem root.
+func Root(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if isFile(filepath.Join(dir, FileName)) || isDir(filepath.Join(dir, markerDir)) {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// Discover opens the project whose root is found from dir upwards, or
+// returns Default when there is none.
+func Discover(dir string) (*Config, error) {
+	root, err := Root(dir)
+	if err != nil || root == "" {
+		return Default(), err
+	}
+	return Open(root, dir)
+}
+
+// Open opens the project rooted at root, loading its plaintest.yaml if
+// there is one. Dir is made relative to wd when possible so that printed
+// paths stay short.
+func Open(root, wd string) (*Config, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if !isDir(root) {
+		return nil, fmt.Errorf("project directory not found: %s", root)
+	}
+
+	c := Default()
+	if path := filepath.Join(root, FileName); isFile(path) {
+		if c, err = Load(path); err != nil {
+			return nil, err
+		}
+	}
+	c.Dir = root
+
+	if abs, err := filepath.Abs(wd); err == nil {
+		if rel, err := filepath.Rel(abs, root); err == nil {
+			c.Dir = rel
+		}
+	}
+	return c, nil
+}
+
+// Resolve returns path relative to the configuration's directory. Absolute
+// paths are returned unchanged.
+func (c *Config) Resolve(path string) string {
+	if c.Dir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(c.Dir, path)
+}
+
+// CollectionsDir returns the directory holding collections.
+func (c *Config) CollectionsDir() string { return c.Resolve(c.Paths.Collections) }
+
+// EnvironmentsDir returns the directory holding environments.
+func (c *Config) EnvironmentsDir() string { return c.Resolve(c.Paths.Environments) }
+
+// DataDir returns the directory holding iteration data.
+func (c *Config) DataDir() string { return c.Resolve(c.Paths.Data) }
+
+// ScriptsDir returns the directory scripts are pulled to.
+func (c *Config) ScriptsDir() string { return c.Resolve(c.Paths.Scripts) }
+
+// PayloadsDir returns the directory payloads are pulled to.
+func (c *Config) PayloadsDir() string { return c.Resolve(c.Paths.Payloads) }
+
+// ReportsDir returns the directory reports are written to.
+func (c *Config) ReportsDir() string { return c.Resolve(c.Paths.Reports) }
+
+// Suite returns the named suite.
+func (c *Config) Suite(name string) (Suite, error) {
+	suite, ok := c.Suites[name]
+	if !ok {
+		if c.File == "" {
+			return Suite{}, fmt.Errorf("unknown suite: %s. No %s found", name, FileName)
+		}
+		return Suite{}, fmt.Errorf("unknown suite: %s. Available: %v", name, c.SuiteNames())
+	}
+	return suite, nil
+}
+
+// EnvironmentLayers returns the environments that make up the named one, in
+// the order they are merged: everything it extends, depth first, then the
+// environment itself. Each name appears once.
+func (c *Config) EnvironmentLayers(name string) ([]string, error) {
+	var layers []string
+	seen := make(map[string]bool)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		for _, p := range path {
+			if p == name {
+				return fmt.Errorf("environment %s extends itself through %s", name, strings.Join(append(path, name), " -> "))
+			}
+		}
+		for _, parent := range c.Environments[name].Extends {
+			if err := visit(parent, append(path, name)); err != nil {
+				return err
+			}
+		}
+		if !seen[name] {
+			seen[name] = true
+			layers = append(layers, name)
+		}
+		return nil
+	}
+	if err := visit(name, nil); err != nil {
+		return nil, err
+	}
+	return layers, nil
+}
+
+// SuiteNames returns the names of all suites, sorted.
+func (c *Config) SuiteNames() []string {
+	names := make([]string, 0, len(c.Suites))
+	for name := range c.Suites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
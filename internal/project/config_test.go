@@ -0,0 +1,197 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleConfig = `paths:
+  collections: api/collections
+  reports: out
+defaults:
+  environment: staging
+  reporters: cli,json
+suites:
+  regression:
+    description: Full regression
+    setup: [get_auth]
+    test: [api_tests, "smoke.Health Check"]
+    data: example
+    rows: 1-3
+    flags: [--bail]
+  smoke:
+    test: [smoke]
+matrices:
+  users_by_currency: [users, currencies]
+environments:
+  base:
+    extends: [common]
+  staging:
+    extends: [base, secrets]
+  prod:
+    extends: [base]
+`
+
+func writeConfig(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, FileName)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefault(t *testing.T) {
+	c := Default()
+	want := Paths{"collections", "environments", "data", "scripts", "payloads", "reports"}
+	if c.Paths != want {
+		t.Errorf("Default().Paths = %+v, want %+v", c.Paths, want)
+	}
+	// Without a file, paths are relative to the working directory
+	if c.CollectionsDir() != "collections" || c.Resolve("data/x.csv") != "data/x.csv" {
+		t.Errorf("Default() dirs = %q, %q", c.CollectionsDir(), c.Resolve("data/x.csv"))
+	}
+	if _, err := c.Suite("regression"); err == nil || !strings.Contains(err.Error(), "No plaintest.yaml") {
+		t.Errorf("Suite() error = %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Load(writeConfig(t, dir, sampleConfig))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if c.CollectionsDir() != filepath.Join(dir, "api", "collections") || c.ReportsDir() != filepath.Join(dir, "out") {
+		t.Errorf("configured dirs = %q, %q", c.CollectionsDir(), c.ReportsDir())
+	}
+	if c.DataDir() != filepath.Join(dir, "data") {
+		t.Errorf("unset dirs should default, got %q", c.DataDir())
+	}
+	if c.Defaults != (Defaults{Environment: "staging", Reporters: "cli,json"}) {
+		t.Errorf("Defaults = %+v", c.Defaults)
+	}
+
+	suite, err := c.Suite("regression")
+	want := Suite{
+		Description: "Full regression",
+		Setup:       []string{"get_auth"},
+		Test:        []string{"api_tests", "smoke.Health Check"},
+		Data:        "example",
+		Rows:        "1-3",
+		Flags:       []string{"--bail"},
+	}
+	if err != nil || !reflect.DeepEqual(suite, want) {
+		t.Errorf("Suite(regression) = %+v, %v; want %+v", suite, err, want)
+	}
+	if members := c.Matrices["users_by_currency"]; !reflect.DeepEqual(members, []string{"users", "currencies"}) {
+		t.Errorf("Matrices = %v", c.Matrices)
+	}
+	if layers, err := c.EnvironmentLayers("staging"); err != nil || !reflect.DeepEqual(layers, []string{"common", "base", "secrets", "staging"}) {
+		t.Errorf("EnvironmentLayers(staging) = %v, %v", layers, err)
+	}
+	if layers, err := c.EnvironmentLayers("dev"); err != nil || !reflect.DeepEqual(layers, []string{"dev"}) {
+		t.Errorf("EnvironmentLayers(dev) = %v, %v", layers, err)
+	}
+	if names := c.SuiteNames(); !reflect.DeepEqual(names, []string{"regression", "smoke"}) {
+		t.Errorf("SuiteNames() = %v", names)
+	}
+	if _, err := c.Suite("nightly"); err == nil || !strings.Contains(err.Error(), "[regression smoke]") {
+		t.Errorf("Suite(nightly) error = %v", err)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"unknown key", "path:\n  collections: x\n", "field path not found"},
+		{"empty suite", "suites:\n  nightly:\n    data: example\n", `suite "nightly" has no setup or test links`},
+		{"small matrix", "matrices:\n  users: [users]\n", `matrix "users" needs at least two data files`},
+		{"environment cycle", "environments:\n  a:\n    extends: [b]\n  b:\n    extends: [a]\n", "extends itself through"},
+		{"bad yaml", "suites: [\n", "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, t.TempDir(), tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// An empty file is the default configuration
+	c, err := Load(writeConfig(t, t.TempDir(), ""))
+	if err != nil || c.Paths != Default().Paths {
+		t.Errorf("Load(empty) = %+v, %v", c, err)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// No marker anywhere up the tree of a fresh temp dir is the common case,
+	// but a stray project above it would be found too, so only check that
+	// discovery succeeds
+	if _, err := Discover(nested); err != nil {
+		t.Fatalf("Discover() without a project error = %v", err)
+	}
+
+	writeConfig(t, root, sampleConfig)
+	found, err := Root(nested)
+	if err != nil || found != root {
+		t.Errorf("Root() = %q, %v; want %q", found, err, root)
+	}
+
+	c, err := Discover(nested)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	// Paths are relative to the directory discovery started from
+	if c.Dir != filepath.Join("..", "..") || c.CollectionsDir() != filepath.Join("..", "..", "api", "collections") {
+		t.Errorf("Discover() Dir = %q, collections = %q", c.Dir, c.CollectionsDir())
+	}
+	if c.Resolve("/abs/data.csv") != "/abs/data.csv" {
+		t.Error("absolute paths should be returned unchanged")
+	}
+}
+
+func TestRoot_CollectionsMarker(t *testing.T) {
+	root := t.TempDir()
+	scripts := filepath.Join(root, "scripts", "users")
+	for _, dir := range []string{filepath.Join(root, "collections"), scripts} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A collections/ directory marks a project without plaintest.yaml
+	found, err := Root(scripts)
+	if err != nil || found != root {
+		t.Errorf("Root() = %q, %v; want %q", found, err, root)
+	}
+	c, err := Discover(scripts)
+	if err != nil || c.File != "" || c.DataDir() != filepath.Join("..", "..", "data") {
+		t.Errorf("Discover() = %+v, %v", c, err)
+	}
+
+	// Open uses the given root without searching
+	other := t.TempDir()
+	c, err = Open(root, other)
+	if err != nil || filepath.Join(other, c.CollectionsDir()) != filepath.Join(root, "collections") {
+		t.Errorf("Open() = %+v, %v", c, err)
+	}
+	if _, err := Open(filepath.Join(root, "missing"), root); err == nil {
+		t.Error("Open() should fail for a missing directory")
+	}
+}
@@ -0,0 +1,218 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// JUnit XML elements, in the layout GitLab and Jenkins read.
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property"`
+	Cases      []junitCase     `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+	Skipped   *struct{}     `xml:"skipped"`
+}
+
+type junitProblem struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// setupPhase is the phase whose failures are reported as errors.
+const setupPhase = "setup"
+
+// WriteJUnit writes a JUnit XML document for a whole run: one testsuite per
+// link and one testcase per assertion per iteration. Assertion failures in
+// the test phase are <failure>s. Failures in the setup phase, request errors
+// and script errors are <error>s, since they stop tests from running rather
+// than being test results. A link that was stopped gets an error case too.
+func WriteJUnit(w io.Writer, summaries []Summary) error {
+	doc := junitSuites{Name: "plaintest"}
+	var total time.Duration
+
+	for _, s := range summaries {
+		suite := s.junitSuite()
+		doc.Suites = append(doc.Suites, suite)
+		doc.Tests += suite.Tests
+		doc.Failures += suite.Failures
+		doc.Errors += suite.Errors
+		doc.Skipped += suite.Skipped
+		total += s.Duration
+	}
+	doc.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// SaveJUnit writes the JUnit XML for summaries to path, creating its
+// directory if needed.
+func SaveJUnit(path string, summaries []Summary) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("creating JUnit directory: %w", err)
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating JUnit file: %w", err)
+	}
+	if err := WriteJUnit(file, summaries); err != nil {
+		file.Close()
+		return fmt.Errorf("writing JUnit file: %w", err)
+	}
+	return file.Close()
+}
+
+func (s Summary) junitSuite() junitSuite {
+	suite := junitSuite{
+		Name: s.Phase + " " + s.Link,
+		Time: seconds(s.Duration),
+		Properties: []junitProperty{
+			{Name: "phase", Value: s.Phase},
+			{Name: "link", Value: s.Link},
+		},
+	}
+	if s.report != nil {
+		s.reportCases(&suite)
+	}
+	if s.Stopped != "" {
+		suite.Cases = append(suite.Cases, junitCase{
+			Name:      "stopped",
+			ClassName: s.Link,
+			Time:      seconds(s.Duration),
+			Error:     &junitProblem{Type: "Stopped", Message: s.Stopped, Text: s.Stopped},
+		})
+		suite.Errors++
+	}
+
+	suite.Tests = len(suite.Cases)
+	return suite
+}
+
+// reportCases adds a testcase to suite for every assertion and failure of
+// the link's report.
+func (s Summary) reportCases(suite *junitSuite) {
+	if started := s.report.Run.Timings.Started; started > 0 {
+		suite.Timestamp = time.UnixMilli(started).UTC().Format("2006-01-02T15:04:05")
+	}
+
+	setup := s.Phase == setupPhase
+	for _, exec := range s.report.Run.Executions {
+		className := s.Link + "." + exec.Item.Name
+		var elapsed time.Duration
+		if exec.Response != nil {
+			elapsed = time.Duration(exec.Response.ResponseTime) * time.Millisecond
+		}
+
+		for _, a := range exec.Assertions {
+			tc := junitCase{
+				Name:      s.caseName(exec.Cursor.Iteration, a.Assertion),
+				ClassName: className,
+				Time:      seconds(elapsed),
+			}
+			switch {
+			case a.Skipped:
+				tc.Skipped = &struct{}{}
+				suite.Skipped++
+			case a.Error != nil && setup:
+				tc.Error = problem("SetupFailure", *a.Error)
+				suite.Errors++
+			case a.Error != nil:
+				tc.Failure = problem(a.Error.Name, *a.Error)
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+	}
+
+	// Failures that are not assertions become error cases of their own
+	for _, f := range s.report.Run.Failures {
+		if strings.HasPrefix(f.At, "assertion") {
+			continue
+		}
+		kind := f.Error.Name
+		if setup {
+			kind = "SetupFailure"
+		}
+		suite.Cases = append(suite.Cases, junitCase{
+			Name:      s.caseName(f.Cursor.Iteration, f.At),
+			ClassName: s.Link + "." + f.Source.Name,
+			Time:      seconds(0),
+			Error:     problem(kind, f.Error),
+		})
+		suite.Errors++
+	}
+}
+
+// caseName names a testcase after its data row, so the same assertion in
+// different iterations stays distinguishable: "TC_001 Valid user: Status
+// code is 200", or "iteration 1: Status code is 200" without test_id and
+// test_name columns.
+func (s Summary) caseName(iteration int, test string) string {
+	label := s.label(iteration)
+	prefix := strings.TrimSpace(label.TestID + " " + label.TestName)
+	if prefix == "" {
+		prefix = fmt.Sprintf("iteration %d", iteration+1)
+	}
+	return prefix + ": " + test
+}
+
+func problem(kind string, e Error) *junitProblem {
+	if kind == "" {
+		kind = "Error"
+	}
+	text := e.Message
+	if e.Name != "" {
+		text = e.Name + ": " + e.Message
+	}
+	return &junitProblem{Type: kind, Message: e.Message, Text: text}
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,58 @@
+package report
+
+// Merge combines the reports of shards that ran consecutive row ranges of one
+// data file into a single report. offsets[i] is the number of data rows before
+// shard i; iterations are renumbered by it so the merged report reads as one
+// run in the original row order. Missing (nil) reports are skipped.
+func Merge(reports []*Report, offsets []int) *Report {
+	merged := &Report{}
+	for i, r := range reports {
+		if r == nil {
+			continue
+		}
+		if merged.Collection.Info.Name == "" {
+			merged.Collection = r.Collection
+		}
+
+		stats := &merged.Run.Stats
+		stats.Iterations.add(r.Run.Stats.Iterations)
+		stats.Items.add(r.Run.Stats.Items)
+		stats.Requests.add(r.Run.Stats.Requests)
+		stats.PrerequestScripts.add(r.Run.Stats.PrerequestScripts)
+		stats.TestScripts.add(r.Run.Stats.TestScripts)
+		stats.Assertions.add(r.Run.Stats.Assertions)
+
+		t := r.Run.Timings
+		if t.Started > 0 && (merged.Run.Timings.Started == 0 || t.Started < merged.Run.Timings.Started) {
+			merged.Run.Timings.Started = t.Started
+		}
+		if t.Completed > merged.Run.Timings.Completed {
+			merged.Run.Timings.Completed = t.Completed
+		}
+
+		for _, exec := range r.Run.Executions {
+			exec.Cursor.Iteration += offsets[i]
+			merged.Run.Executions = append(merged.Run.Executions, exec)
+		}
+		for _, f := range r.Run.Failures {
+			f.Cursor.Iteration += offsets[i]
+			merged.Run.Failures = append(merged.Run.Failures, f)
+		}
+	}
+
+	// Every cursor counts the iterations of the whole run
+	cycles := merged.Run.Stats.Iterations.Total
+	for i := range merged.Run.Executions {
+		merged.Run.Executions[i].Cursor.Cycles = cycles
+	}
+	for i := range merged.Run.Failures {
+		merged.Run.Failures[i].Cursor.Cycles = cycles
+	}
+	return merged
+}
+
+func (c *Count) add(other Count) {
+	c.Total += other.Total
+	c.Pending += other.Pending
+	c.Failed += other.Failed
+}
@@ -0,0 +1,136 @@
+// Package report reads the JSON report Newman writes with
+// --reporter-json-export and turns it into a short per-link summary.
+//
+// The native engine writes the same format, so one summary covers both
+// engines. Only the members plaintest uses are modelled.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Report is a Newman JSON report.
+type Report struct {
+	Collection Collection `json:"collection"`
+	Run        Run        `json:"run"`
+}
+
+// Collection identifies the collection that was run.
+type Collection struct {
+	Info struct {
+		ID   string `json:"_postman_id,omitempty"`
+		Name string `json:"name"`
+	} `json:"info"`
+}
+
+// Run is the outcome of a collection run.
+type Run struct {
+	Stats      Stats       `json:"stats"`
+	Timings    Timings     `json:"timings"`
+	Executions []Execution `json:"executions"`
+	Failures   []Failure   `json:"failures"`
+}
+
+// Stats counts what a run executed.
+type Stats struct {
+	Iterations        Count `json:"iterations"`
+	Items             Count `json:"items"`
+	Requests          Count `json:"requests"`
+	PrerequestScripts Count `json:"prerequestScripts"`
+	TestScripts       Count `json:"testScripts"`
+	Assertions        Count `json:"assertions"`
+}
+
+// Count is a Newman statistic.
+type Count struct {
+	Total   int `json:"total"`
+	Pending int `json:"pending"`
+	Failed  int `json:"failed"`
+}
+
+// Timings holds the start and end of a run in Unix milliseconds.
+type Timings struct {
+	Started   int64 `json:"started"`
+	Completed int64 `json:"completed"`
+}
+
+// Execution is one request of one iteration.
+type Execution struct {
+	Cursor       Cursor      `json:"cursor"`
+	Item         Item        `json:"item"`
+	Response     *Response   `json:"response,omitempty"`
+	RequestError *Error      `json:"requestError,omitempty"`
+	Assertions   []Assertion `json:"assertions,omitempty"`
+}
+
+// Cursor locates an execution within the run.
+type Cursor struct {
+	Iteration int `json:"iteration"`
+	Position  int `json:"position"`
+	Length    int `json:"length"`
+	Cycles    int `json:"cycles"`
+}
+
+// Item names the request an execution or failure belongs to.
+type Item struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+// Response is the part of a response the summary reports.
+type Response struct {
+	Code         int    `json:"code"`
+	Status       string `json:"status"`
+	ResponseTime int64  `json:"responseTime"`
+	ResponseSize int    `json:"responseSize"`
+}
+
+// Assertion is the outcome of one pm.test call.
+type Assertion struct {
+	Assertion string `json:"assertion"`
+	Skipped   bool   `json:"skipped"`
+	Error     *Error `json:"error,omitempty"`
+}
+
+// Error describes a failed assertion, script or request.
+type Error struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+	Test    string `json:"test,omitempty"`
+}
+
+// Failure is an entry of the report's failure list.
+type Failure struct {
+	Error  Error  `json:"error"`
+	At     string `json:"at"`
+	Source Item   `json:"source"`
+	Cursor Cursor `json:"cursor"`
+}
+
+// Load reads a report from path.
+func Load(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("invalid report %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// Save writes r to path, creating its directory if needed, as Newman does.
+func (r *Report) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating report directory: %w", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
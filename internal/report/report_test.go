@@ -0,0 +1,326 @@
+package report
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newmanReport is a trimmed report as written by newman run
+// --reporters json --reporter-json-export.
+const newmanReport = `{
+	"collection": {"info": {"_postman_id": "c1", "name": "Users", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"}, "item": []},
+	"environment": {"values": []},
+	"run": {
+		"stats": {
+			"iterations": {"total": 3, "pending": 0, "failed": 0},
+			"items": {"total": 3, "pending": 0, "failed": 0},
+			"requests": {"total": 3, "pending": 0, "failed": 1},
+			"testScripts": {"total": 2, "pending": 0, "failed": 0},
+			"assertions": {"total": 4, "pending": 0, "failed": 1}
+		},
+		"timings": {"responseAverage": 12, "started": 1700000000000, "completed": 1700000001250},
+		"executions": [
+			{"cursor": {"position": 0, "iteration": 0, "length": 1, "cycles": 3}, "item": {"id": "i1", "name": "Get User"},
+			 "response": {"id": "r1", "status": "OK", "code": 200, "responseTime": 10, "responseSize": 40},
+			 "assertions": [{"assertion": "Status code is 200", "skipped": false}, {"assertion": "Has name", "skipped": false}]}
+		],
+		"transfers": {"responseTotal": 120},
+		"failures": [
+			{"error": {"name": "AssertionError", "index": 0, "test": "Status code is 200", "message": "expected response to have status code 200 but got 404", "stack": "..."},
+			 "at": "assertion:0 in test-script", "source": {"id": "i1", "name": "Get User"}, "parent": {"id": "c1"}, "cursor": {"position": 0, "iteration": 1, "length": 1, "cycles": 3}},
+			{"error": {"name": "Error", "message": "connect ECONNREFUSED 127.0.0.1:80"},
+			 "at": "request", "source": {"id": "i1", "name": "Get User"}, "cursor": {"position": 0, "iteration": 4, "length": 1, "cycles": 3}}
+		]
+	}
+}`
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := os.WriteFile(path, []byte(newmanReport), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if r.Collection.Info.Name != "Users" || r.Run.Stats.Assertions.Total != 4 || len(r.Run.Executions) != 1 {
+		t.Errorf("Load() = %+v", r)
+	}
+
+	// A saved report loads back unchanged
+	saved := filepath.Join(t.TempDir(), "reports", "saved.json")
+	if err := r.Save(saved); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	again, err := Load(saved)
+	if err != nil || !reflect.DeepEqual(again, r) {
+		t.Errorf("round trip = %+v, %v", again, err)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Load() should fail for a missing report")
+	}
+}
+
+func TestReadLabels(t *testing.T) {
+	dir := t.TempDir()
+
+	csvPath := filepath.Join(dir, "users.csv")
+	csvData := "\ufefftest_name,test_id,input\nFirst,TC_001,a\n\"Second, quoted\",TC_002,b\nshort\n"
+	if err := os.WriteFile(csvPath, []byte(csvData), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadLabels(csvPath)
+	want := []Label{{"TC_001", "First"}, {"TC_002", "Second, quoted"}, {"", "short"}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadLabels(csv) = %v, %v; want %v", got, err, want)
+	}
+
+	jsonPath := filepath.Join(dir, "users.json")
+	if err := os.WriteFile(jsonPath, []byte(`[{"test_id": 7, "test_name": "Seven"}, {}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = ReadLabels(jsonPath)
+	want = []Label{{"7", "Seven"}, {}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadLabels(json) = %v, %v; want %v", got, err, want)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := os.WriteFile(path, []byte(newmanReport), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := []Label{{"TC_001", "ok"}, {"TC_002", "not found"}, {"TC_003", "last"}}
+	s := Summarize("users.Get User", "test", r, labels)
+
+	if s.Requests != 3 || s.RequestsFailed != 1 || s.Assertions != 4 || s.AssertionsFailed != 1 || s.Duration != 1250*time.Millisecond {
+		t.Errorf("Summarize() = %+v", s)
+	}
+	want := []FailureDetail{
+		{Iteration: 2, Label: labels[1], Item: "Get User", Test: "Status code is 200", Message: "expected response to have status code 200 but got 404"},
+		{Iteration: 5, Label: labels[2], Item: "Get User", Message: "connect ECONNREFUSED 127.0.0.1:80"},
+	}
+	if !reflect.DeepEqual(s.Failures, want) {
+		t.Errorf("failures = %+v, want %+v", s.Failures, want)
+	}
+	if s.Passed() {
+		t.Error("a summary with failures should not pass")
+	}
+
+	var out bytes.Buffer
+	stopped := Summary{Link: "orders", Phase: "test", Stopped: "interrupted"}
+	if stopped.Passed() {
+		t.Error("a stopped link should not pass")
+	}
+	Print(&out, []Summary{{Link: "auth", Phase: "setup", Requests: 1, Assertions: 2, Duration: 250 * time.Millisecond}, s, stopped})
+	for _, line := range []string{
+		"  setup auth: passed - 1 request, 2/2 assertions passed (250ms)",
+		"  test users.Get User: FAILED - 3 requests, 3/4 assertions passed, 1 request errors (1.25s)",
+		"    ✗ [TC_002] iteration 2, Get User: Status code is 200: expected response to have status code 200 but got 404",
+		"  test orders: STOPPED - 0 requests, 0/0 assertions passed (0s)",
+		"    ✗ interrupted",
+		"  Total: 4 requests, 5/6 assertions passed (1.5s)",
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("Print() output is missing %q:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	setup := &Report{}
+	setup.Run.Stats.Assertions = Count{Total: 1, Failed: 1}
+	setup.Run.Executions = []Execution{{
+		Item:       Item{Name: "Login"},
+		Response:   &Response{Code: 401, ResponseTime: 120},
+		Assertions: []Assertion{{Assertion: "Login successful", Error: &Error{Name: "AssertionError", Message: "expected 401 to equal 200", Test: "Login successful"}}},
+	}}
+
+	tests := &Report{}
+	tests.Run.Timings = Timings{Started: 1700000000000, Completed: 1700000000500}
+	tests.Run.Executions = []Execution{
+		{Cursor: Cursor{Iteration: 0}, Item: Item{Name: "Get User"}, Response: &Response{ResponseTime: 40},
+			Assertions: []Assertion{{Assertion: "Status code is 200"}, {Assertion: "Later", Skipped: true}}},
+		{Cursor: Cursor{Iteration: 1}, Item: Item{Name: "Get User"}, Response: &Response{ResponseTime: 60},
+			Assertions: []Assertion{{Assertion: "Status code is 200", Error: &Error{Name: "AssertionError", Message: "expected response to have status code 200 but got 404 & <more>"}}}},
+		{Cursor: Cursor{Iteration: 2}, Item: Item{Name: "Get User"}, RequestError: &Error{Name: "Error", Message: "ECONNREFUSED"}},
+	}
+	tests.Run.Failures = []Failure{
+		{Error: Error{Name: "AssertionError", Message: "expected response to have status code 200 but got 404"}, At: "assertion:0 in test-script", Source: Item{Name: "Get User"}, Cursor: Cursor{Iteration: 1}},
+		{Error: Error{Name: "Error", Message: "ECONNREFUSED"}, At: "request", Source: Item{Name: "Get User"}, Cursor: Cursor{Iteration: 2}},
+	}
+
+	summaries := []Summary{
+		Summarize("auth", "setup", setup, nil),
+		Summarize("users", "test", tests, []Label{{"TC_001", "Valid user"}, {"TC_002", ""}, {"", ""}}),
+	}
+
+	var out bytes.Buffer
+	if err := WriteJUnit(&out, summaries); err != nil {
+		t.Fatalf("WriteJUnit() error = %v", err)
+	}
+	got := out.String()
+
+	for _, want := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<testsuites name="plaintest" tests="5" failures="1" errors="2" skipped="1" time="0.500">`,
+		`<testsuite name="setup auth" tests="1" failures="0" errors="1" skipped="0" time="0.000">`,
+		`<property name="phase" value="setup"></property>`,
+		`<testcase name="iteration 1: Login successful" classname="auth.Login" time="0.120">`,
+		`<error type="SetupFailure" message="expected 401 to equal 200">AssertionError: expected 401 to equal 200</error>`,
+		`<testsuite name="test users" tests="4" failures="1" errors="1" skipped="1" time="0.500" timestamp="2023-11-14T22:13:20">`,
+		`<testcase name="TC_001 Valid user: Status code is 200" classname="users.Get User" time="0.040"></testcase>`,
+		`<testcase name="TC_001 Valid user: Later" classname="users.Get User" time="0.040">`,
+		`<skipped></skipped>`,
+		`<testcase name="TC_002: Status code is 200" classname="users.Get User" time="0.060">`,
+		`<failure type="AssertionError" message="expected response to have status code 200 but got 404 &amp; &lt;more&gt;">`,
+		`<testcase name="iteration 3: request" classname="users.Get User" time="0.000">`,
+		`<error type="Error" message="ECONNREFUSED">Error: ECONNREFUSED</error>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("JUnit output is missing %s\n%s", want, got)
+		}
+	}
+
+	// A link stopped before it wrote a report is an error of its own
+	out.Reset()
+	stopped := []Summary{{Link: "orders", Phase: "test", Stopped: "timed out after 30s", Duration: 30 * time.Second}}
+	if err := WriteJUnit(&out, stopped); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<testsuite name="test orders" tests="1" failures="0" errors="1" skipped="0" time="30.000">`,
+		`<testcase name="stopped" classname="orders" time="30.000">`,
+		`<error type="Stopped" message="timed out after 30s">timed out after 30s</error>`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("JUnit output is missing %s\n%s", want, out.String())
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "reports", "junit.xml")
+	if err := SaveJUnit(path, summaries); err != nil {
+		t.Fatalf("SaveJUnit() error = %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != got {
+		t.Errorf("SaveJUnit() wrote %q, %v", data, err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	shard := func(iterations int, started, completed int64, failIteration int) *Report {
+		r := &Report{}
+		r.Collection.Info.Name = "Users"
+		r.Run.Stats.Iterations = Count{Total: iterations}
+		r.Run.Stats.Requests = Count{Total: iterations}
+		r.Run.Stats.Assertions = Count{Total: iterations, Failed: 1}
+		r.Run.Timings = Timings{Started: started, Completed: completed}
+		for i := 0; i < iterations; i++ {
+			r.Run.Executions = append(r.Run.Executions, Execution{Cursor: Cursor{Iteration: i, Cycles: iterations}, Item: Item{Name: "Get User"}})
+		}
+		r.Run.Failures = []Failure{{Error: Error{Message: "boom"}, At: "assertion:0 in test-script", Cursor: Cursor{Iteration: failIteration}}}
+		return r
+	}
+
+	merged := Merge([]*Report{shard(3, 2000, 2500, 1), nil, shard(2, 1000, 3000, 0)}, []int{0, 3, 3})
+
+	if merged.Collection.Info.Name != "Users" || merged.Run.Stats.Iterations.Total != 5 || merged.Run.Stats.Assertions != (Count{Total: 5, Failed: 2}) {
+		t.Errorf("Merge() stats = %+v", merged.Run.Stats)
+	}
+	if merged.Run.Timings != (Timings{Started: 1000, Completed: 3000}) {
+		t.Errorf("Merge() timings = %+v", merged.Run.Timings)
+	}
+
+	var iterations []int
+	for _, exec := range merged.Run.Executions {
+		iterations = append(iterations, exec.Cursor.Iteration)
+		if exec.Cursor.Cycles != 5 {
+			t.Errorf("cycles = %d, want 5", exec.Cursor.Cycles)
+		}
+	}
+	if !reflect.DeepEqual(iterations, []int{0, 1, 2, 3, 4}) {
+		t.Errorf("execution iterations = %v", iterations)
+	}
+	if merged.Run.Failures[0].Cursor.Iteration != 1 || merged.Run.Failures[1].Cursor.Iteration != 3 {
+		t.Errorf("failures = %+v", merged.Run.Failures)
+	}
+
+	// Failures point at the right data row once summarized
+	s := Summarize("users", "test", merged, []Label{{"TC_1", ""}, {"TC_2", ""}, {"TC_3", ""}, {"TC_4", ""}, {"TC_5", ""}})
+	if s.Failures[0].Label.TestID != "TC_2" || s.Failures[1].Label.TestID != "TC_4" {
+		t.Errorf("failure labels = %+v", s.Failures)
+	}
+}
+
+func TestApplyRetry(t *testing.T) {
+	exec := func(iteration int, failed bool) Execution {
+		a := Assertion{Assertion: "Status code is 200"}
+		if failed {
+			a.Error = &Error{Name: "AssertionError", Message: "got 503", Test: a.Assertion}
+		}
+		return Execution{Cursor: Cursor{Iteration: iteration}, Item: Item{Name: "Get User"}, Assertions: []Assertion{a}}
+	}
+	failure := func(iteration int) Failure {
+		return Failure{Error: Error{Name: "AssertionError", Message: "got 503", Test: "Status code is 200"}, At: "assertion:0 in test-script", Source: Item{Name: "Get User"}, Cursor: Cursor{Iteration: iteration}}
+	}
+
+	// Iterations 1 and 3 of 4 failed
+	r := &Report{}
+	r.Run.Stats.Iterations = Count{Total: 4}
+	r.Run.Stats.Requests = Count{Total: 4}
+	r.Run.Stats.Assertions = Count{Total: 4, Failed: 2}
+	r.Run.Executions = []Execution{exec(0, false), exec(1, true), exec(2, false), exec(3, true)}
+	r.Run.Failures = []Failure{failure(1), failure(3)}
+
+	if got := r.FailedIterations(); !reflect.DeepEqual(got, []int{1, 3}) {
+		t.Fatalf("FailedIterations() = %v", got)
+	}
+
+	// The rerun of those two rows passes the first and fails the second again
+	rerun := &Report{}
+	rerun.Run.Executions = []Execution{exec(0, false), exec(1, true)}
+	rerun.Run.Failures = []Failure{failure(1)}
+	rerun.Run.Timings.Completed = 5000
+	r.ApplyRetry(rerun, []int{1, 3})
+
+	if r.Run.Stats.Requests != (Count{Total: 4}) || r.Run.Stats.Assertions != (Count{Total: 4, Failed: 1}) {
+		t.Errorf("stats = %+v", r.Run.Stats)
+	}
+	if len(r.Run.Executions) != 4 || r.Run.Executions[1].Assertions[0].Error != nil || r.Run.Executions[3].Assertions[0].Error == nil {
+		t.Errorf("executions = %+v", r.Run.Executions)
+	}
+	if got := r.FailedIterations(); !reflect.DeepEqual(got, []int{3}) {
+		t.Errorf("FailedIterations() after retry = %v", got)
+	}
+	if r.Run.Timings.Completed != 5000 {
+		t.Errorf("completed = %d", r.Run.Timings.Completed)
+	}
+
+	s := Summarize("users", "test", r, []Label{{"TC_1", ""}, {"TC_2", ""}, {"TC_3", ""}, {"TC_4", ""}})
+	s.AddFlaky(1, 12, 1)
+	var out bytes.Buffer
+	Print(&out, []Summary{s})
+	for _, line := range []string{
+		"  test users: FAILED - 4 requests, 3/4 assertions passed, 1 flaky (5s)",
+		"    ✗ [TC_4] iteration 4, Get User: Status code is 200: got 503",
+		"    ~ [TC_2] row 12 passed on retry 1",
+		"  Total: 4 requests, 3/4 assertions passed, 1 flaky (5s)",
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("Print() output is missing %q:\n%s", line, out.String())
+		}
+	}
+}
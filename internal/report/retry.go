@@ -0,0 +1,105 @@
+package report
+
+import "sort"
+
+// FailedIterations returns the zero based iterations with a failure, sorted.
+func (r *Report) FailedIterations() []int {
+	seen := make(map[int]bool)
+	var iterations []int
+	for _, f := range r.Run.Failures {
+		if !seen[f.Cursor.Iteration] {
+			seen[f.Cursor.Iteration] = true
+			iterations = append(iterations, f.Cursor.Iteration)
+		}
+	}
+	sort.Ints(iterations)
+	return iterations
+}
+
+// ApplyRetry replaces iterations of r with a rerun of them. iterations[j] is
+// the iteration of r that iteration j of the rerun repeated. Executions,
+// failures and the request and assertion counts of those iterations are
+// taken from the rerun.
+func (r *Report) ApplyRetry(rerun *Report, iterations []int) {
+	retried := make(map[int]bool, len(iterations))
+	for _, iteration := range iterations {
+		retried[iteration] = true
+	}
+	original := func(iteration int) (int, bool) {
+		if iteration < 0 || iteration >= len(iterations) {
+			return 0, false
+		}
+		return iterations[iteration], true
+	}
+
+	stats := &r.Run.Stats
+	executions := r.Run.Executions[:0:0]
+	for _, exec := range r.Run.Executions {
+		if retried[exec.Cursor.Iteration] {
+			stats.Requests.add(exec.requestCounts(-1))
+			stats.Assertions.add(exec.assertionCounts(-1))
+			continue
+		}
+		executions = append(executions, exec)
+	}
+	for _, exec := range rerun.Run.Executions {
+		iteration, ok := original(exec.Cursor.Iteration)
+		if !ok {
+			continue
+		}
+		exec.Cursor.Iteration = iteration
+		exec.Cursor.Cycles = stats.Iterations.Total
+		stats.Requests.add(exec.requestCounts(1))
+		stats.Assertions.add(exec.assertionCounts(1))
+		executions = append(executions, exec)
+	}
+	sort.SliceStable(executions, func(i, j int) bool {
+		return executions[i].Cursor.Iteration < executions[j].Cursor.Iteration
+	})
+	r.Run.Executions = executions
+
+	failures := r.Run.Failures[:0:0]
+	for _, f := range r.Run.Failures {
+		if !retried[f.Cursor.Iteration] {
+			failures = append(failures, f)
+		}
+	}
+	for _, f := range rerun.Run.Failures {
+		iteration, ok := original(f.Cursor.Iteration)
+		if !ok {
+			continue
+		}
+		f.Cursor.Iteration = iteration
+		f.Cursor.Cycles = stats.Iterations.Total
+		failures = append(failures, f)
+	}
+	sort.SliceStable(failures, func(i, j int) bool {
+		return failures[i].Cursor.Iteration < failures[j].Cursor.Iteration
+	})
+	r.Run.Failures = failures
+
+	if rerun.Run.Timings.Completed > r.Run.Timings.Completed {
+		r.Run.Timings.Completed = rerun.Run.Timings.Completed
+	}
+}
+
+// requestCounts returns the request count of exec, multiplied by sign.
+func (exec Execution) requestCounts(sign int) Count {
+	c := Count{Total: sign}
+	if exec.RequestError != nil {
+		c.Failed = sign
+	}
+	return c
+}
+
+// assertionCounts returns the assertion count of exec, multiplied by sign.
+func (exec Execution) assertionCounts(sign int) Count {
+	var c Count
+	for _, a := range exec.Assertions {
+		c.Total += sign
+		if a.Error != nil {
+			c.Failed += sign
+		}
+	}
+	return c
+}
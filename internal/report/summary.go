@@ -0,0 +1,260 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Label identifies an iteration by the META columns of its data row.
+type Label struct {
+	TestID   string
+	TestName string
+}
+
+// ReadLabels returns the test_id and test_name of every row of a CSV or JSON
+// data file, in iteration order.
+func ReadLabels(dataPath string) ([]Label, error) {
+	file, err := os.Open(dataPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open data file: %w", err)
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(dataPath), ".json") {
+		var rows []map[string]any
+		if err := json.NewDecoder(file).Decode(&rows); err != nil {
+			return nil, fmt.Errorf("invalid JSON data file: %w", err)
+		}
+		labels := make([]Label, len(rows))
+		for i, row := range rows {
+			if v, ok := row["test_id"]; ok && v != nil {
+				labels[i].TestID = fmt.Sprint(v)
+			}
+			if v, ok := row["test_name"]; ok && v != nil {
+				labels[i].TestName = fmt.Sprint(v)
+			}
+		}
+		return labels, nil
+	}
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV data file: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	idColumn, nameColumn := -1, -1
+	for i, name := range records[0] {
+		switch strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")) {
+		case "test_id":
+			idColumn = i
+		case "test_name":
+			nameColumn = i
+		}
+	}
+	cell := func(record []string, i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	labels := make([]Label, 0, len(records)-1)
+	for _, record := range records[1:] {
+		labels = append(labels, Label{TestID: cell(record, idColumn), TestName: cell(record, nameColumn)})
+	}
+	return labels, nil
+}
+
+// Summary is the outcome of one link of a plaintest run.
+type Summary struct {
+	Link             string
+	Phase            string
+	Iterations       int
+	Requests         int
+	RequestsFailed   int
+	Assertions       int
+	AssertionsFailed int
+	Duration         time.Duration
+	Failures         []FailureDetail
+	// Flaky lists the rows that failed and then passed when retried.
+	Flaky []FlakyRow
+	// Stopped is why the link did not run to the end, such as "timed out
+	// after 30s"; empty when it finished.
+	Stopped string
+
+	// report and labels are kept for WriteJUnit.
+	report *Report
+	labels []Label
+}
+
+// FailureDetail is a failure tied back to the data row that caused it.
+type FailureDetail struct {
+	// Iteration is one based.
+	Iteration int
+	Label     Label
+	Item      string
+	Test      string
+	Message   string
+}
+
+// FlakyRow is a data row that passed only when retried.
+type FlakyRow struct {
+	// Row is the one based row of the data file.
+	Row   int
+	Label Label
+	// Attempt is the retry the row passed on, from 1.
+	Attempt int
+}
+
+// Passed reports whether the link had no failures.
+func (s Summary) Passed() bool {
+	return len(s.Failures) == 0 && s.RequestsFailed == 0 && s.AssertionsFailed == 0 && s.Stopped == ""
+}
+
+// Summarize builds the summary of link from its report. labels are the data
+// rows of the run; iterations past the last row are labelled with it, as
+// Newman reuses the last row for them.
+func Summarize(link, phase string, r *Report, labels []Label) Summary {
+	stats := r.Run.Stats
+	s := Summary{
+		Link:             link,
+		Phase:            phase,
+		Iterations:       stats.Iterations.Total,
+		Requests:         stats.Requests.Total,
+		RequestsFailed:   stats.Requests.Failed,
+		Assertions:       stats.Assertions.Total,
+		AssertionsFailed: stats.Assertions.Failed,
+		report:           r,
+		labels:           labels,
+	}
+	if t := r.Run.Timings; t.Completed > t.Started {
+		s.Duration = time.Duration(t.Completed-t.Started) * time.Millisecond
+	}
+
+	for _, f := range r.Run.Failures {
+		detail := FailureDetail{
+			Iteration: f.Cursor.Iteration + 1,
+			Item:      f.Source.Name,
+			Test:      f.Error.Test,
+			Message:   f.Error.Message,
+		}
+		detail.Label = s.label(f.Cursor.Iteration)
+		s.Failures = append(s.Failures, detail)
+	}
+	return s
+}
+
+// AddFlaky records that the zero based iteration, on row of the data file,
+// passed on retry attempt.
+func (s *Summary) AddFlaky(iteration, row, attempt int) {
+	s.Flaky = append(s.Flaky, FlakyRow{Row: row, Label: s.label(iteration), Attempt: attempt})
+}
+
+// label returns the data row label of a zero based iteration.
+func (s Summary) label(iteration int) Label {
+	if len(s.labels) == 0 || iteration < 0 {
+		return Label{}
+	}
+	if iteration >= len(s.labels) {
+		iteration = len(s.labels) - 1
+	}
+	return s.labels[iteration]
+}
+
+// Print writes the summary of a whole run, one block per link.
+func Print(w io.Writer, summaries []Summary) {
+	if len(summaries) == 0 {
+		return
+	}
+
+	var total Summary
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run Summary:")
+	for _, s := range summaries {
+		status := "passed"
+		switch {
+		case s.Stopped != "":
+			status = "STOPPED"
+		case !s.Passed():
+			status = "FAILED"
+		}
+		fmt.Fprintf(w, "  %s %s: %s - %s, %s", s.Phase, s.Link, status, plural(s.Requests, "request"), assertions(s))
+		if s.RequestsFailed > 0 {
+			fmt.Fprintf(w, ", %d request errors", s.RequestsFailed)
+		}
+		if len(s.Flaky) > 0 {
+			fmt.Fprintf(w, ", %d flaky", len(s.Flaky))
+		}
+		fmt.Fprintf(w, " (%s)\n", s.Duration.Round(time.Millisecond))
+
+		if s.Stopped != "" {
+			fmt.Fprintf(w, "    ✗ %s\n", s.Stopped)
+		}
+		for _, f := range s.Failures {
+			fmt.Fprintf(w, "    ✗ %s\n", f.String())
+		}
+		for _, f := range s.Flaky {
+			fmt.Fprintf(w, "    ~ %s\n", f.String())
+		}
+
+		total.Requests += s.Requests
+		total.RequestsFailed += s.RequestsFailed
+		total.Assertions += s.Assertions
+		total.AssertionsFailed += s.AssertionsFailed
+		total.Duration += s.Duration
+		total.Flaky = append(total.Flaky, s.Flaky...)
+	}
+	fmt.Fprintf(w, "  Total: %s, %s", plural(total.Requests, "request"), assertions(total))
+	if len(total.Flaky) > 0 {
+		fmt.Fprintf(w, ", %d flaky", len(total.Flaky))
+	}
+	fmt.Fprintf(w, " (%s)\n", total.Duration.Round(time.Millisecond))
+}
+
+// String formats a failure as "[TC_001] iteration 1, Item: test: message".
+func (f FailureDetail) String() string {
+	var b strings.Builder
+	if f.Label.TestID != "" {
+		fmt.Fprintf(&b, "[%s] ", f.Label.TestID)
+	}
+	fmt.Fprintf(&b, "iteration %d, %s", f.Iteration, f.Item)
+	if f.Test != "" {
+		fmt.Fprintf(&b, ": %s", f.Test)
+	}
+	if f.Message != "" {
+		fmt.Fprintf(&b, ": %s", f.Message)
+	}
+	return b.String()
+}
+
+// String formats a flaky row as "[TC_001] row 1 passed on retry 1".
+func (f FlakyRow) String() string {
+	var b strings.Builder
+	if f.Label.TestID != "" {
+		fmt.Fprintf(&b, "[%s] ", f.Label.TestID)
+	}
+	fmt.Fprintf(&b, "row %d passed on retry %d", f.Row, f.Attempt)
+	return b.String()
+}
+
+func assertions(s Summary) string {
+	return fmt.Sprintf("%d/%d assertions passed", s.Assertions-s.AssertionsFailed, s.Assertions)
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
@@ -0,0 +1,597 @@
+// A Chai compatible subset of the expect interface, enough for the
+// assertions Postman scripts commonly use through pm.expect and pm.response.to.
+(function (global) {
+    'use strict';
+
+    function AssertionError(message) {
+        this.name = 'AssertionError';
+        this.message = message;
+    }
+    AssertionError.prototype = Object.create(Error.prototype);
+    AssertionError.prototype.constructor = AssertionError;
+    AssertionError.prototype.toString = function () {
+        return this.name + ': ' + this.message;
+    };
+
+    function type(v) {
+        if (v === null) return 'null';
+        if (Array.isArray(v)) return 'array';
+        if (v instanceof RegExp) return 'regexp';
+        if (v instanceof Date) return 'date';
+        if (v instanceof Error) return 'error';
+        return typeof v;
+    }
+
+    function inspectValue(v, depth) {
+        switch (type(v)) {
+            case 'undefined':
+            case 'null':
+            case 'boolean':
+            case 'regexp':
+                return String(v);
+            case 'number':
+                return v === 0 && 1 / v < 0 ? '-0' : String(v);
+            case 'string':
+                return "'" + v + "'";
+            case 'date':
+                return v.toISOString();
+            case 'function':
+                return '[Function' + (v.name ? ': ' + v.name : '') + ']';
+            case 'error':
+                return v.name + ': ' + v.message;
+            case 'array':
+                if (depth > 2) return '[Array]';
+                if (v.length === 0) return '[]';
+                return '[ ' + v.map(function (item) { return inspectValue(item, depth + 1); }).join(', ') + ' ]';
+            default:
+                if (v.__response) return 'response';
+                if (depth > 2) return '[Object]';
+                var keys = Object.keys(v);
+                if (keys.length === 0) return '{}';
+                return '{ ' + keys.map(function (k) { return k + ': ' + inspectValue(v[k], depth + 1); }).join(', ') + ' }';
+        }
+    }
+
+    // inspect formats a value the way Chai does in assertion messages,
+    // shortening long arrays and objects.
+    function inspect(v) {
+        var s = inspectValue(v, 0);
+        if (s.length <= 40) return s;
+        if (type(v) === 'array') return '[ Array(' + v.length + ') ]';
+        if (type(v) === 'object' && !v.__response) {
+            var keys = Object.keys(v);
+            var names = keys.length > 2 ? keys.slice(0, 2).join(', ') + ', ...' : keys.join(', ');
+            return '{ Object (' + names + ') }';
+        }
+        return s;
+    }
+
+    function deepEqual(a, b) {
+        if (a === b) return a !== 0 || 1 / a === 1 / b;
+        if (a !== a && b !== b) return true; // NaN
+        var ta = type(a);
+        if (ta !== type(b)) return false;
+        switch (ta) {
+            case 'date':
+                return a.getTime() === b.getTime();
+            case 'regexp':
+                return String(a) === String(b);
+            case 'array':
+                if (a.length !== b.length) return false;
+                for (var i = 0; i < a.length; i++) {
+                    if (!deepEqual(a[i], b[i])) return false;
+                }
+                return true;
+            case 'object':
+                var ka = Object.keys(a).sort();
+                var kb = Object.keys(b).sort();
+                if (!deepEqual(ka, kb)) return false;
+                for (var j = 0; j < ka.length; j++) {
+                    if (!deepEqual(a[ka[j]], b[ka[j]])) return false;
+                }
+                return true;
+            default:
+                return false;
+        }
+    }
+
+    function Assertion(obj, message) {
+        this.__flags = { object: obj, message: message };
+    }
+
+    function flag(assertion, key, value) {
+        if (arguments.length === 3) {
+            assertion.__flags[key] = value;
+        }
+        return assertion.__flags[key];
+    }
+
+    function format(template, values) {
+        return template.split('#{this}').join(values.self)
+            .split('#{exp}').join(values.exp)
+            .split('#{act}').join(values.act);
+    }
+
+    Assertion.prototype.assert = function (ok, message, negatedMessage, expected, actual) {
+        var negate = flag(this, 'negate');
+        if (negate ? !ok : ok) return;
+
+        var text = format(negate ? negatedMessage : message, {
+            self: inspect(flag(this, 'object')),
+            exp: inspect(expected),
+            act: inspect(actual)
+        });
+        var custom = flag(this, 'message');
+        throw new AssertionError(custom ? custom + ': ' + text : text);
+    };
+
+    function addProperty(name, getter) {
+        Object.defineProperty(Assertion.prototype, name, {
+            get: function () {
+                var result = getter.call(this);
+                return result === undefined ? this : result;
+            },
+            configurable: true
+        });
+    }
+
+    function addMethod(name, method) {
+        Assertion.prototype[name] = function () {
+            var result = method.apply(this, arguments);
+            return result === undefined ? this : result;
+        };
+    }
+
+    // addChainableMethod defines a word that works both as a chain, as in
+    // `.to.include.keys('a')`, and as a call, as in `.to.include('a')`.
+    function addChainableMethod(name, method, chain) {
+        Object.defineProperty(Assertion.prototype, name, {
+            get: function () {
+                var assertion = this;
+                if (chain) chain.call(assertion);
+                var callable = function () {
+                    var result = method.apply(assertion, arguments);
+                    return result === undefined ? assertion : result;
+                };
+                callable.__flags = assertion.__flags;
+                Object.setPrototypeOf(callable, Assertion.prototype);
+                return callable;
+            },
+            configurable: true
+        });
+    }
+
+    ['to', 'be', 'been', 'is', 'and', 'has', 'have', 'with', 'that', 'which',
+        'at', 'of', 'same', 'but', 'does', 'still', 'also'].forEach(function (word) {
+        addProperty(word, function () {});
+    });
+
+    addProperty('not', function () { flag(this, 'negate', !flag(this, 'negate')); });
+    addProperty('deep', function () { flag(this, 'deep', true); });
+    addProperty('own', function () { flag(this, 'own', true); });
+    addProperty('any', function () { flag(this, 'any', true); flag(this, 'all', false); });
+    addProperty('all', function () { flag(this, 'all', true); flag(this, 'any', false); });
+    addProperty('nested', function () { flag(this, 'nested', true); });
+    addProperty('ordered', function () { flag(this, 'ordered', true); });
+
+    function isResponse(v) {
+        return v !== null && typeof v === 'object' && v.__response === true;
+    }
+
+    function statusRange(assertion, low, high, description) {
+        var code = flag(assertion, 'object').code;
+        assertion.assert(code >= low && code <= high,
+            'expected response code to be ' + description + ' but found ' + code,
+            'expected response code to not be ' + description + ' but found ' + code);
+    }
+
+    addProperty('ok', function () {
+        var obj = flag(this, 'object');
+        if (isResponse(obj)) return statusRange(this, 200, 299, '2XX');
+        this.assert(!!obj, 'expected #{this} to be truthy', 'expected #{this} to be falsy');
+    });
+    addProperty('true', function () {
+        this.assert(flag(this, 'object') === true, 'expected #{this} to be true', 'expected #{this} to be false');
+    });
+    addProperty('false', function () {
+        this.assert(flag(this, 'object') === false, 'expected #{this} to be false', 'expected #{this} to be true');
+    });
+    addProperty('null', function () {
+        this.assert(flag(this, 'object') === null, 'expected #{this} to be null', 'expected #{this} not to be null');
+    });
+    addProperty('undefined', function () {
+        this.assert(flag(this, 'object') === undefined, 'expected #{this} to be undefined', 'expected #{this} not to be undefined');
+    });
+    addProperty('NaN', function () {
+        var obj = flag(this, 'object');
+        this.assert(obj !== obj, 'expected #{this} to be NaN', 'expected #{this} not to be NaN');
+    });
+    addProperty('finite', function () {
+        var obj = flag(this, 'object');
+        this.assert(typeof obj === 'number' && isFinite(obj), 'expected #{this} to be a finite number', 'expected #{this} to not be a finite number');
+    });
+    addProperty('exist', function () {
+        var obj = flag(this, 'object');
+        this.assert(obj !== null && obj !== undefined, 'expected #{this} to exist', 'expected #{this} to not exist');
+    });
+    addProperty('empty', function () {
+        var obj = flag(this, 'object');
+        var size;
+        switch (type(obj)) {
+            case 'string':
+            case 'array':
+                size = obj.length;
+                break;
+            case 'object':
+                size = Object.keys(obj).length;
+                break;
+            default:
+                throw new TypeError(inspect(obj) + ' is not a string, array or object');
+        }
+        this.assert(size === 0, 'expected #{this} to be empty', 'expected #{this} not to be empty');
+    });
+
+    // Response status shortcuts used as pm.response.to.be.<name>
+    [
+        ['success', 200, 299, '2XX'],
+        ['info', 100, 199, '1XX'],
+        ['redirection', 300, 399, '3XX'],
+        ['clientError', 400, 499, '4XX'],
+        ['serverError', 500, 599, '5XX'],
+        ['error', 400, 599, '4XX or 5XX'],
+        ['accepted', 202, 202, '202'],
+        ['badRequest', 400, 400, '400'],
+        ['unauthorized', 401, 401, '401'],
+        ['unauthorised', 401, 401, '401'],
+        ['forbidden', 403, 403, '403'],
+        ['notFound', 404, 404, '404'],
+        ['rateLimited', 429, 429, '429']
+    ].forEach(function (entry) {
+        addProperty(entry[0], function () {
+            statusRange(this, entry[1], entry[2], entry[3]);
+        });
+    });
+
+    addProperty('json', function () {
+        var obj = flag(this, 'object');
+        var parsed = true;
+        try {
+            obj.json();
+        } catch (e) {
+            parsed = false;
+        }
+        this.assert(parsed, 'expected response body to be a valid json', 'expected response body not to be a valid json');
+    });
+
+    addProperty('withBody', function () {
+        var body = flag(this, 'object').text();
+        this.assert(body.length > 0, 'expected response to have content in body', 'expected response to not have content in body');
+    });
+
+    function equal(expected) {
+        var actual = flag(this, 'object');
+        if (flag(this, 'deep')) return eql.call(this, expected);
+        if (flag(this, 'doLength')) {
+            actual = actual.length;
+            this.assert(actual === expected, 'expected #{this} to have a length of #{exp} but got #{act}',
+                'expected #{this} to not have a length of #{act}', expected, actual);
+            return;
+        }
+        this.assert(actual === expected, 'expected #{this} to equal #{exp}', 'expected #{this} to not equal #{exp}', expected, actual);
+    }
+
+    function eql(expected) {
+        this.assert(deepEqual(flag(this, 'object'), expected), 'expected #{this} to deeply equal #{exp}',
+            'expected #{this} to not deeply equal #{exp}', expected);
+    }
+
+    ['equal', 'equals', 'eq'].forEach(function (name) { addMethod(name, equal); });
+    ['eql', 'eqls'].forEach(function (name) { addMethod(name, eql); });
+
+    function compare(symbol, words, test) {
+        return function (n) {
+            var obj = flag(this, 'object');
+            if (flag(this, 'doLength')) {
+                var len = obj.length;
+                this.assert(test(len, n), 'expected #{this} to have a length ' + words + ' #{exp} but got #{act}',
+                    'expected #{this} to not have a length ' + words + ' #{exp}', n, len);
+                return;
+            }
+            this.assert(test(obj, n), 'expected #{this} to be ' + words + ' #{exp}',
+                'expected #{this} to be ' + symbol + ' #{exp}', n);
+        };
+    }
+
+    var above = compare('at most', 'above', function (a, b) { return a > b; });
+    var least = compare('below', 'at least', function (a, b) { return a >= b; });
+    var below = compare('at least', 'below', function (a, b) { return a < b; });
+    var most = compare('above', 'at most', function (a, b) { return a <= b; });
+    ['above', 'gt', 'greaterThan'].forEach(function (name) { addMethod(name, above); });
+    ['least', 'gte', 'greaterThanOrEqual'].forEach(function (name) { addMethod(name, least); });
+    ['below', 'lt', 'lessThan'].forEach(function (name) { addMethod(name, below); });
+    ['most', 'lte', 'lessThanOrEqual'].forEach(function (name) { addMethod(name, most); });
+
+    addMethod('within', function (start, finish) {
+        var obj = flag(this, 'object');
+        var value = flag(this, 'doLength') ? obj.length : obj;
+        var range = start + '..' + finish;
+        this.assert(value >= start && value <= finish, 'expected #{this} to be within ' + range,
+            'expected #{this} to not be within ' + range);
+    });
+
+    ['closeTo', 'approximately'].forEach(function (name) {
+        addMethod(name, function (expected, delta) {
+            var obj = flag(this, 'object');
+            this.assert(Math.abs(obj - expected) <= delta, 'expected #{this} to be close to ' + expected + ' +/- ' + delta,
+                'expected #{this} not to be close to ' + expected + ' +/- ' + delta);
+        });
+    });
+
+    function typeAssertion(expected) {
+        expected = String(expected).toLowerCase();
+        var article = /^[aeiou]/.test(expected) ? 'an ' : 'a ';
+        this.assert(type(flag(this, 'object')) === expected, 'expected #{this} to be ' + article + expected,
+            'expected #{this} not to be ' + article + expected);
+    }
+    addChainableMethod('a', typeAssertion);
+    addChainableMethod('an', typeAssertion);
+
+    ['instanceof', 'instanceOf'].forEach(function (name) {
+        addMethod(name, function (constructor) {
+            var cname = constructor && constructor.name ? constructor.name : 'constructor';
+            this.assert(flag(this, 'object') instanceof constructor, 'expected #{this} to be an instance of ' + cname,
+                'expected #{this} to not be an instance of ' + cname);
+        });
+    });
+
+    function includes(haystack, needle, deep) {
+        switch (type(haystack)) {
+            case 'string':
+                return haystack.indexOf(needle) !== -1;
+            case 'array':
+                return haystack.some(function (item) { return deep ? deepEqual(item, needle) : item === needle; });
+            case 'object':
+                if (type(needle) !== 'object') return false;
+                return Object.keys(needle).every(function (k) {
+                    return deep ? deepEqual(haystack[k], needle[k]) : haystack[k] === needle[k];
+                });
+            default:
+                return false;
+        }
+    }
+
+    function include(value) {
+        var obj = flag(this, 'object');
+        var deep = flag(this, 'deep');
+        this.assert(includes(obj, value, deep), 'expected #{this} to ' + (deep ? 'deep ' : '') + 'include #{exp}',
+            'expected #{this} to not ' + (deep ? 'deep ' : '') + 'include #{exp}', value);
+    }
+    ['include', 'includes', 'contain', 'contains'].forEach(function (name) {
+        addChainableMethod(name, include, function () { flag(this, 'contains', true); });
+    });
+
+    function nestedValue(obj, path) {
+        var parts = String(path).replace(/\[(\d+)\]/g, '.$1').split('.');
+        var current = obj;
+        for (var i = 0; i < parts.length; i++) {
+            if (current === null || current === undefined || !(parts[i] in Object(current))) {
+                return { exists: false };
+            }
+            current = current[parts[i]];
+        }
+        return { exists: true, value: current };
+    }
+
+    function property(name, value) {
+        var obj = flag(this, 'object');
+        var found;
+        if (flag(this, 'nested')) {
+            found = nestedValue(obj, name);
+        } else if (flag(this, 'own')) {
+            found = { exists: obj !== null && obj !== undefined && Object.prototype.hasOwnProperty.call(obj, name) };
+            found.value = found.exists ? obj[name] : undefined;
+        } else {
+            found = { exists: obj !== null && obj !== undefined && name in Object(obj) };
+            found.value = found.exists ? obj[name] : undefined;
+        }
+
+        var description = (flag(this, 'nested') ? 'nested ' : '') + (flag(this, 'own') ? 'own ' : '') + 'property ';
+        if (arguments.length > 1) {
+            var matches = found.exists && (flag(this, 'deep') ? deepEqual(found.value, value) : found.value === value);
+            this.assert(matches, 'expected #{this} to have ' + description + inspect(name) + ' of #{exp}, but got #{act}',
+                'expected #{this} to not have ' + description + inspect(name) + ' of #{act}', value, found.value);
+        } else {
+            this.assert(found.exists, 'expected #{this} to have ' + description + inspect(name),
+                'expected #{this} to not have ' + description + inspect(name));
+        }
+        flag(this, 'object', found.value);
+    }
+    addMethod('property', property);
+    ['ownProperty', 'haveOwnProperty'].forEach(function (name) {
+        addMethod(name, function () {
+            flag(this, 'own', true);
+            return property.apply(this, arguments);
+        });
+    });
+    addMethod('nestedProperty', function () {
+        flag(this, 'nested', true);
+        return property.apply(this, arguments);
+    });
+
+    function lengthOf(n) {
+        var len = flag(this, 'object').length;
+        this.assert(len === n, 'expected #{this} to have a length of #{exp} but got #{act}',
+            'expected #{this} to not have a length of #{act}', n, len);
+    }
+    addMethod('lengthOf', lengthOf);
+    addChainableMethod('length', lengthOf, function () { flag(this, 'doLength', true); });
+
+    ['match', 'matches'].forEach(function (name) {
+        addMethod(name, function (re) {
+            this.assert(re.exec(flag(this, 'object')) !== null, 'expected #{this} to match ' + re,
+                'expected #{this} not to match ' + re);
+        });
+    });
+
+    addMethod('string', function (str) {
+        var obj = flag(this, 'object');
+        this.assert(typeof obj === 'string' && obj.indexOf(str) !== -1, 'expected #{this} to contain #{exp}',
+            'expected #{this} to not contain #{exp}', str);
+    });
+
+    function keys() {
+        var obj = flag(this, 'object');
+        var expected = Array.prototype.slice.call(arguments);
+        if (expected.length === 1 && Array.isArray(expected[0])) {
+            expected = expected[0];
+        } else if (expected.length === 1 && type(expected[0]) === 'object') {
+            expected = Object.keys(expected[0]);
+        }
+        var actual = Object.keys(obj || {});
+
+        var ok;
+        if (flag(this, 'any')) {
+            ok = expected.some(function (k) { return actual.indexOf(k) !== -1; });
+        } else {
+            ok = expected.every(function (k) { return actual.indexOf(k) !== -1; });
+            if (!flag(this, 'contains')) ok = ok && actual.length === expected.length;
+        }
+
+        var words = (flag(this, 'any') ? 'any of ' : 'all of ') + 'keys ' + expected.map(inspect).join(', ');
+        this.assert(ok, 'expected #{this} to have ' + words, 'expected #{this} to not have ' + words);
+    }
+    addMethod('keys', keys);
+    addMethod('key', keys);
+
+    addMethod('members', function (set) {
+        var obj = flag(this, 'object');
+        var deep = flag(this, 'deep');
+        var contained = set.every(function (item) { return includes(obj, item, deep); });
+        var ok = flag(this, 'contains') ? contained : contained && obj.length === set.length &&
+            obj.every(function (item) { return includes(set, item, deep); });
+        if (ok && flag(this, 'ordered')) {
+            ok = set.every(function (item, i) { return deep ? deepEqual(obj[i], item) : obj[i] === item; });
+        }
+        var words = flag(this, 'contains') ? 'be a superset of' : 'have the same members as';
+        this.assert(ok, 'expected #{this} to ' + words + ' #{exp}', 'expected #{this} to not ' + words + ' #{exp}', set);
+    });
+
+    addMethod('oneOf', function (list) {
+        var obj = flag(this, 'object');
+        this.assert(includes(list, obj, flag(this, 'deep')), 'expected #{this} to be one of #{exp}',
+            'expected #{this} to not be one of #{exp}', list);
+    });
+
+    ['satisfy', 'satisfies'].forEach(function (name) {
+        addMethod(name, function (matcher) {
+            this.assert(matcher(flag(this, 'object')), 'expected #{this} to satisfy ' + inspect(matcher),
+                'expected #{this} to not satisfy ' + inspect(matcher));
+        });
+    });
+
+    ['throw', 'throws', 'Throw'].forEach(function (name) {
+        addMethod(name, function (expected) {
+            var thrown = null;
+            try {
+                flag(this, 'object')();
+            } catch (e) {
+                thrown = e;
+            }
+            var ok = thrown !== null;
+            if (ok && expected !== undefined) {
+                var message = thrown && thrown.message !== undefined ? thrown.message : String(thrown);
+                if (typeof expected === 'string') {
+                    ok = message.indexOf(expected) !== -1;
+                } else if (expected instanceof RegExp) {
+                    ok = expected.test(message);
+                } else if (typeof expected === 'function') {
+                    ok = thrown instanceof expected;
+                }
+            }
+            this.assert(ok, 'expected #{this} to throw' + (expected !== undefined ? ' ' + inspect(expected) : ''),
+                'expected #{this} to not throw' + (expected !== undefined ? ' ' + inspect(expected) : ''));
+        });
+    });
+
+    // Response assertions used as pm.response.to.have.<name>(...)
+
+    addMethod('status', function (expected) {
+        var obj = flag(this, 'object');
+        if (typeof expected === 'string') {
+            this.assert(obj.status === expected, "expected response to have status reason '" + expected + "' but got '" + obj.status + "'",
+                "expected response to not have status reason '" + expected + "'");
+            return;
+        }
+        this.assert(obj.code === expected, 'expected response to have status code ' + expected + ' but got ' + obj.code,
+            'expected response to not have status code ' + expected);
+    });
+
+    addMethod('header', function (name, value) {
+        var headers = flag(this, 'object').headers;
+        if (arguments.length < 2) {
+            this.assert(headers.has(name), "expected response to have header with key '" + name + "'",
+                "expected response to not have header with key '" + name + "'");
+            return;
+        }
+        var actual = headers.get(name);
+        this.assert(actual === value, "expected '" + name + "' response header to be '" + value + "' but got '" + actual + "'",
+            "expected '" + name + "' response header to not be '" + value + "'");
+    });
+
+    addMethod('body', function (expected) {
+        var text = flag(this, 'object').text();
+        if (arguments.length === 0) {
+            this.assert(text.length > 0, 'expected response to have content in body', 'expected response to not have content in body');
+            return;
+        }
+        if (expected instanceof RegExp) {
+            this.assert(expected.test(text), 'expected response body text to match ' + expected,
+                'expected response body text to not match ' + expected);
+            return;
+        }
+        if (typeof expected === 'object') {
+            this.assert(deepEqual(JSON.parse(text), expected), 'expected response body json to equal ' + inspect(expected),
+                'expected response body json to not equal ' + inspect(expected));
+            return;
+        }
+        this.assert(text === expected, "expected response body to equal '" + expected + "' but got '" + text + "'",
+            "expected response body to not equal '" + expected + "'");
+    });
+
+    addMethod('jsonBody', function (path, value) {
+        var body;
+        try {
+            body = flag(this, 'object').json();
+        } catch (e) {
+            this.assert(false, 'expected response body to be a valid json', 'expected response body not to be a valid json');
+            return;
+        }
+        if (arguments.length === 0) {
+            this.assert(true, '', 'expected response body not to be a valid json');
+            return;
+        }
+        if (typeof path === 'object') {
+            this.assert(deepEqual(body, path), 'expected response body json to equal ' + inspect(path),
+                'expected response body json to not equal ' + inspect(path));
+            return;
+        }
+        var found = nestedValue(body, path);
+        if (arguments.length === 1) {
+            this.assert(found.exists, "expected response body json to contain path '" + path + "'",
+                "expected response body json to not contain path '" + path + "'");
+            return;
+        }
+        this.assert(found.exists && deepEqual(found.value, value),
+            "expected response body json at '" + path + "' to contain " + inspect(value) + ' but got ' + inspect(found.value),
+            "expected response body json at '" + path + "' to not contain " + inspect(value));
+    });
+
+    function expect(value, message) {
+        return new Assertion(value, message);
+    }
+    expect.fail = function (message) {
+        throw new AssertionError(message || 'expect.fail()');
+    };
+
+    global.__chai = { expect: expect, Assertion: Assertion, AssertionError: AssertionError, inspect: inspect };
+})(this);
@@ -0,0 +1,374 @@
+// The pm object and the legacy globals of the Postman sandbox, built on top of
+// the __host functions, the __request/__response snapshots and __info that
+// the Go side defines before each script.
+(function (global, host) {
+    'use strict';
+
+    var expect = global.__chai.expect;
+    var inspect = global.__chai.inspect;
+
+    // PropertyList mirrors the Postman SDK list used for headers and query
+    // parameters. Header keys compare case-insensitively.
+    function PropertyList(items, caseInsensitive) {
+        this._items = [];
+        this._ci = !!caseInsensitive;
+        var self = this;
+        (items || []).forEach(function (item) { self.add(item); });
+    }
+
+    PropertyList.prototype._same = function (a, b) {
+        a = String(a);
+        b = String(b);
+        return this._ci ? a.toLowerCase() === b.toLowerCase() : a === b;
+    };
+    PropertyList.prototype._normalize = function (item) {
+        if (typeof item === 'string') {
+            var idx = item.indexOf(':');
+            return idx === -1 ? { key: item.trim(), value: '' } : { key: item.slice(0, idx).trim(), value: item.slice(idx + 1).trim() };
+        }
+        var normalized = { key: item.key, value: item.value };
+        if (item.disabled) normalized.disabled = true;
+        if (item.type) normalized.type = item.type;
+        if (item.src) normalized.src = item.src;
+        return normalized;
+    };
+    PropertyList.prototype.all = function () { return this._items.slice(); };
+    PropertyList.prototype.count = function () { return this._items.length; };
+    PropertyList.prototype.idx = function (i) { return this._items[i]; };
+    PropertyList.prototype.one = function (key) {
+        for (var i = this._items.length - 1; i >= 0; i--) {
+            if (this._same(this._items[i].key, key)) return this._items[i];
+        }
+        return undefined;
+    };
+    PropertyList.prototype.get = function (key) {
+        for (var i = this._items.length - 1; i >= 0; i--) {
+            var item = this._items[i];
+            if (!item.disabled && this._same(item.key, key)) return item.value;
+        }
+        return undefined;
+    };
+    PropertyList.prototype.has = function (key, value) {
+        var self = this;
+        return this._items.some(function (item) {
+            return !item.disabled && self._same(item.key, key) && (value === undefined || item.value === value);
+        });
+    };
+    PropertyList.prototype.add = function (item) { this._items.push(this._normalize(item)); };
+    PropertyList.prototype.append = PropertyList.prototype.add;
+    PropertyList.prototype.upsert = function (item) {
+        item = this._normalize(item);
+        var existing = this.one(item.key);
+        if (existing) {
+            existing.value = item.value;
+            existing.disabled = item.disabled;
+            return;
+        }
+        this._items.push(item);
+    };
+    PropertyList.prototype.remove = function (predicate) {
+        var self = this;
+        var matches = typeof predicate === 'function' ? predicate : function (item) {
+            return self._same(item.key, typeof predicate === 'object' ? predicate.key : predicate);
+        };
+        this._items = this._items.filter(function (item) { return !matches(item); });
+    };
+    PropertyList.prototype.clear = function () { this._items = []; };
+    PropertyList.prototype.each = function (fn) { this._items.forEach(fn); };
+    PropertyList.prototype.map = function (fn) { return this._items.map(fn); };
+    PropertyList.prototype.filter = function (fn) { return this._items.filter(fn); };
+    PropertyList.prototype.find = function (fn) { return this._items.find(fn); };
+    PropertyList.prototype.toObject = function () {
+        var obj = {};
+        this._items.forEach(function (item) {
+            if (!item.disabled) obj[item.key] = item.value;
+        });
+        return obj;
+    };
+    PropertyList.prototype.toJSON = function () { return this.all(); };
+
+    function QueryList(items) {
+        PropertyList.call(this, items, false);
+    }
+    QueryList.prototype = Object.create(PropertyList.prototype);
+    QueryList.prototype.toString = function () {
+        return this._items.filter(function (item) { return !item.disabled; }).map(function (item) {
+            return item.value === null || item.value === undefined ? item.key : item.key + '=' + item.value;
+        }).join('&');
+    };
+
+    function parseQuery(query) {
+        if (!query) return [];
+        return query.split('&').map(function (pair) {
+            var idx = pair.indexOf('=');
+            return idx === -1 ? { key: pair, value: null } : { key: pair.slice(0, idx), value: pair.slice(idx + 1) };
+        });
+    }
+
+    // Url keeps a request URL in the parts Postman exposes. toString puts
+    // them back together, so an untouched URL round-trips unchanged.
+    function Url(raw) {
+        this.update(raw);
+    }
+    Url.prototype.update = function (raw) {
+        raw = String(raw === undefined || raw === null ? '' : raw);
+        var hashIdx = raw.indexOf('#');
+        this.hash = hashIdx === -1 ? undefined : raw.slice(hashIdx + 1);
+        if (hashIdx !== -1) raw = raw.slice(0, hashIdx);
+
+        var queryIdx = raw.indexOf('?');
+        this.query = new QueryList(parseQuery(queryIdx === -1 ? '' : raw.slice(queryIdx + 1)));
+        if (queryIdx !== -1) raw = raw.slice(0, queryIdx);
+
+        var protocol = /^([A-Za-z][A-Za-z0-9+.-]*):\/\//.exec(raw);
+        this.protocol = protocol ? protocol[1] : undefined;
+        if (protocol) raw = raw.slice(protocol[0].length);
+
+        var slash = raw.indexOf('/');
+        var hostPort = slash === -1 ? raw : raw.slice(0, slash);
+        var port = /:(\d+|\{\{[^}]+\}\})$/.exec(hostPort);
+        this.port = port ? port[1] : undefined;
+        if (port) hostPort = hostPort.slice(0, port.index);
+        this.host = hostPort ? hostPort.split('.') : [];
+        this.path = slash === -1 ? [] : raw.slice(slash + 1).split('/');
+    };
+    Url.prototype.getHost = function () { return this.host.join('.'); };
+    Url.prototype.getRemote = function () { return this.getHost() + (this.port ? ':' + this.port : ''); };
+    Url.prototype.getPath = function () { return '/' + this.path.join('/'); };
+    Url.prototype.getQueryString = function () { return this.query.toString(); };
+    Url.prototype.getPathWithQuery = function () {
+        var query = this.getQueryString();
+        return this.getPath() + (query ? '?' + query : '');
+    };
+    Url.prototype.addQueryParams = function (params) {
+        var self = this;
+        (typeof params === 'string' ? parseQuery(params) : [].concat(params)).forEach(function (p) { self.query.add(p); });
+    };
+    Url.prototype.removeQueryParams = function (keys) {
+        var self = this;
+        [].concat(keys).forEach(function (key) { self.query.remove(key); });
+    };
+    Url.prototype.toString = function () {
+        var s = this.protocol ? this.protocol + '://' : '';
+        s += this.getHost();
+        if (this.port) s += ':' + this.port;
+        if (this.path.length) s += '/' + this.path.join('/');
+        var query = this.getQueryString();
+        if (query) s += '?' + query;
+        if (this.hash !== undefined) s += '#' + this.hash;
+        return s;
+    };
+    Url.prototype.toJSON = function () { return this.toString(); };
+
+    function RequestBody(body) {
+        body = body || {};
+        this.mode = body.mode;
+        this.raw = body.raw;
+        this.urlencoded = new PropertyList(body.urlencoded);
+        this.formdata = new PropertyList(body.formdata);
+    }
+    RequestBody.prototype.update = function (value) {
+        if (typeof value === 'string') {
+            this.mode = 'raw';
+            this.raw = value;
+            return;
+        }
+        if (value && value.mode) {
+            this.mode = value.mode;
+            if (value.raw !== undefined) this.raw = value.raw;
+            if (value.urlencoded) this.urlencoded = new PropertyList(value.urlencoded);
+            if (value.formdata) this.formdata = new PropertyList(value.formdata);
+        }
+    };
+    RequestBody.prototype.isEmpty = function () {
+        switch (this.mode) {
+            case 'urlencoded':
+                return this.urlencoded.count() === 0;
+            case 'formdata':
+                return this.formdata.count() === 0;
+            default:
+                return !this.raw;
+        }
+    };
+    RequestBody.prototype.toString = function () { return this.raw || ''; };
+
+    var snapshot = global.__request;
+    var url = new Url(snapshot.url);
+    var request = {
+        id: snapshot.id,
+        name: snapshot.name,
+        method: snapshot.method,
+        headers: new PropertyList(snapshot.headers, true),
+        body: new RequestBody(snapshot.body),
+        addHeader: function (header) { this.headers.add(header); },
+        removeHeader: function (key) { this.headers.remove(key); },
+        upsertHeader: function (header) { this.headers.upsert(header); },
+        getHeaders: function () { return this.headers.toObject(); },
+        addQueryParams: function (params) { url.addQueryParams(params); },
+        removeQueryParams: function (keys) { url.removeQueryParams(keys); }
+    };
+    Object.defineProperty(request, 'url', {
+        get: function () { return url; },
+        set: function (value) { url = value instanceof Url ? value : new Url(String(value)); },
+        enumerable: true
+    });
+
+    function exportParams(list) {
+        return list.all().map(function (p) {
+            var out = { key: String(p.key), value: p.value === undefined || p.value === null ? '' : String(p.value) };
+            if (p.disabled) out.disabled = true;
+            if (p.type) out.type = String(p.type);
+            return out;
+        });
+    }
+
+    function exportRequest() {
+        var body = request.body || new RequestBody();
+        return {
+            method: String(request.method || 'GET').toUpperCase(),
+            url: url.toString(),
+            headers: exportParams(request.headers),
+            body: {
+                mode: body.mode || '',
+                raw: body.raw === undefined || body.raw === null ? '' : String(body.raw),
+                urlencoded: body.urlencoded ? exportParams(body.urlencoded) : [],
+                formdata: body.formdata ? exportParams(body.formdata) : []
+            }
+        };
+    }
+
+    var response;
+    if (global.__response) {
+        var res = global.__response;
+        var parsed;
+        response = {
+            __response: true,
+            code: res.code,
+            status: res.status,
+            responseTime: res.responseTime,
+            responseSize: res.responseSize,
+            headers: new PropertyList(res.headers, true),
+            text: function () { return res.body; },
+            json: function () {
+                if (parsed === undefined) parsed = JSON.parse(res.body);
+                return parsed;
+            },
+            reason: function () { return res.status; }
+        };
+        Object.defineProperty(response, 'to', {
+            get: function () { return expect(response).to; }
+        });
+    }
+
+    function scope(name) {
+        return {
+            get: function (key) { return host.get(name, key); },
+            set: function (key, value) { host.set(name, key, value); },
+            has: function (key) { return host.has(name, key); },
+            unset: function (key) { host.unset(name, key); },
+            clear: function () { host.clear(name); },
+            toObject: function () { return host.toObject(name); },
+            toJSON: function () { return host.toObject(name); },
+            replaceIn: function (template) { return host.replaceIn(String(template)); }
+        };
+    }
+
+    var results = [];
+
+    function test(name, fn) {
+        if (typeof fn !== 'function') {
+            results.push({ name: String(name), skipped: true });
+            return test;
+        }
+        try {
+            fn();
+            results.push({ name: String(name), passed: true });
+        } catch (e) {
+            var message = e && e.message !== undefined ? e.message : String(e);
+            results.push({ name: String(name), passed: false, error: (e && e.name ? e.name + ': ' : '') + message });
+        }
+        return test;
+    }
+    test.skip = function (name) {
+        results.push({ name: String(name), skipped: true });
+        return test;
+    };
+
+    var info = global.__info;
+    var environment = scope('environment');
+    environment.name = info.environmentName;
+
+    global.pm = {
+        info: {
+            eventName: info.eventName,
+            iteration: info.iteration,
+            iterationCount: info.iterationCount,
+            requestName: info.requestName,
+            requestId: info.requestId
+        },
+        variables: scope('variables'),
+        environment: environment,
+        collectionVariables: scope('collectionVariables'),
+        globals: scope('globals'),
+        iterationData: scope('iterationData'),
+        request: request,
+        response: response,
+        test: test,
+        expect: expect,
+        sendRequest: function () {
+            throw new Error('pm.sendRequest is not supported by the native engine');
+        }
+    };
+
+    function formatArgs(args) {
+        return Array.prototype.map.call(args, function (arg) {
+            if (typeof arg === 'string') return arg;
+            if (arg !== null && typeof arg === 'object' && !(arg instanceof Error)) {
+                try {
+                    return JSON.stringify(arg);
+                } catch (e) {
+                    return inspect(arg);
+                }
+            }
+            return inspect(arg);
+        }).join(' ');
+    }
+
+    global.console = {};
+    ['log', 'info', 'warn', 'error', 'debug'].forEach(function (level) {
+        global.console[level] = function () { host.log(level, formatArgs(arguments)); };
+    });
+
+    global.require = function (name) {
+        throw new Error("require('" + name + "') is not supported by the native engine");
+    };
+
+    // Legacy sandbox API (pre pm.*), still common in older collections
+    global.tests = {};
+    global.data = host.toObject('iterationData');
+    global.environment = host.toObject('environment');
+    global.globals = host.toObject('globals');
+    global.iteration = info.iteration;
+    global.postman = {
+        getEnvironmentVariable: function (key) { return host.get('environment', key); },
+        setEnvironmentVariable: function (key, value) { host.set('environment', key, value); },
+        clearEnvironmentVariable: function (key) { host.unset('environment', key); },
+        getGlobalVariable: function (key) { return host.get('globals', key); },
+        setGlobalVariable: function (key, value) { host.set('globals', key, value); },
+        clearGlobalVariable: function (key) { host.unset('globals', key); }
+    };
+    if (response) {
+        global.responseBody = response.text();
+        global.responseCode = { code: response.code, name: response.status };
+        global.responseTime = response.responseTime;
+        global.responseHeaders = response.headers.toObject();
+    }
+
+    global.__finish = function () {
+        Object.keys(global.tests).forEach(function (name) {
+            var passed = !!global.tests[name];
+            results.push({ name: name, passed: passed, error: passed ? '' : 'AssertionError: expected ' + inspect(global.tests[name]) + ' to be truthy' });
+        });
+        return JSON.stringify({ results: results, request: exportRequest() });
+    };
+})(this, this.__host);
@@ -0,0 +1,441 @@
+// Package sandbox runs Postman prerequest and test scripts in an embedded
+// JavaScript runtime, so the native engine can execute the same scripts that
+// scriptsync extracts without Node.
+//
+// Scripts see the pm.* API (variables, environment, request, response, test
+// and Chai style expect) and the older postman.* and tests[] globals.
+package sandbox
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"github.com/ssd532/plaintest/internal/collection"
+	"github.com/ssd532/plaintest/internal/native"
+)
+
+//go:embed js/expect.js js/pm.js
+var jsFS embed.FS
+
+// DefaultTimeout bounds how long a single script may run.
+const DefaultTimeout = 30 * time.Second
+
+var (
+	compileOnce sync.Once
+	expectJS    *goja.Program
+	compileErr  error
+)
+
+// compiled returns the Chai-compatible expect library, compiled once.
+func compiled() (*goja.Program, error) {
+	compileOnce.Do(func() {
+		src, err := jsFS.ReadFile("js/expect.js")
+		if err != nil {
+			compileErr = err
+			return
+		}
+		expectJS, compileErr = goja.Compile("expect.js", string(src), true)
+	})
+	return expectJS, compileErr
+}
+
+// Engine runs scripts. Every script gets a fresh runtime, so scripts cannot
+// leak state into each other except through pm variables.
+type Engine struct {
+	timeout time.Duration
+}
+
+// New returns an engine with DefaultTimeout.
+func New() *Engine {
+	return &Engine{timeout: DefaultTimeout}
+}
+
+// SetTimeout changes how long a single script may run.
+func (e *Engine) SetTimeout(timeout time.Duration) {
+	e.timeout = timeout
+}
+
+// finishState is what pm.js reports back once a script has run.
+type finishState struct {
+	Results []struct {
+		Name    string `json:"name"`
+		Passed  bool   `json:"passed"`
+		Skipped bool   `json:"skipped"`
+		Error   string `json:"error"`
+	} `json:"results"`
+	Request requestState `json:"request"`
+}
+
+// Run executes source with the pm API bound to ctx. Changes a prerequest
+// script makes to pm.request are written back to ctx.Request.
+func (e *Engine) Run(source string, ctx *native.ScriptContext) ([]native.TestResult, error) {
+	expect, err := compiled()
+	if err != nil {
+		return nil, fmt.Errorf("loading expect library: %w", err)
+	}
+	pmSource, err := jsFS.ReadFile("js/pm.js")
+	if err != nil {
+		return nil, err
+	}
+
+	vm := goja.New()
+	before := snapshotRequest(ctx.Request)
+	if err := e.bind(vm, ctx, before); err != nil {
+		return nil, err
+	}
+	if _, err := vm.RunProgram(expect); err != nil {
+		return nil, fmt.Errorf("loading expect library: %w", err)
+	}
+	if _, err := vm.RunScript("pm.js", string(pmSource)); err != nil {
+		return nil, fmt.Errorf("loading pm API: %w", err)
+	}
+
+	timer := time.AfterFunc(e.timeout, func() {
+		vm.Interrupt(fmt.Sprintf("script timed out after %s", e.timeout))
+	})
+	// Wrapping keeps line numbers intact and allows a top-level return
+	_, runErr := vm.RunScript(ctx.Listen+"-script", "(function () {"+source+"\n}).call(this);")
+	timer.Stop()
+	vm.ClearInterrupt()
+
+	state, err := finish(vm)
+	if err != nil {
+		return nil, err
+	}
+	applyRequest(ctx.Request, before, state.Request)
+
+	results := make([]native.TestResult, 0, len(state.Results))
+	for _, r := range state.Results {
+		results = append(results, native.TestResult{Name: r.Name, Passed: r.Passed, Skipped: r.Skipped, Error: r.Error})
+	}
+	return results, scriptError(runErr)
+}
+
+// bind defines the globals pm.js builds the sandbox from.
+func (e *Engine) bind(vm *goja.Runtime, ctx *native.ScriptContext, request requestState) error {
+	h := &host{vm: vm, ctx: ctx}
+	if err := vm.Set("__host", h.object()); err != nil {
+		return err
+	}
+
+	request.ID = ""
+	request.Name = ctx.ItemName
+	if err := setJSON(vm, "__request", request); err != nil {
+		return err
+	}
+
+	var response any
+	if resp := ctx.Response; resp != nil {
+		var headers []map[string]string
+		for key, values := range resp.Header {
+			for _, value := range values {
+				headers = append(headers, map[string]string{"key": key, "value": value})
+			}
+		}
+		response = map[string]any{
+			"code":         resp.Code,
+			"status":       resp.Status,
+			"responseTime": resp.Time.Milliseconds(),
+			"responseSize": len(resp.Body),
+			"headers":      headers,
+			"body":         string(resp.Body),
+		}
+	}
+	if err := setJSON(vm, "__response", response); err != nil {
+		return err
+	}
+
+	return setJSON(vm, "__info", map[string]any{
+		"eventName":       ctx.Listen,
+		"iteration":       ctx.Iteration,
+		"iterationCount":  ctx.IterationCount,
+		"requestName":     ctx.ItemName,
+		"environmentName": ctx.EnvironmentName,
+	})
+}
+
+// setJSON defines name as the JavaScript value of v, built with JSON.parse
+// so scripts see plain arrays and objects rather than wrapped Go values.
+func setJSON(vm *goja.Runtime, name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	parse, ok := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("parse"))
+	if !ok {
+		return errors.New("JSON.parse is not available")
+	}
+	value, err := parse(goja.Undefined(), vm.ToValue(string(data)))
+	if err != nil {
+		return err
+	}
+	return vm.Set(name, value)
+}
+
+func finish(vm *goja.Runtime) (*finishState, error) {
+	fn, ok := goja.AssertFunction(vm.Get("__finish"))
+	if !ok {
+		return nil, errors.New("sandbox did not initialise")
+	}
+	value, err := fn(goja.Undefined())
+	if err != nil {
+		return nil, fmt.Errorf("collecting script results: %w", err)
+	}
+	var state finishState
+	if err := json.Unmarshal([]byte(value.String()), &state); err != nil {
+		return nil, fmt.Errorf("collecting script results: %w", err)
+	}
+	return &state, nil
+}
+
+// scriptError turns a JavaScript exception into a one-line error.
+func scriptError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		return fmt.Errorf("%v", interrupted.Value())
+	}
+	var exception *goja.Exception
+	if errors.As(err, &exception) {
+		return errors.New(exception.Error())
+	}
+	return err
+}
+
+// host implements the variable and console functions pm.js calls.
+type host struct {
+	vm  *goja.Runtime
+	ctx *native.ScriptContext
+}
+
+func (h *host) object() map[string]any {
+	return map[string]any{
+		"get":       h.get,
+		"set":       h.set,
+		"has":       h.has,
+		"unset":     h.unset,
+		"clear":     h.clear,
+		"toObject":  h.toObject,
+		"replaceIn": h.ctx.Variables.Replace,
+		"log":       h.log,
+	}
+}
+
+// scope returns the variable scope pm.js refers to by name. "variables"
+// writes to the local scope and reads through all scopes.
+func (h *host) scope(name string) *native.Scope {
+	vars := h.ctx.Variables
+	switch name {
+	case "environment":
+		return vars.Environment
+	case "collectionVariables":
+		return vars.Collection
+	case "globals":
+		return vars.Globals
+	case "iterationData":
+		return vars.Data
+	default:
+		return vars.Local
+	}
+}
+
+func (h *host) get(scope, key string) goja.Value {
+	var value any
+	var ok bool
+	if scope == "variables" {
+		value, ok = h.ctx.Variables.Get(key)
+	} else {
+		value, ok = h.scope(scope).Get(key)
+	}
+	if !ok {
+		return goja.Undefined()
+	}
+	return h.vm.ToValue(jsValue(value))
+}
+
+func (h *host) set(scope, key string, value goja.Value) {
+	var v any
+	if value != nil && !goja.IsUndefined(value) {
+		v = value.Export()
+	}
+	h.scope(scope).Set(key, v)
+}
+
+func (h *host) has(scope, key string) bool {
+	if scope == "variables" {
+		_, ok := h.ctx.Variables.Get(key)
+		return ok
+	}
+	return h.scope(scope).Has(key)
+}
+
+func (h *host) unset(scope, key string) {
+	h.scope(scope).Unset(key)
+}
+
+func (h *host) clear(scope string) {
+	h.scope(scope).Clear()
+}
+
+func (h *host) toObject(scope string) goja.Value {
+	merged := make(map[string]any)
+	if scope == "variables" {
+		vars := h.ctx.Variables
+		for _, s := range []*native.Scope{vars.Globals, vars.Collection, vars.Environment, vars.Data, vars.Local} {
+			for key, value := range s.ToMap() {
+				merged[key] = jsValue(value)
+			}
+		}
+	} else {
+		for key, value := range h.scope(scope).ToMap() {
+			merged[key] = jsValue(value)
+		}
+	}
+	return h.vm.ToValue(merged)
+}
+
+func (h *host) log(level, message string) {
+	if h.ctx.Console == nil {
+		return
+	}
+	if level == "warn" || level == "error" {
+		message = level + ": " + message
+	}
+	_, _ = io.WriteString(h.ctx.Console, message+"\n")
+}
+
+// jsValue converts numbers read from JSON files into JavaScript numbers.
+func jsValue(v any) any {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return i
+	}
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+	return string(n)
+}
+
+// requestState is the part of a request scripts can read and change.
+type requestState struct {
+	ID      string      `json:"id,omitempty"`
+	Name    string      `json:"name,omitempty"`
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers []paramJSON `json:"headers"`
+	Body    bodyState   `json:"body"`
+}
+
+type bodyState struct {
+	Mode       string      `json:"mode"`
+	Raw        string      `json:"raw"`
+	URLEncoded []paramJSON `json:"urlencoded"`
+	FormData   []paramJSON `json:"formdata"`
+}
+
+type paramJSON struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled,omitempty"`
+	Type     string `json:"type,omitempty"`
+}
+
+func snapshotRequest(req *collection.Request) requestState {
+	state := requestState{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Headers: []paramJSON{},
+		Body:    bodyState{URLEncoded: []paramJSON{}, FormData: []paramJSON{}},
+	}
+	if state.Method == "" {
+		state.Method = "GET"
+	}
+	for _, h := range req.Header {
+		state.Headers = append(state.Headers, paramJSON{Key: h.Key, Value: h.Value, Disabled: h.Disabled})
+	}
+	if b := req.Body; b != nil {
+		state.Body.Mode = b.Mode
+		state.Body.Raw = b.Raw
+		state.Body.URLEncoded = params(b.URLEncoded)
+		state.Body.FormData = params(b.FormData)
+	}
+	return state
+}
+
+func params(list []collection.Param) []paramJSON {
+	out := make([]paramJSON, 0, len(list))
+	for _, p := range list {
+		out = append(out, paramJSON{Key: p.Key, Value: p.Value, Disabled: p.Disabled, Type: p.Type})
+	}
+	return out
+}
+
+// applyRequest copies the parts of after that differ from before into req,
+// leaving everything a script did not touch as it was.
+func applyRequest(req *collection.Request, before, after requestState) {
+	if after.Method != "" && after.Method != before.Method {
+		req.Method = after.Method
+	}
+
+	if after.URL != before.URL {
+		if req.URL == nil {
+			req.URL = &collection.URL{}
+		}
+		req.URL.Raw = after.URL
+	}
+
+	if !sameParams(before.Headers, after.Headers) {
+		req.Header = make([]collection.Header, 0, len(after.Headers))
+		for _, h := range after.Headers {
+			req.Header = append(req.Header, collection.Header{Key: h.Key, Value: h.Value, Disabled: h.Disabled})
+		}
+	}
+
+	b, a := before.Body, after.Body
+	if a.Mode == b.Mode && a.Raw == b.Raw && sameParams(a.URLEncoded, b.URLEncoded) && sameParams(a.FormData, b.FormData) {
+		return
+	}
+	if req.Body == nil {
+		req.Body = &collection.Body{}
+	}
+	req.Body.Mode = a.Mode
+	if a.Mode == "" && a.Raw != "" {
+		req.Body.Mode = "raw"
+	}
+	req.Body.Raw = a.Raw
+	req.Body.URLEncoded = toParams(a.URLEncoded)
+	req.Body.FormData = toParams(a.FormData)
+}
+
+func sameParams(a, b []paramJSON) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func toParams(list []paramJSON) []collection.Param {
+	out := make([]collection.Param, 0, len(list))
+	for _, p := range list {
+		out = append(out, collection.Param{Key: p.Key, Value: p.Value, Disabled: p.Disabled, Type: p.Type})
+	}
+	return out
+}
@@ -0,0 +1,240 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ssd532/plaintest/internal/collection"
+	"github.com/ssd532/plaintest/internal/native"
+)
+
+func newContext(listen string) *native.ScriptContext {
+	return &native.ScriptContext{
+		Listen:   listen,
+		ItemName: "Get User",
+		Request: &collection.Request{
+			Method: "GET",
+			URL:    &collection.URL{Raw: "{{base_url}}/users/1"},
+			Header: []collection.Header{{Key: "Accept", Value: "application/json"}},
+		},
+		Variables:      native.NewVariables(),
+		IterationCount: 1,
+	}
+}
+
+func TestEngine_Run_Tests(t *testing.T) {
+	ctx := newContext("test")
+	ctx.Response = &native.Response{
+		Code:   200,
+		Status: "OK",
+		Header: http.Header{"Content-Type": {"application/json"}},
+		Body:   []byte(`{"id": 1, "username": "emilys", "roles": ["admin"]}`),
+		Time:   12 * time.Millisecond,
+	}
+
+	results, err := New().Run(`
+		pm.test('status', function () { pm.response.to.have.status(200); });
+		pm.test('json', function () {
+			var body = pm.response.json();
+			pm.expect(body).to.have.property('username', 'emilys');
+			pm.expect(body.roles).to.include('admin');
+			pm.expect(pm.response).to.have.header('content-type');
+		});
+		pm.test('wrong status', function () { pm.response.to.have.status(404); });
+		pm.test('wrong value', function () { pm.expect(pm.response.json().id).to.eql(2); });
+		pm.test.skip('later', function () {});
+		tests['legacy'] = responseCode.code === 200;
+	`, ctx)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := []native.TestResult{
+		{Name: "status", Passed: true},
+		{Name: "json", Passed: true},
+		{Name: "wrong status", Error: "AssertionError: expected response to have status code 404 but got 200"},
+		{Name: "wrong value", Error: "AssertionError: expected 1 to deeply equal 2"},
+		{Name: "later", Skipped: true},
+		{Name: "legacy", Passed: true},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("Run() = %+v, want %+v", results, want)
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, results[i], want[i])
+		}
+	}
+}
+
+func TestEngine_Run_Variables(t *testing.T) {
+	ctx := newContext("prerequest")
+	var console bytes.Buffer
+	ctx.Console = &console
+	ctx.Variables.Environment.Set("base_url", "http://localhost")
+	ctx.Variables.Environment.Set("count", json.Number("3"))
+	ctx.Variables.Data.Set("user", "emilys")
+
+	_, err := New().Run(`
+		pm.environment.set('token', 'abc');
+		pm.collectionVariables.set('count', pm.environment.get('count') + 1);
+		pm.globals.set('seen', true);
+		pm.variables.set('local', pm.iterationData.get('user'));
+		pm.environment.unset('base_url');
+		console.log('user', pm.variables.get('user'), { id: 1 });
+	`, ctx)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	vars := ctx.Variables
+	if got, _ := vars.Environment.Get("token"); got != "abc" {
+		t.Errorf("environment token = %v", got)
+	}
+	if got, _ := vars.Collection.Get("count"); got != int64(4) {
+		t.Errorf("collection count = %#v, want 4", got)
+	}
+	if got, _ := vars.Globals.Get("seen"); got != true {
+		t.Errorf("globals seen = %v", got)
+	}
+	if got, _ := vars.Local.Get("local"); got != "emilys" {
+		t.Errorf("local = %v", got)
+	}
+	if vars.Environment.Has("base_url") {
+		t.Error("base_url should be unset")
+	}
+	if got := console.String(); got != "user emilys {\"id\":1}\n" {
+		t.Errorf("console = %q", got)
+	}
+}
+
+func TestEngine_Run_Request(t *testing.T) {
+	ctx := newContext("prerequest")
+	ctx.Variables.Environment.Set("base_url", "http://localhost")
+
+	_, err := New().Run(`
+		pm.request.method = 'post';
+		pm.request.url = pm.variables.get('base_url') + '/posts/add?draft=true';
+		pm.request.headers.upsert({ key: 'X-Trace', value: 7 });
+		pm.request.body.raw = '{"title": "new"}';
+	`, ctx)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	req := ctx.Request
+	if req.Method != "POST" {
+		t.Errorf("method = %s, want POST", req.Method)
+	}
+	if got := req.URL.String(); got != "http://localhost/posts/add?draft=true" {
+		t.Errorf("url = %s", got)
+	}
+	if len(req.Header) != 2 || req.Header[1].Key != "X-Trace" || req.Header[1].Value != "7" {
+		t.Errorf("headers = %+v", req.Header)
+	}
+	if req.Body == nil || req.Body.Mode != "raw" || req.Body.Raw != `{"title": "new"}` {
+		t.Errorf("body = %+v", req.Body)
+	}
+
+	// A script that does not touch the request leaves it alone
+	ctx = newContext("prerequest")
+	if _, err := New().Run(`pm.variables.get('x');`, ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if ctx.Request.URL.Raw != "{{base_url}}/users/1" || ctx.Request.Body != nil {
+		t.Errorf("untouched request changed: %+v", ctx.Request)
+	}
+}
+
+func TestEngine_Run_Errors(t *testing.T) {
+	ctx := newContext("test")
+	results, err := New().Run("pm.test('before', function () {});\nundefinedFunction();", ctx)
+	if err == nil || !strings.Contains(err.Error(), "undefinedFunction is not defined") {
+		t.Errorf("Run() error = %v, want a reference error", err)
+	}
+	if len(results) != 1 || !results[0].Passed {
+		t.Errorf("tests before the error should be kept, got %+v", results)
+	}
+
+	if _, err := New().Run(`require('lodash');`, ctx); err == nil {
+		t.Error("require should not be available")
+	}
+
+	engine := New()
+	engine.SetTimeout(50 * time.Millisecond)
+	if _, err := engine.Run(`while (true) {}`, ctx); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Run() error = %v, want a timeout", err)
+	}
+}
+
+// TestEngine_Templates runs the collections plaintest init creates against a
+// server that answers like dummyjson.com.
+func TestEngine_Templates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/auth/login":
+			_, _ = w.Write([]byte(`{"id": 1, "username": "emilys", "accessToken": "token-1"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/auth/me":
+			if r.Header.Get("Authorization") != "Bearer token-1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"message": "Invalid/Expired Token!"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"id": 1, "username": "emilys", "email": "emily@x.dummyjson.com", "firstName": "Emily"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/posts/add":
+			var post map[string]any
+			if err := json.Unmarshal(body, &post); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			post["id"] = 252
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(post)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	templates := filepath.Join("..", "templates")
+	dir := t.TempDir()
+	data, err := os.ReadFile(filepath.Join(templates, "environments", "dummyjson.postman_environment.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	envPath := filepath.Join(dir, "env.json")
+	data = bytes.Replace(data, []byte("https://dummyjson.com"), []byte(server.URL), 1)
+	if err := os.WriteFile(envPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	service := native.NewService()
+	service.SetScriptEngine(New())
+
+	authPath := filepath.Join(templates, "collections", "auth.postman_collection.json")
+	result, err := service.RunWithEnvironmentExport(context.Background(), authPath, []string{"-e", envPath}, envPath)
+	if err != nil || !result.Success {
+		t.Fatalf("auth collection failed: %v\n%s", err, result.Output)
+	}
+
+	testsPath := filepath.Join(templates, "collections", "api_tests.postman_collection.json")
+	dataPath := filepath.Join(templates, "data", "example.csv")
+	result, err = service.RunWithFlags(context.Background(), testsPath, []string{"-e", envPath, "-d", dataPath})
+	if err != nil || !result.Success {
+		t.Fatalf("api_tests collection failed: %v\n%s", err, result.Output)
+	}
+	if !strings.Contains(result.Output, "assertions: 12 executed, 0 failed") {
+		t.Errorf("unexpected summary:\n%s", result.Output)
+	}
+}
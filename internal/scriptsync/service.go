@@ -1,12 +1,12 @@
 package scriptsync
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/ssd532/plaintest/internal/collection"
 )
 
 // Config drives the extract and build process.
@@ -37,7 +37,7 @@ func (s *Service) Extract(collectionName string) error {
 		return err
 	}
 
-	collectionPath := filepath.Join(s.cfg.CollectionsDir, collectionName+".postman_collection.json")
+	collectionPath := filepath.Join(s.cfg.CollectionsDir, collectionName+collection.FileSuffix)
 	if _, err := os.Stat(collectionPath); err != nil {
 		return fmt.Errorf("collection not found: %s", collectionPath)
 	}
@@ -51,7 +51,7 @@ func (s *Service) Build(collectionName string) error {
 		return err
 	}
 
-	collectionPath := filepath.Join(s.cfg.CollectionsDir, collectionName+".postman_collection.json")
+	collectionPath := filepath.Join(s.cfg.CollectionsDir, collectionName+collection.FileSuffix)
 	if _, err := os.Stat(collectionPath); err != nil {
 		return fmt.Errorf("collection not found: %s", collectionPath)
 	}
@@ -70,34 +70,23 @@ func (s *Service) ensureDirs() error {
 }
 
 func (s *Service) extractCollection(collectionPath string) error {
-	data, err := os.ReadFile(collectionPath)
+	coll, err := collection.Load(collectionPath)
 	if err != nil {
 		return err
 	}
 
-	var node any
-	if err := json.Unmarshal(data, &node); err != nil {
-		return fmt.Errorf("%s: %w", collectionPath, err)
-	}
-
-	collMap, ok := node.(map[string]any)
-	if !ok {
-		return fmt.Errorf("%s: unexpected JSON structure", collectionPath)
-	}
-
-	collectionName := collectionID(collMap, filepath.Base(collectionPath))
+	collectionName := collectionID(coll, filepath.Base(collectionPath))
 	fmt.Printf("Extracting scripts from %s:\n", filepath.Base(collectionPath))
 
-	if events, ok := collMap["event"].([]any); ok {
-		if err := s.extractEvents(collectionName, []string{"collection"}, events); err != nil {
-			return err
-		}
+	if err := s.extractEvents(collectionName, []string{"collection"}, coll.Events); err != nil {
+		return err
 	}
 
-	if items, ok := collMap["item"].([]any); ok {
-		if err := s.extractItems(collectionName, nil, items); err != nil {
-			return err
-		}
+	err = coll.Walk(func(path []string, item *collection.Item) error {
+		return s.extractEvents(collectionName, path, item.Events)
+	})
+	if err != nil {
+		return err
 	}
 
 	fmt.Printf("Extraction complete\n")
@@ -105,43 +94,27 @@ func (s *Service) extractCollection(collectionPath string) error {
 }
 
 func (s *Service) buildCollection(collectionPath string) error {
-	data, err := os.ReadFile(collectionPath)
+	coll, err := collection.Load(collectionPath)
 	if err != nil {
 		return err
 	}
 
-	var node any
-	if err := json.Unmarshal(data, &node); err != nil {
-		return fmt.Errorf("%s: %w", collectionPath, err)
-	}
-
-	collMap, ok := node.(map[string]any)
-	if !ok {
-		return fmt.Errorf("%s: unexpected JSON structure", collectionPath)
-	}
-
-	collectionName := collectionID(collMap, filepath.Base(collectionPath))
+	collectionName := collectionID(coll, filepath.Base(collectionPath))
 	fmt.Printf("Building %s from scripts:\n", filepath.Base(collectionPath))
 
-	if events, ok := collMap["event"].([]any); ok {
-		if err := s.buildEvents(collectionName, []string{"collection"}, events); err != nil {
-			return err
-		}
-	}
-
-	if items, ok := collMap["item"].([]any); ok {
-		if err := s.buildItems(collectionName, nil, items); err != nil {
-			return err
-		}
+	if err := s.buildEvents(collectionName, []string{"collection"}, coll.Events); err != nil {
+		return err
 	}
 
-	// Write updated collection back to original location
-	buildData, err := json.MarshalIndent(collMap, "", "  ")
+	err = coll.Walk(func(path []string, item *collection.Item) error {
+		return s.buildEvents(collectionName, path, item.Events)
+	})
 	if err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(collectionPath, buildData, 0o644); err != nil {
+	// Write updated collection back to original location
+	if err := coll.Save(collectionPath); err != nil {
 		return err
 	}
 
@@ -149,71 +122,14 @@ func (s *Service) buildCollection(collectionPath string) error {
 	return nil
 }
 
-func (s *Service) extractItems(collectionName string, parents []string, items []any) error {
-	for _, itemVal := range items {
-		item, ok := itemVal.(map[string]any)
-		if !ok {
-			return errors.New("unexpected item structure")
-		}
-
-		name := stringValue(item["name"])
-		currentPath := append(parents, name)
-
-		if events, ok := item["event"].([]any); ok {
-			if err := s.extractEvents(collectionName, currentPath, events); err != nil {
-				return err
-			}
-		}
-
-		if children, ok := item["item"].([]any); ok {
-			if err := s.extractItems(collectionName, currentPath, children); err != nil {
-				return err
-			}
-		}
-	}
-	return nil
-}
-
-func (s *Service) buildItems(collectionName string, parents []string, items []any) error {
-	for _, itemVal := range items {
-		item, ok := itemVal.(map[string]any)
-		if !ok {
-			return errors.New("unexpected item structure")
-		}
-
-		name := stringValue(item["name"])
-		currentPath := append(parents, name)
-
-		if events, ok := item["event"].([]any); ok {
-			if err := s.buildEvents(collectionName, currentPath, events); err != nil {
-				return err
-			}
-		}
-
-		if children, ok := item["item"].([]any); ok {
-			if err := s.buildItems(collectionName, currentPath, children); err != nil {
-				return err
-			}
-		}
-	}
-	return nil
-}
-
-func (s *Service) extractEvents(collectionName string, parents []string, events []any) error {
-	for _, eventVal := range events {
-		evt, ok := eventVal.(map[string]any)
-		if !ok {
-			return errors.New("unexpected event structure")
-		}
-		listen := stringValue(evt["listen"])
-		scriptMap, ok := evt["script"].(map[string]any)
-		if !ok {
+func (s *Service) extractEvents(collectionName string, parents []string, events []collection.Event) error {
+	for _, evt := range events {
+		if evt.Script == nil {
 			continue
 		}
 
-		rawLines := extractExec(scriptMap["exec"])
-		rawContent := joinLines(rawLines)
-		scriptPath := s.scriptPath(collectionName, parents, listen)
+		rawContent := joinLines(evt.Script.Exec)
+		scriptPath := s.scriptPath(collectionName, parents, evt.Listen)
 
 		// Always overwrite script files
 		if err := os.MkdirAll(filepath.Dir(scriptPath), 0o755); err != nil {
@@ -230,19 +146,13 @@ func (s *Service) extractEvents(collectionName string, parents []string, events
 	return nil
 }
 
-func (s *Service) buildEvents(collectionName string, parents []string, events []any) error {
-	for _, eventVal := range events {
-		evt, ok := eventVal.(map[string]any)
-		if !ok {
-			return errors.New("unexpected event structure")
-		}
-		listen := stringValue(evt["listen"])
-		scriptMap, ok := evt["script"].(map[string]any)
-		if !ok {
+func (s *Service) buildEvents(collectionName string, parents []string, events []collection.Event) error {
+	for _, evt := range events {
+		if evt.Script == nil {
 			continue
 		}
 
-		scriptPath := s.scriptPath(collectionName, parents, listen)
+		scriptPath := s.scriptPath(collectionName, parents, evt.Listen)
 
 		// Read script content from file
 		scriptBytes, err := os.ReadFile(scriptPath)
@@ -251,7 +161,10 @@ func (s *Service) buildEvents(collectionName string, parents []string, events []
 		}
 
 		scriptContent := normalizeScript(string(scriptBytes))
-		scriptMap["exec"] = splitLines(scriptContent)
+		// Leave unchanged scripts alone so their lines are written back as exported
+		if normalizeScript(joinLines(evt.Script.Exec)) != scriptContent {
+			evt.Script.Exec = splitLines(scriptContent)
+		}
 
 		fmt.Printf("✓ Injected: %s\n", filepath.Base(scriptPath))
 	}
@@ -282,32 +195,13 @@ func (s *Service) scriptPath(collectionName string, parents []string, listen str
 	return filepath.Join(dir, fileName)
 }
 
-func collectionID(coll map[string]any, fallback string) string {
-	if info, ok := coll["info"].(map[string]any); ok {
-		if name := stringValue(info["name"]); name != "" {
-			return name
-		}
+func collectionID(coll *collection.Collection, fallback string) string {
+	if coll.Info.Name != "" {
+		return coll.Info.Name
 	}
 	return fallback
 }
 
-func extractExec(execVal any) []string {
-	switch val := execVal.(type) {
-	case []any:
-		result := make([]string, 0, len(val))
-		for _, line := range val {
-			result = append(result, stringValue(line))
-		}
-		return result
-	case []string:
-		return val
-	case string:
-		return []string{val}
-	default:
-		return nil
-	}
-}
-
 func joinLines(lines []string) string {
 	if len(lines) == 0 {
 		return ""
@@ -340,17 +234,6 @@ func withTrailingNewline(content string) []byte {
 	return []byte(content + "\n")
 }
 
-func stringValue(in any) string {
-	switch v := in.(type) {
-	case string:
-		return v
-	case fmt.Stringer:
-		return v.String()
-	default:
-		return ""
-	}
-}
-
 func sanitize(name string) string {
 	name = strings.ToLower(strings.TrimSpace(name))
 	name = strings.ReplaceAll(name, " ", "-")
@@ -233,3 +233,49 @@ func TestSanitize(t *testing.T) {
 		t.Error("sanitize should handle empty strings")
 	}
 }
+
+func TestService_Build_PreservesLayout(t *testing.T) {
+	_, cleanup := setupTest(t)
+	defer cleanup()
+
+	// Postman export: tab indentation, non-alphabetical keys, raw HTML characters
+	original := "{\n\t\"info\": {\n\t\t\"name\": \"Test\",\n\t\t\"schema\": \"https://schema.getpostman.com/json/collection/v2.1.0/collection.json\"\n\t},\n" +
+		"\t\"item\": [\n\t\t{\n\t\t\t\"name\": \"Test Request\",\n\t\t\t\"event\": [\n\t\t\t\t{\n\t\t\t\t\t\"listen\": \"test\",\n\t\t\t\t\t\"script\": {\n" +
+		"\t\t\t\t\t\t\"exec\": [\n\t\t\t\t\t\t\t\"pm.test('status', function () {\",\n\t\t\t\t\t\t\t\"    pm.expect(pm.response.code < 300 && pm.response.code >= 200).to.be.true;\",\n\t\t\t\t\t\t\t\"});\"\n\t\t\t\t\t\t],\n" +
+		"\t\t\t\t\t\t\"type\": \"text/javascript\"\n\t\t\t\t\t}\n\t\t\t\t}\n\t\t\t]\n\t\t}\n\t],\n" +
+		"\t\"event\": [\n\t\t{\n\t\t\t\"listen\": \"prerequest\",\n\t\t\t\"script\": {\n\t\t\t\t\"type\": \"text/javascript\",\n\t\t\t\t\"exec\": [\n\t\t\t\t\t\"console.log('collection script');\"\n\t\t\t\t]\n\t\t\t}\n\t\t}\n\t]\n}"
+
+	if err := os.MkdirAll("collections", 0755); err != nil {
+		t.Fatalf("Failed to create collections dir: %v", err)
+	}
+	if err := os.WriteFile("collections/test.postman_collection.json", []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write collection: %v", err)
+	}
+
+	service := NewService(Config{})
+	if err := service.Extract("test"); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	// A push without edits must leave the file byte for byte identical
+	if err := service.Build("test"); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	unchanged, _ := os.ReadFile("collections/test.postman_collection.json")
+	if string(unchanged) != original {
+		t.Errorf("Build without edits rewrote the collection:\n%s", unchanged)
+	}
+
+	// Editing one script changes only that script's exec lines
+	if err := os.WriteFile("scripts/test/_collection__prerequest.js", []byte("console.log('edited script');\n"), 0644); err != nil {
+		t.Fatalf("Failed to edit script: %v", err)
+	}
+	if err := service.Build("test"); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	updated, _ := os.ReadFile("collections/test.postman_collection.json")
+	want := strings.Replace(original, "collection script", "edited script", 1)
+	if string(updated) != want {
+		t.Errorf("Build changed more than the edited script:\n%s", updated)
+	}
+}
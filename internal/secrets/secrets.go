@@ -0,0 +1,199 @@
+// Package secrets resolves secret references in environment files, so that
+// the files can be committed without the secrets themselves.
+//
+// An environment value may hold ${NAME} references to environment variables
+// of the shell, or be a file:<path> or vault:<name> reference as a whole.
+// ResolveEnvironment writes a temporary copy of the environment with the
+// references replaced, and Masker hides the resolved values in output.
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ssd532/plaintest/internal/tempdir"
+)
+
+// Prefixes of values that are references as a whole
+const (
+	filePrefix  = "file:"
+	vaultPrefix = "vault:"
+)
+
+// minMaskLength is the length below which resolved values are not masked,
+// as hiding them would hide unrelated text too.
+const minMaskLength = 4
+
+// mask replaces secret values in output.
+const mask = "****"
+
+// variablePattern matches a ${NAME} reference.
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Resolver resolves secret references. The vault is opened on the first
+// vault: reference.
+type Resolver struct {
+	lookupEnv func(string) (string, bool)
+	vaultPath string
+	vault     *Vault
+}
+
+// NewResolver returns a Resolver reading environment variables of the
+// process and the vault at vaultPath.
+func NewResolver(vaultPath string) *Resolver {
+	return &Resolver{lookupEnv: os.LookupEnv, vaultPath: vaultPath}
+}
+
+// Resolve returns value with its references replaced, and the secret values
+// it used. file: paths are relative to dir.
+func (r *Resolver) Resolve(value, dir string) (string, []string, error) {
+	switch {
+	case strings.HasPrefix(value, filePrefix):
+		path := strings.TrimPrefix(value, filePrefix)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read secret file: %w", err)
+		}
+		secret := TrimLineBreak(string(data))
+		return secret, []string{secret}, nil
+
+	case strings.HasPrefix(value, vaultPrefix):
+		name := strings.TrimPrefix(value, vaultPrefix)
+		if r.vault == nil {
+			vault, err := OpenVault(r.vaultPath, os.Getenv(KeyVariable))
+			if err != nil {
+				return "", nil, err
+			}
+			r.vault = vault
+		}
+		secret, ok := r.vault.Get(name)
+		if !ok {
+			return "", nil, fmt.Errorf("secret %s is not in the vault %s", name, r.vaultPath)
+		}
+		return secret, []string{secret}, nil
+	}
+
+	var used []string
+	var err error
+	resolved := variablePattern.ReplaceAllStringFunc(value, func(match string) string {
+		name := variablePattern.FindStringSubmatch(match)[1]
+		secret, ok := r.lookupEnv(name)
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("environment variable %s is not set", name)
+			}
+			return match
+		}
+		used = append(used, secret)
+		return secret
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return resolved, used, nil
+}
+
+// TrimLineBreak removes the final line break of s, \n or \r\n, that editors
+// and echo add. Line breaks before it are part of the secret.
+func TrimLineBreak(s string) string {
+	if trimmed, ok := strings.CutSuffix(s, "\n"); ok {
+		return strings.TrimSuffix(trimmed, "\r")
+	}
+	return s
+}
+
+// IsReference reports whether value holds a secret reference.
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, filePrefix) || strings.HasPrefix(value, vaultPrefix) || variablePattern.MatchString(value)
+}
+
+// ResolveEnvironment resolves the references in the values of the Postman
+// environment file at path. When there are any, it writes the resolved
+// environment to a new temporary file and returns its path and the secret
+// values used; otherwise it returns "".
+func ResolveEnvironment(path string, r *Resolver) (string, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read environment: %w", err)
+	}
+	var env map[string]any
+	if err := json.Unmarshal(data, &env); err != nil {
+		return "", nil, fmt.Errorf("invalid environment %s: %w", path, err)
+	}
+
+	var secrets []string
+	values, _ := env["values"].([]any)
+	for _, v := range values {
+		variable, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		value, ok := variable["value"].(string)
+		if !ok {
+			continue
+		}
+		resolved, used, err := r.Resolve(value, filepath.Dir(path))
+		if err != nil {
+			return "", nil, fmt.Errorf("variable %v: %w", variable["key"], err)
+		}
+		if resolved != value {
+			variable["value"] = resolved
+		}
+		secrets = append(secrets, used...)
+	}
+	if len(secrets) == 0 {
+		return "", nil, nil
+	}
+
+	data, err = json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return "", nil, err
+	}
+	file, err := tempdir.CreateTemp("plaintest_env_*.json")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create environment file: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		os.Remove(file.Name())
+		return "", nil, fmt.Errorf("failed to write environment file: %w", err)
+	}
+	return file.Name(), secrets, nil
+}
+
+// Masker hides secret values in text.
+type Masker struct {
+	replacer *strings.Replacer
+}
+
+// NewMasker returns a Masker for values. Values shorter than four
+// characters are left visible.
+func NewMasker(values []string) *Masker {
+	var masked []string
+	for _, value := range values {
+		if len(value) >= minMaskLength {
+			masked = append(masked, value)
+		}
+	}
+	// Longer values first, so a secret containing another is masked whole
+	sort.Slice(masked, func(i, j int) bool { return len(masked[i]) > len(masked[j]) })
+
+	var pairs []string
+	for _, value := range masked {
+		pairs = append(pairs, value, mask)
+	}
+	return &Masker{replacer: strings.NewReplacer(pairs...)}
+}
+
+// Mask returns text with every secret value replaced by ****.
+func (m *Masker) Mask(text string) string {
+	return m.replacer.Replace(text)
+}
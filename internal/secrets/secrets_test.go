@@ -0,0 +1,187 @@
+package secrets
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const environment = `{
+  "name": "staging",
+  "values": [
+    {"key": "base_url", "value": "https://staging.example.com", "enabled": true},
+    {"key": "auth", "value": "Bearer ${API_TOKEN}", "enabled": true},
+    {"key": "password", "value": "file:secrets/password.txt", "enabled": true},
+    {"key": "client_secret", "value": "vault:client_secret", "enabled": true},
+    {"key": "retries", "value": 3}
+  ]
+}`
+
+func TestResolveEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	vaultPath := filepath.Join(dir, VaultPath)
+	t.Setenv("API_TOKEN", "tok-123456")
+	t.Setenv(KeyVariable, "correct horse")
+
+	vault, err := OpenVault(vaultPath, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault.Set("client_secret", "cs-abcdef")
+	if err := vault.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "secrets"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secrets", "password.txt"), []byte("hunter22\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	envPath := filepath.Join(dir, "staging.postman_environment.json")
+	if err := os.WriteFile(envPath, []byte(environment), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, secrets, err := ResolveEnvironment(envPath, NewResolver(vaultPath))
+	if err != nil {
+		t.Fatalf("ResolveEnvironment() error = %v", err)
+	}
+	defer os.Remove(resolved)
+	if !reflect.DeepEqual(secrets, []string{"tok-123456", "hunter22", "cs-abcdef"}) {
+		t.Errorf("ResolveEnvironment() secrets = %v", secrets)
+	}
+
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env struct {
+		Name   string
+		Values []struct {
+			Key   string
+			Value any
+		}
+	}
+	if err := json.Unmarshal(data, &env); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]any)
+	for _, v := range env.Values {
+		got[v.Key] = v.Value
+	}
+	want := map[string]any{
+		"base_url": "https://staging.example.com", "auth": "Bearer tok-123456",
+		"password": "hunter22", "client_secret": "cs-abcdef", "retries": float64(3),
+	}
+	if env.Name != "staging" || !reflect.DeepEqual(got, want) {
+		t.Errorf("resolved environment = %s", data)
+	}
+
+	// An environment without references is used as it is
+	plain := filepath.Join(dir, "plain.json")
+	if err := os.WriteFile(plain, []byte(`{"values": [{"key": "a", "value": "b"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if resolved, _, err := ResolveEnvironment(plain, NewResolver(vaultPath)); err != nil || resolved != "" {
+		t.Errorf("ResolveEnvironment(plain) = %q, %v", resolved, err)
+	}
+}
+
+func TestIsReference(t *testing.T) {
+	for value, want := range map[string]bool{
+		"Bearer ${TOKEN}": true, "file:token.txt": true, "vault:token": true,
+		"plain": false, "{{token}}": false, "$TOKEN": false,
+	} {
+		if got := IsReference(value); got != want {
+			t.Errorf("IsReference(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestResolve_Errors(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(KeyVariable, "")
+	r := NewResolver(filepath.Join(dir, VaultPath))
+	tests := []struct {
+		value   string
+		wantErr string
+	}{
+		{"${PLAINTEST_TEST_UNSET}", "environment variable PLAINTEST_TEST_UNSET is not set"},
+		{"file:missing.txt", "failed to read secret file"},
+		{"vault:token", KeyVariable + " is not set"},
+	}
+	for _, tt := range tests {
+		if _, _, err := r.Resolve(tt.value, dir); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Resolve(%q) error = %v, want %q", tt.value, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTrimLineBreak(t *testing.T) {
+	for value, want := range map[string]string{
+		"s3cret\n": "s3cret", "s3cret\r\n": "s3cret", "s3cret": "s3cret",
+		"s3cret\n\n": "s3cret\n", "line1\r\n\r\n": "line1\r\n", "s3cret\r": "s3cret\r",
+	} {
+		if got := TrimLineBreak(value); got != want {
+			t.Errorf("TrimLineBreak(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestVault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), VaultPath)
+	vault, err := OpenVault(path, "s3cret")
+	if err != nil {
+		t.Fatalf("OpenVault(missing) error = %v", err)
+	}
+	vault.Set("b", "2")
+	vault.Set("a", "1")
+	if err := vault.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), `"a"`) {
+		t.Errorf("vault file is not encrypted: %s", data)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("vault file mode = %v, %v", info.Mode(), err)
+	}
+
+	reopened, err := OpenVault(path, "s3cret")
+	if err != nil {
+		t.Fatalf("OpenVault() error = %v", err)
+	}
+	if value, ok := reopened.Get("a"); !ok || value != "1" || !reflect.DeepEqual(reopened.Names(), []string{"a", "b"}) {
+		t.Errorf("reopened vault = %v", reopened.Names())
+	}
+	if !reopened.Remove("a") || reopened.Remove("a") {
+		t.Error("Remove() should report whether the secret existed")
+	}
+
+	if _, err := OpenVault(path, "wrong"); err == nil || !strings.Contains(err.Error(), "cannot decrypt") {
+		t.Errorf("OpenVault(wrong passphrase) error = %v", err)
+	}
+}
+
+func TestPBKDF2(t *testing.T) {
+	// RFC 7914, section 11
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if got := hex.EncodeToString(pbkdf2([]byte("passwd"), []byte("salt"), 1, 64)); got != want {
+		t.Errorf("pbkdf2() = %s, want %s", got, want)
+	}
+}
+
+func TestMasker(t *testing.T) {
+	m := NewMasker([]string{"tok-123", "tok-123456", "abc"})
+	got := m.Mask("Authorization: Bearer tok-123456, old tok-123, id abc")
+	want := "Authorization: Bearer ****, old ****, id abc"
+	if got != want {
+		t.Errorf("Mask() = %q, want %q", got, want)
+	}
+}
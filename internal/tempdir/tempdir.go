@@ -0,0 +1,57 @@
+// Package tempdir keeps the temporary files of a run in one private
+// directory, so that concurrent runs never share a file and everything a run
+// leaves behind is removed with the directory.
+package tempdir
+
+import (
+	"os"
+	"sync"
+)
+
+var (
+	mu  sync.Mutex
+	dir string
+)
+
+// Start creates the private directory of a run, readable by the owner only,
+// and makes it the directory of CreateTemp. It returns the directory.
+func Start() (string, error) {
+	d, err := os.MkdirTemp("", "plaintest_run_*")
+	if err != nil {
+		return "", err
+	}
+	mu.Lock()
+	dir = d
+	mu.Unlock()
+	return d, nil
+}
+
+// Dir returns the directory of the current run, or the system temporary
+// directory when no run has started.
+func Dir() string {
+	mu.Lock()
+	defer mu.Unlock()
+	if dir == "" {
+		return os.TempDir()
+	}
+	return dir
+}
+
+// CreateTemp creates a new file in Dir, as os.CreateTemp does.
+func CreateTemp(pattern string) (*os.File, error) {
+	return os.CreateTemp(Dir(), pattern)
+}
+
+// Remove deletes the directory of the current run with everything in it.
+// When keep is true the directory is left in place. Either way later files
+// go to the system temporary directory again.
+func Remove(keep bool) error {
+	mu.Lock()
+	d := dir
+	dir = ""
+	mu.Unlock()
+	if d == "" || keep {
+		return nil
+	}
+	return os.RemoveAll(d)
+}
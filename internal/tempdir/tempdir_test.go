@@ -0,0 +1,50 @@
+package tempdir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunDirectory(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+
+	d, err := Start()
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if info, err := os.Stat(d); err != nil || info.Mode().Perm() != 0o700 {
+		t.Errorf("run directory mode = %v, %v", info.Mode(), err)
+	}
+
+	file, err := CreateTemp("plaintest_env_*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	if filepath.Dir(file.Name()) != d || Dir() != d {
+		t.Errorf("CreateTemp() = %s, want a file in %s", file.Name(), d)
+	}
+
+	if err := Remove(false); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := os.Stat(d); !os.IsNotExist(err) {
+		t.Errorf("run directory still exists: %v", err)
+	}
+	if Dir() != os.TempDir() {
+		t.Errorf("Dir() after Remove() = %s, want %s", Dir(), os.TempDir())
+	}
+
+	// A kept directory stays with its files
+	d, err = Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Remove(true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(d); err != nil {
+		t.Errorf("kept run directory is gone: %v", err)
+	}
+}
@@ -2,91 +2,116 @@ package templates
 
 import (
 	"embed"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 //go:embed collections/* environments/* data/*
 var templateFS embed.FS
 
-type ProjectInitializer struct{}
+// Config holds the project directories to create.
+type Config struct {
+	CollectionsDir  string
+	ScriptsDir      string
+	DataDir         string
+	EnvironmentsDir string
+	ReportsDir      string
+	PayloadsDir     string
+	// Force overwrites template files that already exist.
+	Force bool
+}
+
+// ErrExists is returned when template files would overwrite existing files.
+var ErrExists = errors.New("files already exist")
 
-func NewProjectInitializer() *ProjectInitializer {
-	return &ProjectInitializer{}
+type ProjectInitializer struct {
+	cfg Config
 }
 
-func (p *ProjectInitializer) CreateProjectStructure() error {
-	// Create directories
-	directories := []string{
-		"collections",
-		"scripts",
-		"data",
-		"environments",
-		"reports",
-		"payloads",
+// NewProjectInitializer constructs a ProjectInitializer, defaulting empty
+// directories to the standard layout.
+func NewProjectInitializer(cfg Config) *ProjectInitializer {
+	if cfg.CollectionsDir == "" {
+		cfg.CollectionsDir = "collections"
 	}
-	for _, dir := range directories {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return err
-		}
+	if cfg.ScriptsDir == "" {
+		cfg.ScriptsDir = "scripts"
 	}
-
-	// Create template files
-	if err := p.createCollectionTemplates(); err != nil {
-		return err
+	if cfg.DataDir == "" {
+		cfg.DataDir = "data"
 	}
-
-	if err := p.createEnvironmentTemplates(); err != nil {
-		return err
+	if cfg.EnvironmentsDir == "" {
+		cfg.EnvironmentsDir = "environments"
 	}
-
-	if err := p.createDataTemplates(); err != nil {
-		return err
+	if cfg.ReportsDir == "" {
+		cfg.ReportsDir = "reports"
 	}
-
-	return nil
+	if cfg.PayloadsDir == "" {
+		cfg.PayloadsDir = "payloads"
+	}
+	return &ProjectInitializer{cfg: cfg}
 }
 
-func (p *ProjectInitializer) createCollectionTemplates() error {
-	// Copy auth collection template
-	authData, err := templateFS.ReadFile("collections/auth.postman_collection.json")
-	if err != nil {
-		return err
-	}
-	err = os.WriteFile("collections/get_auth.postman_collection.json", authData, 0644)
-	if err != nil {
-		return err
+// CreateProjectStructure creates the project directories and copies the
+// template files into them. Unless Force is set, it creates nothing when any
+// template file already exists.
+func (p *ProjectInitializer) CreateProjectStructure() error {
+	files := p.templateFiles()
+	if !p.cfg.Force {
+		var existing []string
+		for _, file := range files {
+			if _, err := os.Stat(file.path); err == nil {
+				existing = append(existing, file.path)
+			}
+		}
+		if len(existing) > 0 {
+			return fmt.Errorf("%w: %s", ErrExists, strings.Join(existing, ", "))
+		}
 	}
 
-	// Copy API tests collection template
-	apiData, err := templateFS.ReadFile("collections/api_tests.postman_collection.json")
-	if err != nil {
-		return err
+	// Create directories
+	directories := []string{
+		p.cfg.CollectionsDir,
+		p.cfg.ScriptsDir,
+		p.cfg.DataDir,
+		p.cfg.EnvironmentsDir,
+		p.cfg.ReportsDir,
+		p.cfg.PayloadsDir,
 	}
-	err = os.WriteFile("collections/api_tests.postman_collection.json", apiData, 0644)
-	if err != nil {
-		return err
+	for _, dir := range directories {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
 	}
 
-	// Copy smoke collection template
-	smokeData, err := templateFS.ReadFile("collections/smoke.postman_collection.json")
-	if err != nil {
-		return err
+	// Create template files
+	for _, file := range files {
+		data, err := templateFS.ReadFile(file.template)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(file.path, data, 0644); err != nil {
+			return err
+		}
 	}
-	return os.WriteFile("collections/smoke.postman_collection.json", smokeData, 0644)
+	return nil
 }
 
-func (p *ProjectInitializer) createEnvironmentTemplates() error {
-	envData, err := templateFS.ReadFile("environments/dummyjson.postman_environment.json")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile("environments/dummyjson.postman_environment.json", envData, 0644)
+// templateFile is an embedded template and the path it is copied to.
+type templateFile struct {
+	template string
+	path     string
 }
 
-func (p *ProjectInitializer) createDataTemplates() error {
-	csvData, err := templateFS.ReadFile("data/example.csv")
-	if err != nil {
-		return err
+func (p *ProjectInitializer) templateFiles() []templateFile {
+	return []templateFile{
+		{"collections/auth.postman_collection.json", filepath.Join(p.cfg.CollectionsDir, "get_auth.postman_collection.json")},
+		{"collections/api_tests.postman_collection.json", filepath.Join(p.cfg.CollectionsDir, "api_tests.postman_collection.json")},
+		{"collections/smoke.postman_collection.json", filepath.Join(p.cfg.CollectionsDir, "smoke.postman_collection.json")},
+		{"environments/dummyjson.postman_environment.json", filepath.Join(p.cfg.EnvironmentsDir, "dummyjson.postman_environment.json")},
+		{"data/example.csv", filepath.Join(p.cfg.DataDir, "example.csv")},
 	}
-	return os.WriteFile("data/example.csv", csvData, 0644)
 }